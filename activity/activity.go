@@ -6,21 +6,172 @@ import (
 	"time"
 )
 
+// RelationshipType defines how an activity depends on one of its predecessors.
+type RelationshipType int
+
+// Enumeration of available relationship types.
+const (
+	FinishToStart  RelationshipType = 0 // The activity starts after the predecessor finishes
+	StartToStart   RelationshipType = 1 // The activity starts after the predecessor starts
+	FinishToFinish RelationshipType = 2 // The activity finishes after the predecessor finishes
+	StartToFinish  RelationshipType = 3 // The activity finishes after the predecessor starts
+)
+
+// String returns the abbreviation of the relationship type (FS, SS, FF or SF).
+func (t RelationshipType) String() string {
+	switch t {
+	case FinishToStart:
+		return "FS"
+	case StartToStart:
+		return "SS"
+	case FinishToFinish:
+		return "FF"
+	case StartToFinish:
+		return "SF"
+	}
+	return fmt.Sprintf("RelationshipType(%d)", int(t))
+}
+
+// ParseRelationshipType parses a relationship type from its abbreviation (FS, SS, FF or SF).
+func ParseRelationshipType(s string) (t RelationshipType, err error) {
+	switch s {
+	case "FS":
+		return FinishToStart, nil
+	case "SS":
+		return StartToStart, nil
+	case "FF":
+		return FinishToFinish, nil
+	case "SF":
+		return StartToFinish, nil
+	}
+	return t, fmt.Errorf("unknown relationship type %q", s)
+}
+
+// Relationship holds the type and the lag of the link between an activity and one of its predecessors.
+type Relationship struct {
+	Type RelationshipType `json:"type"` // Type of the relationship
+	Lag  time.Duration    `json:"lag"`  // Delay (or lead if negative) applied to the relationship
+}
+
+// String returns the relationship in a compact form, e.g. "SS+2h0m0s" or "FF-30m0s".
+func (r Relationship) String() string {
+	if r.Lag < 0 {
+		return fmt.Sprintf("%s-%s", r.Type, -r.Lag)
+	}
+	return fmt.Sprintf("%s+%s", r.Type, r.Lag)
+}
+
+// ConstraintType defines a date constraint imposed on an activity.
+type ConstraintType int
+
+// Enumeration of available constraint types.
+const (
+	NoConstraint        ConstraintType = 0 // The activity is scheduled as soon as possible
+	StartNoEarlierThan  ConstraintType = 1 // The activity cannot start before the constraint date
+	StartNoLaterThan    ConstraintType = 2 // The activity must start on or before the constraint date
+	FinishNoEarlierThan ConstraintType = 3 // The activity cannot finish before the constraint date
+	FinishNoLaterThan   ConstraintType = 4 // The activity must finish on or before the constraint date
+	MustStartOn         ConstraintType = 5 // The activity starts on the constraint date, regardless of its predecessors
+	MustFinishOn        ConstraintType = 6 // The activity finishes on the constraint date, regardless of its predecessors
+	AsLateAsPossible    ConstraintType = 7 // The activity is scheduled as late as possible without delaying its successors
+)
+
+var constraintTypeNames = []string{"", "SNET", "SNLT", "FNET", "FNLT", "MSO", "MFO", "ALAP"}
+
+// String returns the abbreviation of the constraint type (e.g. SNET, FNLT or ALAP),
+// or an empty string if there is no constraint.
+func (t ConstraintType) String() string {
+	if t < 0 || int(t) >= len(constraintTypeNames) {
+		return fmt.Sprintf("ConstraintType(%d)", int(t))
+	}
+	return constraintTypeNames[t]
+}
+
+// ParseConstraintType parses a constraint type from its abbreviation (e.g. SNET, FNLT or ALAP).
+// An empty string is parsed as no constraint.
+func ParseConstraintType(s string) (t ConstraintType, err error) {
+	idx := slices.Index(constraintTypeNames, s)
+	if idx == -1 {
+		return t, fmt.Errorf("unknown constraint type %q", s)
+	}
+	return ConstraintType(idx), nil
+}
+
 // Activity is a struct representing an activity with various attributes.
 type Activity struct {
-	Id             int           `json:"id"`             // Unique identifier of the activity
-	Description    string        `json:"description"`    // description of the activity
-	Duration       time.Duration `json:"duration"`       // duration of the activity
-	Start          time.Time     `json:"start"`          // Start time of the activity
-	Finish         time.Time     `json:"finish"`         // Finish time of he activity
-	PredecessorsId []int         `json:"predecessorsId"` // ID of the activities that precede
-	SuccessorsId   []int         `json:"successorsId"`   // ID of the activities that come after
-	Progress       float32       `json:"progress"`       // How complete is the activity (between 0 and 1)
-	Cost           float64       `json:"cost"`           // Cost of the activity
+	Id             int                  `json:"id"`                      // Unique identifier of the activity
+	Description    string               `json:"description"`             // description of the activity
+	Wbs            string               `json:"wbs"`                     // Path of the work breakdown structure element of the activity (e.g. "1.2.3", a dot of a code being escaped as "\.")
+	Duration       time.Duration        `json:"duration"`                // duration of the activity
+	CalendarId     int                  `json:"calendarId"`              // ID of the calendar of the activity (0 for the default calendar)
+	Start          time.Time            `json:"start"`                   // Start time of the activity
+	Finish         time.Time            `json:"finish"`                  // Finish time of he activity
+	LateStart      time.Time            `json:"lateStart"`               // Latest start time of the activity without delaying the project
+	LateFinish     time.Time            `json:"lateFinish"`              // Latest finish time of the activity without delaying the project
+	TotalFloat     time.Duration        `json:"totalFloat"`              // How much the activity can be delayed without delaying the project
+	FreeFloat      time.Duration        `json:"freeFloat"`               // How much the activity can be delayed without delaying its successors
+	PredecessorsId []int                `json:"predecessorsId"`          // ID of the activities that precede
+	SuccessorsId   []int                `json:"successorsId"`            // ID of the activities that come after
+	Relationships  map[int]Relationship `json:"relationships,omitempty"` // Type and lag of the links to the predecessors, keyed by predecessor ID
+	ConstraintType ConstraintType       `json:"constraintType"`          // Type of the date constraint imposed on the activity
+	ConstraintDate time.Time            `json:"constraintDate"`          // Date of the constraint imposed on the activity
+	Progress       float32              `json:"progress"`                // How complete is the activity (between 0 and 1)
+	ActualStart    time.Time            `json:"actualStart"`             // Time at which the activity actually started (zero if not started)
+	ActualFinish   time.Time            `json:"actualFinish"`            // Time at which the activity actually finished (zero if not finished)
+	Cost           float64              `json:"cost"`                    // Cost of the activity
+	Code           string               `json:"code,omitempty"`          // Code of the activity used by the planners (e.g. "A1000"), empty if the activity is known by its ID
+}
+
+// IsCritical reports whether the activity is on the critical path, that is
+// whether it has no total float left. Completed activities are never critical.
+func (a *Activity) IsCritical() bool {
+	return a.TotalFloat <= 0 && !a.IsCompleted()
+}
+
+// IsStarted reports whether the activity has an actual start.
+func (a *Activity) IsStarted() bool {
+	return !a.ActualStart.IsZero()
+}
+
+// IsCompleted reports whether the activity has an actual finish.
+func (a *Activity) IsCompleted() bool {
+	return !a.ActualFinish.IsZero()
+}
+
+// RemainingDuration returns the duration of the work left in the activity,
+// computed from its progress. It is zero for completed activities.
+func (a *Activity) RemainingDuration() time.Duration {
+	if a.IsCompleted() || a.Progress >= 1 {
+		return 0
+	}
+	if a.Progress <= 0 {
+		return a.Duration
+	}
+	return time.Duration(float64(a.Duration) * (1 - float64(a.Progress))).Round(time.Second)
+}
+
+// Relationship returns the relationship between the activity and the predecessor with the given 'id'.
+// If no relationship was set for the predecessor, a finish to start relationship without lag is returned.
+func (a *Activity) Relationship(id int) Relationship {
+	return a.Relationships[id]
+}
+
+// SetRelationship sets the relationship between the activity and the predecessor with the given 'id'.
+// It returns an error if the predecessor is not in the activity's predecessors list.
+func (a *Activity) SetRelationship(id int, rel Relationship) (err error) {
+	if slices.Index(a.PredecessorsId, id) == -1 {
+		return fmt.Errorf("no predecessor with id %d", id)
+	}
+	if a.Relationships == nil {
+		a.Relationships = make(map[int]Relationship)
+	}
+	a.Relationships[id] = rel
+	return
 }
 
 // AddPredecessor adds a predecessor with the given 'id' to the activity's predecessors list.
 // It returns an error if the predecessor already exists in the list.
+// Only the activity is updated, project.Link records the link in both activities.
 func (a *Activity) AddPredecessor(id int) (err error) {
 	if slices.Index(a.PredecessorsId, id) != -1 {
 		return fmt.Errorf("predecessor with id %d already exists", id)
@@ -31,6 +182,7 @@ func (a *Activity) AddPredecessor(id int) (err error) {
 
 // AddSuccessor adds a successor with the given 'id' to the activity's successors list.
 // It returns an error if the successor already exists in the list.
+// Only the activity is updated, project.Link records the link in both activities.
 func (a *Activity) AddSuccessor(id int) (err error) {
 	if slices.Index(a.SuccessorsId, id) != -1 {
 		return fmt.Errorf("successor with id %d already exists", id)
@@ -39,7 +191,8 @@ func (a *Activity) AddSuccessor(id int) (err error) {
 	return
 }
 
-// RemovePredecessor removes the predecessor with the given 'id' from the activity's predecessors list.
+// RemovePredecessor removes the predecessor with the given 'id' from the activity's predecessors list,
+// along with its relationship.
 // It returns an error if the predecessor is not found in the list.
 func (a *Activity) RemovePredecessor(id int) (err error) {
 	idx := slices.Index(a.PredecessorsId, id)
@@ -47,6 +200,7 @@ func (a *Activity) RemovePredecessor(id int) (err error) {
 		return fmt.Errorf("no predecessor with id %d", id)
 	}
 	a.PredecessorsId = slices.Delete(a.PredecessorsId, idx, idx+1)
+	delete(a.Relationships, id)
 	return
 }
 
@@ -61,7 +215,8 @@ func (a *Activity) RemoveSuccessor(id int) (err error) {
 	return
 }
 
-// UpdatePredecessorId updates the predecessor ID from 'oldId' to 'newId' in the activity's predecessors list.
+// UpdatePredecessorId updates the predecessor ID from 'oldId' to 'newId' in the activity's predecessors list,
+// and in its relationships.
 // It returns an error if the predecessor with 'oldId' is not found.
 func (a *Activity) UpdatePredecessorId(oldId, newId int) (err error) {
 	idx := slices.Index(a.PredecessorsId, oldId)
@@ -69,6 +224,10 @@ func (a *Activity) UpdatePredecessorId(oldId, newId int) (err error) {
 		return fmt.Errorf(fmt.Sprintf("no predecessor with id %d", oldId))
 	}
 	a.PredecessorsId = slices.Replace(a.PredecessorsId, idx, idx+1, newId)
+	if rel, ok := a.Relationships[oldId]; ok {
+		delete(a.Relationships, oldId)
+		a.Relationships[newId] = rel
+	}
 	return
 }
 
@@ -101,3 +101,90 @@ func TestUpdateSuccessorId(t *testing.T) {
 		t.Error("expected UpdateSuccessorId to fail")
 	}
 }
+
+func TestSetRelationship(t *testing.T) {
+	temp := Activity{Id: 69, Description: "Testing", Duration: time.Hour, PredecessorsId: []int{1, 2, 3}, SuccessorsId: []int{4, 5, 6}}
+	if rel := temp.Relationship(1); rel != (Relationship{Type: FinishToStart}) {
+		t.Errorf("got %v, want %v", rel, Relationship{Type: FinishToStart})
+	}
+	rel := Relationship{Type: StartToStart, Lag: 2 * time.Hour}
+	err := temp.SetRelationship(1, rel)
+	if err != nil {
+		t.Error(err)
+	}
+	if temp.Relationship(1) != rel {
+		t.Errorf("got %v, want %v", temp.Relationship(1), rel)
+	}
+	err = temp.SetRelationship(7, rel)
+	if err == nil {
+		t.Error("expected SetRelationship to fail")
+	}
+	err = temp.UpdatePredecessorId(1, 11)
+	if err != nil {
+		t.Error(err)
+	}
+	if temp.Relationship(11) != rel {
+		t.Errorf("got %v, want %v", temp.Relationship(11), rel)
+	}
+	err = temp.RemovePredecessor(11)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, ok := temp.Relationships[11]; ok {
+		t.Error("expected relationship to be removed")
+	}
+}
+
+func TestParseRelationshipType(t *testing.T) {
+	for _, relType := range []RelationshipType{FinishToStart, StartToStart, FinishToFinish, StartToFinish} {
+		parsed, err := ParseRelationshipType(relType.String())
+		if err != nil {
+			t.Error(err)
+		}
+		if parsed != relType {
+			t.Errorf("got %v, want %v", parsed, relType)
+		}
+	}
+	if _, err := ParseRelationshipType("XX"); err == nil {
+		t.Error("expected ParseRelationshipType to fail")
+	}
+}
+
+func TestRelationshipString(t *testing.T) {
+	rel := Relationship{Type: FinishToFinish, Lag: -30 * time.Minute}
+	if rel.String() != "FF-30m0s" {
+		t.Errorf("got %s, want %s", rel.String(), "FF-30m0s")
+	}
+}
+
+func TestParseConstraintType(t *testing.T) {
+	for constraintType := NoConstraint; constraintType <= AsLateAsPossible; constraintType++ {
+		parsed, err := ParseConstraintType(constraintType.String())
+		if err != nil {
+			t.Error(err)
+		}
+		if parsed != constraintType {
+			t.Errorf("got %v, want %v", parsed, constraintType)
+		}
+	}
+	if _, err := ParseConstraintType("ASAP"); err == nil {
+		t.Error("expected ParseConstraintType to fail")
+	}
+}
+
+func TestRemainingDuration(t *testing.T) {
+	tests := []struct {
+		a    Activity
+		want time.Duration
+	}{
+		{Activity{Duration: 4 * time.Hour}, 4 * time.Hour},
+		{Activity{Duration: 4 * time.Hour, Progress: 0.25}, 3 * time.Hour},
+		{Activity{Duration: 4 * time.Hour, Progress: 1}, 0},
+		{Activity{Duration: 4 * time.Hour, Progress: 0.5, ActualFinish: time.Now()}, 0},
+	}
+	for _, test := range tests {
+		if got := test.a.RemainingDuration(); got != test.want {
+			t.Errorf("got %v, want %v", got, test.want)
+		}
+	}
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/goccy/go-graphviz"
+	"github.com/vanillaiice/verano/activity"
+	"github.com/vanillaiice/verano/db"
+	"github.com/vanillaiice/verano/graph"
+	"github.com/vanillaiice/verano/parser/pmxml"
+	"github.com/vanillaiice/verano/parser/pxer"
+	"github.com/vanillaiice/verano/project"
+	"github.com/vanillaiice/verano/project/calendar"
+	"github.com/vanillaiice/verano/sorter"
+	"github.com/vanillaiice/verano/util"
+)
+
+// Layouts accepted for dates passed as flags, in local time.
+var dateLayouts = []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02", time.RFC3339}
+
+// Layout of the dates printed by the list command.
+const listDateFormat = "2006-01-02 15:04"
+
+// newFlagSet creates a flag set for the command 'name', with a flag for the database path.
+func newFlagSet(name, argsUsage string, stdout io.Writer) (fs *flag.FlagSet, dbPath *string) {
+	fs = flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: verano %s [flags] %s\n", name, argsUsage)
+		fs.PrintDefaults()
+	}
+	dbPath = fs.String("db", "verano.db", "path of the SQLite database")
+	return
+}
+
+// parseDate parses a date passed as a flag, using the first matching layout of 'dateLayouts'.
+func parseDate(s string) (t time.Time, err error) {
+	for _, layout := range dateLayouts {
+		if t, err = time.ParseInLocation(layout, s, time.Local); err == nil {
+			return
+		}
+	}
+	return t, fmt.Errorf("invalid date %q, want a date like \"2006-01-02 15:04\"", s)
+}
+
+// parsePolicy parses the name of a duplicate insert policy.
+func parsePolicy(s string) (db.DuplicateInsertPolicy, error) {
+	switch s {
+	case "none":
+		return db.None, nil
+	case "ignore":
+		return db.Ignore, nil
+	case "replace":
+		return db.Replace, nil
+	}
+	return db.None, fmt.Errorf("unknown duplicate policy %q, want none, ignore or replace", s)
+}
+
+// cmdImport imports activities, and optionally calendars, from a file into the database.
+func cmdImport(args []string, stdout io.Writer) (err error) {
+	fs, dbPath := newFlagSet("import", "FILE", stdout)
+	format := fs.String("format", "", "format of the file (csv, json, xlsx, xer, mspdi or pmxml), guessed from the extension if empty (xml for mspdi)")
+	calendarsPath := fs.String("calendars", "", "file with the calendars, in the same format as FILE (the 'calendars' sheet of FILE for xlsx)")
+	policy := fs.String("policy", "none", "policy for activities already in the database (none, ignore or replace)")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("import expects exactly one file")
+	}
+	path := fs.Arg(0)
+
+	duplicateInsertPolicy, err := parsePolicy(*policy)
+	if err != nil {
+		return
+	}
+	f, err := formatOf(path, *format)
+	if err != nil {
+		return
+	}
+	if f == "xer" || f == "pmxml" {
+		if *calendarsPath != "" {
+			return fmt.Errorf("the calendars of %s files are part of FILE, -calendars is not supported", f)
+		}
+		return importProject(path, f, *dbPath, duplicateInsertPolicy, stdout)
+	}
+	activities, err := readActivities(path, f)
+	if err != nil {
+		return
+	}
+
+	if *calendarsPath == "" && f == "xlsx" {
+		// the calendars sheet is optional in xlsx files
+		if _, err := openSheet(path, calendarsSheet, false); err == nil {
+			*calendarsPath = path
+		}
+	}
+	var calendars []*calendar.Calendar
+	if *calendarsPath != "" {
+		if calendars, err = readCalendars(*calendarsPath, f); err != nil {
+			return
+		}
+	}
+
+	sqldb, err := db.New(*dbPath)
+	if err != nil {
+		return
+	}
+	defer sqldb.DB.Close()
+
+	// the activities and calendars are imported together, or not at all
+	err = sqldb.InTx(func(tx *db.Tx) (err error) {
+		if err = tx.InsertActivities(activities, duplicateInsertPolicy); err != nil {
+			return
+		}
+		return tx.InsertCalendars(calendars, duplicateInsertPolicy)
+	})
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(stdout, "imported %d activities into %s\n", len(activities), *dbPath)
+	if *calendarsPath != "" {
+		fmt.Fprintf(stdout, "imported %d calendars into %s\n", len(calendars), *dbPath)
+	}
+
+	return
+}
+
+// importProject imports the activities and calendars of the project file (xer or pmxml) at 'path' into the database,
+// and prints the constructs of XER files that were dropped or approximated.
+func importProject(path, format, dbPath string, duplicateInsertPolicy db.DuplicateInsertPolicy, stdout io.Writer) (err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	var (
+		p        *project.Project
+		warnings []pxer.Warning
+	)
+	if format == "xer" {
+		p, warnings, err = pxer.XERToProject(f)
+	} else {
+		p, err = pmxml.PMXMLToProject(f)
+	}
+	if err != nil {
+		return
+	}
+
+	sqldb, err := db.New(dbPath)
+	if err != nil {
+		return
+	}
+	defer sqldb.DB.Close()
+
+	err = sqldb.InTx(func(tx *db.Tx) (err error) {
+		if err = tx.InsertActivities(p.Activities(), duplicateInsertPolicy); err != nil {
+			return
+		}
+		return tx.InsertCalendars(p.Calendars, duplicateInsertPolicy)
+	})
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(stdout, "imported %d activities and %d calendars of project %q into %s\n", len(p.Activities()), len(p.Calendars), p.Name, dbPath)
+	for _, w := range warnings {
+		fmt.Fprintf(stdout, "warning: %v\n", w)
+	}
+	return
+}
+
+// cmdSchedule schedules the activities of the database and stores their new times.
+func cmdSchedule(args []string, stdout io.Writer) (err error) {
+	fs, dbPath := newFlagSet("schedule", "", stdout)
+	start := fs.String("start", "", "start date of the project, like \"2006-01-02 15:04\" (required)")
+	dataDate := fs.String("data-date", "", "data date up to which progress is recorded (progress is ignored if empty)")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	if *start == "" {
+		fs.Usage()
+		return errors.New("schedule expects a start date")
+	}
+	startDate, err := parseDate(*start)
+	if err != nil {
+		return
+	}
+
+	sqldb, err := db.New(*dbPath)
+	if err != nil {
+		return
+	}
+	defer sqldb.DB.Close()
+
+	p, err := loadProject(sqldb, startDate)
+	if err != nil {
+		return
+	}
+	if *dataDate != "" {
+		if p.DataDate, err = parseDate(*dataDate); err != nil {
+			return
+		}
+	}
+
+	violations := p.Schedule()
+	err = sqldb.InTx(func(tx *db.Tx) (err error) {
+		for _, a := range p.Activities() {
+			if _, err = tx.UpdateActivity(a, a.Id); err != nil {
+				return
+			}
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	fmt.Fprintf(stdout, "scheduled %d activities, project finishes on %s\n", len(p.Activities()), p.FinishDate().Format(listDateFormat))
+	for _, path := range p.CriticalPath() {
+		fmt.Fprintf(stdout, "critical path: %s\n", util.Flat(path))
+	}
+	for _, v := range violations {
+		fmt.Fprintf(stdout, "constraint violation: %v\n", v)
+	}
+	return
+}
+
+// cmdExport exports the activities, and optionally the calendars, of the database to a file.
+func cmdExport(args []string, stdout io.Writer) (err error) {
+	fs, dbPath := newFlagSet("export", "FILE", stdout)
+	format := fs.String("format", "", "format of the file (csv, json, xlsx, xer, mspdi or pmxml), guessed from the extension if empty (xml for mspdi)")
+	calendarsPath := fs.String("calendars", "", "file to export the calendars to, in the same format as FILE (the calendars are part of FILE for xer and pmxml)")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("export expects exactly one file")
+	}
+	path := fs.Arg(0)
+
+	f, err := formatOf(path, *format)
+	if err != nil {
+		return
+	}
+	if *calendarsPath != "" && (f == "xer" || f == "pmxml") {
+		return fmt.Errorf("the calendars of %s files are part of FILE, -calendars is not supported", f)
+	}
+
+	sqldb, err := db.New(*dbPath)
+	if err != nil {
+		return
+	}
+	defer sqldb.DB.Close()
+
+	activities, err := sqldb.GetActivitiesAll()
+	if err != nil {
+		return
+	}
+	sorter.SortActivitiesById(activities)
+	if f == "xer" || f == "pmxml" {
+		return exportProject(sqldb, path, f, activities, stdout)
+	}
+	if err = writeActivities(path, f, activities); err != nil {
+		return
+	}
+	fmt.Fprintf(stdout, "exported %d activities to %s\n", len(activities), path)
+
+	if *calendarsPath != "" {
+		calendars, err := sqldb.GetCalendarsAll()
+		if err != nil {
+			return err
+		}
+		if err = writeCalendars(*calendarsPath, f, calendars); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "exported %d calendars to %s\n", len(calendars), *calendarsPath)
+	}
+
+	return
+}
+
+// exportProject exports the 'activities' and the calendars of the database to the project file (xer or pmxml) at 'path'.
+func exportProject(sqldb *db.DB, path, format string, activities []*activity.Activity, stdout io.Writer) (err error) {
+	calendars, err := sqldb.GetCalendarsAll()
+	if err != nil {
+		return
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if format == "xer" {
+		err = pxer.ActivitiesToXER(activities, calendars, f)
+	} else {
+		err = pmxml.ActivitiesToPMXML(activities, calendars, f)
+	}
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(stdout, "exported %d activities and %d calendars to %s\n", len(activities), len(calendars), path)
+	return
+}
+
+// cmdRender renders the graph of the activities of the database to an image.
+func cmdRender(args []string, stdout io.Writer) (err error) {
+	fs, dbPath := newFlagSet("render", "FILE", stdout)
+	format := fs.String("format", "", "format of the image (png, svg, jpg or dot), guessed from the extension if empty")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("render expects exactly one file")
+	}
+	path := fs.Arg(0)
+
+	imageFormat := graphviz.Format(strings.ToLower(*format))
+	if imageFormat == "" {
+		imageFormat = graphviz.Format(strings.ToLower(strings.TrimPrefix(filepath.Ext(path), ".")))
+	}
+	switch imageFormat {
+	case graphviz.PNG, graphviz.SVG, graphviz.JPG, graphviz.XDOT:
+	default:
+		return fmt.Errorf("unsupported image format %q", imageFormat)
+	}
+
+	sqldb, err := db.New(*dbPath)
+	if err != nil {
+		return
+	}
+	defer sqldb.DB.Close()
+
+	activitiesMap, err := sqldb.GetActivitiesAllMap()
+	if err != nil {
+		return
+	}
+	if err = graph.DrawAndRender(graphviz.New(), activitiesMap, imageFormat, path); err != nil {
+		return
+	}
+	fmt.Fprintf(stdout, "rendered %d activities to %s\n", len(activitiesMap), path)
+	return
+}
+
+// cmdList prints a table of the activities of the database.
+func cmdList(args []string, stdout io.Writer) (err error) {
+	fs, dbPath := newFlagSet("list", "", stdout)
+	sortBy := fs.String("sort", "id", "sort the activities by id, description, duration, start, finish or cost")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	sortFuncs := map[string]func([]*activity.Activity){
+		"id":          sorter.SortActivitiesById,
+		"description": sorter.SortActivitiesByDescription,
+		"duration":    sorter.SortActivitiesByDuration,
+		"start":       sorter.SortActivitiesByStart,
+		"finish":      sorter.SortActivitiesByFinish,
+		"cost":        sorter.SortActivitiesByCost,
+	}
+	sortFunc, ok := sortFuncs[*sortBy]
+	if !ok {
+		return fmt.Errorf("cannot sort by %q", *sortBy)
+	}
+
+	sqldb, err := db.New(*dbPath)
+	if err != nil {
+		return
+	}
+	defer sqldb.DB.Close()
+
+	activities, err := sqldb.GetActivitiesAll()
+	if err != nil {
+		return
+	}
+	sortFunc(activities)
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tDESCRIPTION\tDURATION\tSTART\tFINISH\tPREDECESSORS\tPROGRESS\tCOST")
+	for _, a := range activities {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%.0f%%\t%.2f\n",
+			a.Id,
+			a.Description,
+			a.Duration,
+			formatListDate(a.Start),
+			formatListDate(a.Finish),
+			util.Flat(a.PredecessorsId),
+			a.Progress*100,
+			a.Cost,
+		)
+	}
+	return w.Flush()
+}
+
+// formatListDate formats a date for the list command, a zero date being printed as "-".
+func formatListDate(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(listDateFormat)
+}
+
+// loadProject creates a project from the activities and calendars of the database.
+// The links recorded on one side only are rebuilt from the predecessors lists.
+func loadProject(sqldb *db.DB, startDate time.Time) (p *project.Project, err error) {
+	activities, err := sqldb.GetActivitiesAll()
+	if err != nil {
+		return
+	}
+	calendars, err := sqldb.GetCalendarsAll()
+	if err != nil {
+		return
+	}
+	project.Normalize(activities, project.FromPredecessors)
+	if p, err = project.New("", startDate, activities); err != nil {
+		return
+	}
+	p.Calendars = calendars
+	return
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tealeg/xlsx/v3"
+	"github.com/vanillaiice/verano/activity"
+	"github.com/vanillaiice/verano/parser/pcsv"
+	"github.com/vanillaiice/verano/parser/pjson"
+	"github.com/vanillaiice/verano/parser/pmspdi"
+	"github.com/vanillaiice/verano/parser/pxlsx"
+	"github.com/vanillaiice/verano/project/calendar"
+)
+
+// Names of the sheets used in xlsx files.
+const (
+	activitiesSheet = "activities"
+	calendarsSheet  = "calendars"
+)
+
+// formatOf returns the format of the file at 'path', which is 'format' if not empty,
+// or the extension of the file otherwise, xml files being read as Microsoft Project (MSPDI) files.
+func formatOf(path, format string) (string, error) {
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	format = strings.ToLower(format)
+	switch format {
+	case "csv", "json", "xlsx", "xer", "mspdi", "pmxml":
+		return format, nil
+	case "xml":
+		return "mspdi", nil
+	}
+	return "", fmt.Errorf("unsupported format %q for %s", format, path)
+}
+
+// readActivities reads the activities of the file at 'path' in the given 'format'.
+// For xlsx files, the activities are read from the 'activities' sheet, or from the first sheet if there is none.
+func readActivities(path, format string) (activities []*activity.Activity, err error) {
+	if format == "xlsx" {
+		sheet, err := openSheet(path, activitiesSheet, true)
+		if err != nil {
+			return nil, err
+		}
+		return pxlsx.XLSXToActivities(sheet)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	switch format {
+	case "json":
+		return pjson.JSONtoActivities(f)
+	case "mspdi":
+		return pmspdi.MSPDIToActivities(f)
+	}
+	return pcsv.CSVToActivities(f)
+}
+
+// readCalendars reads the calendars of the file at 'path' in the given 'format'.
+// For xlsx files, the calendars are read from the 'calendars' sheet.
+func readCalendars(path, format string) (calendars []*calendar.Calendar, err error) {
+	if format == "mspdi" {
+		return nil, errors.New("calendars are not supported in mspdi files")
+	}
+	if format == "xlsx" {
+		sheet, err := openSheet(path, calendarsSheet, false)
+		if err != nil {
+			return nil, err
+		}
+		return pxlsx.XLSXToCalendars(sheet)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if format == "json" {
+		return pjson.JSONtoCalendars(f)
+	}
+	return pcsv.CSVToCalendars(f)
+}
+
+// writeActivities writes the 'activities' to the file at 'path' in the given 'format'.
+func writeActivities(path, format string, activities []*activity.Activity) (err error) {
+	if format == "xlsx" {
+		wb := xlsx.NewFile()
+		sheet, err := wb.AddSheet(activitiesSheet)
+		if err != nil {
+			return err
+		}
+		pxlsx.ActivitiesToXLSX(activities, sheet)
+		return wb.Save(path)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	switch format {
+	case "json":
+		return pjson.ActivitiesToJSON(activities, f)
+	case "mspdi":
+		return pmspdi.ActivitiesToMSPDI(activities, f)
+	}
+	return pcsv.ActivitiesToCSV(activities, f)
+}
+
+// writeCalendars writes the 'calendars' to the file at 'path' in the given 'format'.
+func writeCalendars(path, format string, calendars []*calendar.Calendar) (err error) {
+	if format == "mspdi" {
+		return errors.New("calendars are not supported in mspdi files")
+	}
+	if format == "xlsx" {
+		wb := xlsx.NewFile()
+		sheet, err := wb.AddSheet(calendarsSheet)
+		if err != nil {
+			return err
+		}
+		pxlsx.CalendarsToXLSX(calendars, sheet)
+		return wb.Save(path)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if format == "json" {
+		return pjson.CalendarsToJSON(calendars, f)
+	}
+	return pcsv.CalendarsToCSV(calendars, f)
+}
+
+// openSheet opens the sheet named 'name' of the xlsx file at 'path',
+// falling back to the first sheet if 'firstIfMissing' is true.
+func openSheet(path, name string, firstIfMissing bool) (sheet *xlsx.Sheet, err error) {
+	wb, err := xlsx.OpenFile(path)
+	if err != nil {
+		return
+	}
+	if sheet, ok := wb.Sheet[name]; ok {
+		return sheet, nil
+	}
+	if firstIfMissing && len(wb.Sheets) > 0 {
+		return wb.Sheets[0], nil
+	}
+	return nil, fmt.Errorf("no sheet named %q in %s", name, path)
+}
@@ -0,0 +1,56 @@
+// Command verano imports, schedules, exports and renders the activities of a project stored in a SQLite database.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `usage: verano <command> [flags] [arguments]
+
+commands:
+  import    import activities (and calendars) from a CSV, JSON, XLSX, P6 XER, P6 PMXML or MS Project XML file into a database
+  schedule  schedule the activities of a database from a start date
+  export    export the activities (and calendars) of a database to a CSV, JSON, XLSX, P6 XER, P6 PMXML or MS Project XML file
+  render    render the graph of the activities of a database to an image
+  list      print a table of the activities of a database
+
+Run 'verano <command> -h' for the flags of a command.
+`
+
+// command runs a subcommand with its arguments, writing its output to 'stdout'.
+type command func(args []string, stdout io.Writer) error
+
+var commands = map[string]command{
+	"import":   cmdImport,
+	"schedule": cmdSchedule,
+	"export":   cmdExport,
+	"render":   cmdRender,
+	"list":     cmdList,
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "verano:", err)
+		os.Exit(1)
+	}
+}
+
+// run runs the subcommand named by the first of 'args'.
+func run(args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "help" {
+		fmt.Fprint(stdout, usage)
+		return nil
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+	if err := cmd(args[1:], stdout); err != nil && !errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	return nil
+}
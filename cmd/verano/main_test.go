@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var scsv = `Id,Description,Duration,Start,Finish,PredecessorsId,SuccessorsId,Cost
+1,Tip landlord,12h0s,-62135596800,-62135596800,2,3,1000000
+2,Get money,6h0s,-62135596800,-62135596800,,1,0
+3,Edge,12h0s,-62135596800,-62135596800,1,,1000
+`
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+	csvPath := filepath.Join(dir, "activities.csv")
+	if err := os.WriteFile(csvPath, []byte(scsv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	steps := [][]string{
+		{"import", "-db", dbPath, csvPath},
+		{"schedule", "-db", dbPath, "-start", "2024-01-02 08:00"},
+		{"list", "-db", dbPath, "-sort", "start"},
+		{"export", "-db", dbPath, filepath.Join(dir, "activities.json")},
+		{"export", "-db", dbPath, filepath.Join(dir, "activities.xlsx")},
+		{"export", "-db", dbPath, filepath.Join(dir, "activities.xer")},
+		{"export", "-db", dbPath, filepath.Join(dir, "activities.xml")},
+		{"export", "-db", dbPath, "-format", "pmxml", filepath.Join(dir, "pmxml.xml")},
+		{"import", "-db", filepath.Join(dir, "pmxml.db"), "-format", "pmxml", filepath.Join(dir, "pmxml.xml")},
+		{"render", "-db", dbPath, filepath.Join(dir, "graph.dot")},
+	}
+	for _, args := range steps {
+		if err := run(args, &out); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+
+	for _, want := range []string{
+		"imported 3 activities",
+		"project finishes on 2024-01-03 14:00",
+		"critical path: 2,1,3",
+		"exported 3 activities and 0 calendars",
+		"imported 3 activities and 1 calendars of project \"Verano\"",
+		"2   Get money     6h0m0s    2024-01-02 08:00  2024-01-02 14:00",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("got %s, want it to contain %q", out.String(), want)
+		}
+	}
+
+	// the exported xlsx file can be imported back
+	out.Reset()
+	if err := run([]string{"import", "-db", filepath.Join(dir, "copy.db"), filepath.Join(dir, "activities.xlsx")}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "imported 3 activities") {
+		t.Errorf("got %s, want 3 imported activities", out.String())
+	}
+}
+
+func TestRunImportXER(t *testing.T) {
+	dir := t.TempDir()
+	xerPath := filepath.Join(dir, "project.xer")
+	xer := "ERMHDR\t19.12\n" +
+		"%T\tPROJECT\n%F\tproj_id\tproj_short_name\tplan_start_date\n%R\t1\tEggs\t2024-01-02 08:00\n" +
+		"%T\tTASK\n%F\ttask_id\tproj_id\ttask_name\ttarget_drtn_hr_cnt\n%R\t10\t1\tBuy eggs\t1\n%R\t11\t1\tCook eggs\t0.5\n" +
+		"%T\tTASKPRED\n%F\ttask_pred_id\ttask_id\tpred_task_id\tpred_type\n%R\t1\t11\t10\tPR_FS\n" +
+		"%T\tRSRC\n%F\trsrc_id\n%R\t1\n%E\n"
+	if err := os.WriteFile(xerPath, []byte(xer), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := run([]string{"import", "-db", filepath.Join(dir, "test.db"), xerPath}, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"imported 2 activities and 0 calendars of project \"Eggs\"",
+		"warning: RSRC: table not supported, 1 row(s) dropped",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("got %s, want it to contain %q", out.String(), want)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	var out bytes.Buffer
+	if err := run([]string{"unknown"}, &out); err == nil {
+		t.Error("expected unknown command to fail")
+	}
+	if err := run([]string{"import", "activities.txt"}, &out); err == nil {
+		t.Error("expected unsupported format to fail")
+	}
+	if err := run([]string{"schedule", "-start", "tomorrow"}, &out); err == nil {
+		t.Error("expected invalid date to fail")
+	}
+	if err := run([]string{"schedule"}, &out); err == nil {
+		t.Error("expected missing start date to fail")
+	}
+	if err := run([]string{"import", "-calendars", "calendars.csv", "project.xer"}, &out); err == nil || !strings.Contains(err.Error(), "-calendars") {
+		t.Errorf("got %v, want calendars of a xer file to fail", err)
+	}
+	if err := run([]string{"export", "-calendars", "calendars.csv", "-format", "pmxml", "project.xml"}, &out); err == nil || !strings.Contains(err.Error(), "-calendars") {
+		t.Errorf("got %v, want calendars of a pmxml file to fail", err)
+	}
+	if err := run([]string{"list", "-h"}, &out); err != nil {
+		t.Errorf("got %v, want no error for help", err)
+	}
+}
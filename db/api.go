@@ -5,23 +5,38 @@ import (
 	"time"
 
 	"github.com/vanillaiice/verano/activity"
+	"github.com/vanillaiice/verano/project/calendar"
 	_ "modernc.org/sqlite"
 )
 
-// A DB stores a pointer to a sqlite database connection.
+// A DB stores a pointer to a sqlite database connection, and the id of the project whose activities
+// and calendars it reads and writes. The optional User and Reason are recorded in the history of the
+// changes of the activities made through it.
 type DB struct {
-	DB *sql.DB
+	DB        *sql.DB
+	ProjectId int
+	User      string
+	Reason    string
+}
+
+// A Project groups activities and calendars in the database. The ids of the activities and calendars
+// are unique within their project.
+type Project struct {
+	Id   int
+	Name string
 }
 
 // New creates a new instance of the DB type by initializing and opening a database.
 // located at the specified 'path'. It returns a pointer to the created DB and an error, if any.
 // The returned DB is ready for use, and the associated database file is opened.
 // It should be noted that the DB connection should be closed after use.
+// The returned DB is bound to the default project, see WithProject to read and write other projects.
 func New(path string) (*DB, error) {
 	var (
 		db  DB
 		err error
 	)
+	db.ProjectId = DefaultProjectId
 	db.DB, err = open(path)
 	return &db, err
 }
@@ -31,92 +46,242 @@ func Close(db *DB) error {
 	return db.DB.Close()
 }
 
+// WithProject returns a DB bound to the project with the specified id, sharing the database connection,
+// user and reason of 'db'.
+func (db *DB) WithProject(id int) *DB {
+	p := *db
+	p.ProjectId = id
+	return &p
+}
+
+// scope returns the project of 'db', read and written through its database connection.
+func (db *DB) scope() scope {
+	return scope{q: db.DB, project: db.ProjectId, user: db.User, reason: db.Reason}
+}
+
 // InsertActivity inserts the provided activity into the database.
+// Its predecessors and successors must already be in the database, as a link to a missing activity is refused:
+// activities linked to each other are inserted together with InsertActivities, or in a transaction with InTx.
 func (db *DB) InsertActivity(act *activity.Activity, duplicateInsertPolicy DuplicateInsertPolicy) (n int64, err error) {
-	return insertActivity(db.DB, act, duplicateInsertPolicy)
+	return insertActivity(db.scope(), act, duplicateInsertPolicy)
 }
 
-// InsertActivities inserts the provided activities into the database.
+// InsertActivities inserts the provided activities into the database, in a single transaction:
+// either all the activities are inserted, or none if one of them cannot be.
 func (db *DB) InsertActivities(activities []*activity.Activity, duplicateInsertPolicy DuplicateInsertPolicy) (err error) {
-	return insertActivities(db.DB, activities, duplicateInsertPolicy)
+	return insertActivities(db.scope(), activities, duplicateInsertPolicy)
 }
 
 // GetActivity retrieves the activity with the specified id from the database.
 func (db *DB) GetActivity(id int) (act *activity.Activity, err error) {
-	return getActivity(db.DB, id)
+	return getActivity(db.scope(), id)
 }
 
 // GetActivities retrieves the activities with the specified ids from the database.
 func (db *DB) GetActivities(ids []int) (activities []*activity.Activity, err error) {
-	return getActivities(db.DB, ids)
+	return getActivities(db.scope(), ids)
 }
 
 // GetActivitiesAll retrieves all activities from the database.
 // It returns a slice of pointers to activities.
 func (db *DB) GetActivitiesAll() (activities []*activity.Activity, err error) {
-	return getActivitiesAll(db.DB)
+	return getActivitiesAll(db.scope())
 }
 
 // GetActivitiesAllMap retrieves all activities from the database,
 // and returns them as a map with activity ids as keys and pointers to activities as values.
 func (db *DB) GetActivitiesAllMap() (activitiesMap map[int]*activity.Activity, err error) {
-	return getActivitiesAllMap(db.DB)
+	return getActivitiesAllMap(db.scope())
 }
 
 // UpdateActivity updates the activity with the specified id in the database
 // using the information provided in the activity.
 func (db *DB) UpdateActivity(act *activity.Activity, id int) (n int64, err error) {
-	return updateActivity(db.DB, act, id)
+	return updateActivity(db.scope(), act, id)
 }
 
-// UpdateId updates the id of an activity with the specified id in the database
+// UpdateId updates the id of an activity with the specified id in the database, its links following it.
 func (db *DB) UpdateId(oldId, newId int) (n int64, err error) {
-	return updateId(db.DB, oldId, newId)
+	return updateId(db.scope(), oldId, newId, Cascade)
+}
+
+// UpdateIdWithPolicy updates the id of an activity with the specified id in the database, its links following it
+// unless the 'referencePolicy' is Restrict, which refuses to renumber an activity linked to other activities.
+func (db *DB) UpdateIdWithPolicy(oldId, newId int, referencePolicy ReferencePolicy) (n int64, err error) {
+	return updateId(db.scope(), oldId, newId, referencePolicy)
+}
+
+// UpdateIds updates the ids of the activities from the keys of 'newIds' to their values in the database,
+// in a single transaction. Ids can be swapped, and the links follow the activities.
+// It returns the number of activities whose id changed.
+func (db *DB) UpdateIds(newIds map[int]int) (n int64, err error) {
+	return updateIds(db.scope(), newIds, Cascade)
+}
+
+// UpdateIdsWithPolicy updates the ids of the activities like UpdateIds, the links following the activities
+// unless the 'referencePolicy' is Restrict, which refuses to renumber activities linked to activities that are not renumbered.
+func (db *DB) UpdateIdsWithPolicy(newIds map[int]int, referencePolicy ReferencePolicy) (n int64, err error) {
+	return updateIds(db.scope(), newIds, referencePolicy)
 }
 
 // UpdateDescription updates the description of an activity with the specified id in the database
 func (db *DB) UpdateDescription(id int, newDescription string) (n int64, err error) {
-	return updateDescription(db.DB, id, newDescription)
+	return updateDescription(db.scope(), id, newDescription)
 }
 
 // UpdateDuration updates the duration of an activity with the specified id in the database
 func (db *DB) UpdateDuration(id int, newDuration time.Duration) (n int64, err error) {
-	return updateDuration(db.DB, id, newDuration)
+	return updateDuration(db.scope(), id, newDuration)
 }
 
 // UpdateStart updates the start time of an activity with the specified id in the database
 func (db *DB) UpdateStart(id int, newStart time.Time) (n int64, err error) {
-	return updateStart(db.DB, id, newStart)
+	return updateStart(db.scope(), id, newStart)
 }
 
 // UpdateFinish updates the finish time of an activity with the specified id in the database
 func (db *DB) UpdateFinish(id int, newFinish time.Time) (n int64, err error) {
-	return updateFinish(db.DB, id, newFinish)
+	return updateFinish(db.scope(), id, newFinish)
+}
+
+// UpdateProgress updates the progress of an activity with the specified id in the database
+func (db *DB) UpdateProgress(id int, newProgress float32) (n int64, err error) {
+	return updateProgress(db.scope(), id, newProgress)
 }
 
-// UpdateSuccessors updates the successors of the activity with the specified id in the database.
+// UpdateActualStart updates the actual start time of an activity with the specified id in the database
+func (db *DB) UpdateActualStart(id int, newActualStart time.Time) (n int64, err error) {
+	return updateActualStart(db.scope(), id, newActualStart)
+}
+
+// UpdateActualFinish updates the actual finish time of an activity with the specified id in the database
+func (db *DB) UpdateActualFinish(id int, newActualFinish time.Time) (n int64, err error) {
+	return updateActualFinish(db.scope(), id, newActualFinish)
+}
+
+// UpdateSuccessors updates the successors of the activity with the specified id in the database,
+// keeping the type and lag of its existing links. It returns the number of links added or removed.
 func (db *DB) UpdateSuccessors(id int, successorsId []int) (n int64, err error) {
-	return updateSuccessors(db.DB, id, successorsId)
+	return updateSuccessors(db.scope(), id, successorsId)
+}
+
+// UpdateCalendarId updates the calendar of an activity with the specified id in the database
+func (db *DB) UpdateCalendarId(id int, newCalendarId int) (n int64, err error) {
+	return updateCalendarId(db.scope(), id, newCalendarId)
+}
+
+// UpdateRelationships updates the relationships of the activity with the specified id with its predecessors in the database.
+// The predecessors missing from 'relationships' are linked finish to start without lag, and the relationships with
+// activities that are not predecessors are ignored. It returns the number of links to the predecessors.
+func (db *DB) UpdateRelationships(id int, relationships map[int]activity.Relationship) (n int64, err error) {
+	return updateRelationships(db.scope(), id, relationships)
 }
 
 // UpdateCost updates the cost of an activity with the specified id in the database
 func (db *DB) UpdateCost(id int, newCost float64) (n int64, err error) {
-	return updateCost(db.DB, id, newCost)
+	return updateCost(db.scope(), id, newCost)
 }
 
-// UpdatePredecessors updates the predecessors of the activity with the specified id in the database.
+// UpdatePredecessors updates the predecessors of the activity with the specified id in the database,
+// keeping the type and lag of its existing links. It returns the number of links added or removed.
 func (db *DB) UpdatePredecessors(id int, predecessorsId []int) (n int64, err error) {
-	return updatePredecessors(db.DB, id, predecessorsId)
+	return updatePredecessors(db.scope(), id, predecessorsId)
 }
 
-// DeleteActivity deletes the activity with the specified id from the database.
+// DeleteActivity deletes the activity with the specified id from the database, with its links.
 // It returns the number of affected rows and an error if the deletion operation encounters any issues.
 func (db *DB) DeleteActivity(id int) (n int64, err error) {
-	return deleteActivity(db.DB, id)
+	return deleteActivity(db.scope(), id, Cascade)
+}
+
+// DeleteActivityWithPolicy deletes the activity with the specified id from the database, with the 'referencePolicy'.
+// With the Restrict policy, an activity with links is not deleted, and with the Bridge policy, its predecessors are first
+// linked to its successors. It returns the number of affected rows and an error if the deletion operation encounters any issues.
+func (db *DB) DeleteActivityWithPolicy(id int, referencePolicy ReferencePolicy) (n int64, err error) {
+	return deleteActivity(db.scope(), id, referencePolicy)
 }
 
-// DeleteActivities deletes the activities with the specified ids from the database.
+// DeleteActivities deletes the activities with the specified ids from the database, with their links, in a single transaction.
 // It returns the number of affected rows and an error if the deletion operation encounters any issues.
 func (db *DB) DeleteActivities(ids []int) (n int64, err error) {
-	return deleteActivities(db.DB, ids)
+	return deleteActivities(db.scope(), ids, Cascade)
+}
+
+// DeleteActivitiesWithPolicy deletes the activities with the specified ids from the database in a single transaction,
+// with the 'referencePolicy'. With the Restrict policy, no activity is deleted if one of them is linked to an activity
+// that is not deleted, and with the Bridge policy, the predecessors of the deleted activities are first linked to
+// their successors. It returns the number of affected rows and an error if the deletion operation encounters any issues.
+func (db *DB) DeleteActivitiesWithPolicy(ids []int, referencePolicy ReferencePolicy) (n int64, err error) {
+	return deleteActivities(db.scope(), ids, referencePolicy)
+}
+
+// GetHistory retrieves the changes of the activity with the specified id from the database, oldest first.
+// The changes of the id of the activity from or to the specified id are included, so that a renumbered activity can be
+// followed from the history of its former id.
+func (db *DB) GetHistory(id int) (changes []*Change, err error) {
+	return getHistory(db.scope(), id)
+}
+
+// GetActivitiesAllAt retrieves all activities of the database as they were at the time 't', sorted by id.
+func (db *DB) GetActivitiesAllAt(t time.Time) (activities []*activity.Activity, err error) {
+	return getActivitiesAllAt(db.scope(), t)
+}
+
+// InsertCalendars inserts the provided calendars into the database.
+func (db *DB) InsertCalendars(calendars []*calendar.Calendar, duplicateInsertPolicy DuplicateInsertPolicy) (err error) {
+	return insertCalendars(db.scope(), calendars, duplicateInsertPolicy)
+}
+
+// GetCalendar retrieves the calendar with the specified id from the database.
+func (db *DB) GetCalendar(id int) (c *calendar.Calendar, err error) {
+	return getCalendar(db.scope(), id)
+}
+
+// GetCalendarsAll retrieves all calendars from the database.
+func (db *DB) GetCalendarsAll() (calendars []*calendar.Calendar, err error) {
+	return getCalendarsAll(db.scope())
+}
+
+// UpdateCalendar updates the calendar with the specified id in the database
+// using the information provided in the calendar.
+func (db *DB) UpdateCalendar(c *calendar.Calendar, id int) (n int64, err error) {
+	return updateCalendar(db.scope(), c, id)
+}
+
+// DeleteCalendar deletes the calendar with the specified id from the database.
+// It returns the number of affected rows and an error if the deletion operation encounters any issues.
+func (db *DB) DeleteCalendar(id int) (n int64, err error) {
+	return deleteCalendar(db.scope(), id)
+}
+
+// CreateProject creates a project with the specified name, which must be unique, in the database.
+func (db *DB) CreateProject(name string) (p *Project, err error) {
+	return createProject(db.DB, name)
+}
+
+// GetProject retrieves the project with the specified id from the database.
+func (db *DB) GetProject(id int) (p *Project, err error) {
+	return getProject(db.DB, id)
+}
+
+// GetProjectsAll retrieves all projects from the database, sorted by id.
+func (db *DB) GetProjectsAll() (projects []*Project, err error) {
+	return getProjectsAll(db.DB)
+}
+
+// UpdateProjectName updates the name of the project with the specified id in the database
+func (db *DB) UpdateProjectName(id int, newName string) (n int64, err error) {
+	return updateProjectName(db.DB, id, newName)
+}
+
+// CopyProject creates a project with the specified name holding a copy of the activities, relationships
+// and calendars of the project with the specified id, and returns the created project.
+func (db *DB) CopyProject(id int, name string) (p *Project, err error) {
+	return copyProject(db.WithProject(id).scope(), name)
+}
+
+// DeleteProject deletes the project with the specified id from the database, with its activities, relationships and calendars.
+// It returns the number of deleted projects and an error if the deletion operation encounters any issues.
+func (db *DB) DeleteProject(id int) (n int64, err error) {
+	return deleteProject(db.WithProject(id).scope())
 }
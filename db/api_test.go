@@ -1,11 +1,16 @@
 package db
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/vanillaiice/verano/activity"
+	"github.com/vanillaiice/verano/internal/activitytest"
+	"github.com/vanillaiice/verano/project/calendar"
 )
 
 var id = 1
@@ -53,6 +58,33 @@ func TestOpen(t *testing.T) {
 	sqldb.DB.Close()
 }
 
+func TestOpenInMemory(t *testing.T) {
+	sqldb, err := New(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqldb.DB.Close()
+
+	if err = sqldb.InsertActivities([]*activity.Activity{{Id: 1}, {Id: 2, PredecessorsId: []int{1}}}, None); err != nil {
+		t.Fatal(err)
+	}
+	acts, err := sqldb.GetActivitiesAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(acts) != 2 || !reflect.DeepEqual(acts[0].SuccessorsId, []int{2}) {
+		t.Errorf("got %+v, want the inserted activities", acts)
+	}
+
+	// the foreign keys are enforced once the schema is migrated
+	if _, err = sqldb.DeleteActivity(1); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := sqldb.GetActivity(2); err != nil || len(a.PredecessorsId) != 0 {
+		t.Errorf("got %+v, %v, want the link to be deleted with the activity", a, err)
+	}
+}
+
 func TestInsertActivity(t *testing.T) {
 	sqldb, err := openDB()
 	if err != nil {
@@ -172,7 +204,7 @@ func TestUpdateActivity(t *testing.T) {
 	start2 := time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)
 	finish2 := start2.Add(duration2)
 
-	n, err := sqldb.UpdateActivity(&activity.Activity{Description: descr2, Duration: duration2, Start: start2, Finish: finish2}, 1)
+	n, err := sqldb.UpdateActivity(&activity.Activity{Description: descr2, Duration: duration2, Start: start2, Finish: finish2, Progress: 0.5, ActualStart: start2}, 1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -197,6 +229,12 @@ func TestUpdateActivity(t *testing.T) {
 	if a.Finish != finish2 {
 		t.Errorf("finish: want %v, got %v", finish2, a.Finish)
 	}
+	if a.Progress != 0.5 {
+		t.Errorf("progress: want %v, got %v", 0.5, a.Progress)
+	}
+	if a.ActualStart != start2 || !a.ActualFinish.IsZero() {
+		t.Errorf("actual dates: want %v and %v, got %v and %v", start2, time.Time{}, a.ActualStart, a.ActualFinish)
+	}
 }
 
 func TestDeleteActivity(t *testing.T) {
@@ -248,16 +286,13 @@ func TestInsertActivities(t *testing.T) {
 	}
 	defer sqldb.Close()
 
-	predId := []int{1, 2, 3}
-	succId := []int{5, 6, 7}
-
 	activities := []*activity.Activity{
 		{
 			Id:             43,
 			Description:    "buy eggs",
 			Duration:       duration,
-			PredecessorsId: predId,
-			SuccessorsId:   succId,
+			PredecessorsId: []int{},
+			SuccessorsId:   []int{35},
 			Start:          start,
 			Finish:         finish,
 		},
@@ -265,8 +300,8 @@ func TestInsertActivities(t *testing.T) {
 			Id:             35,
 			Description:    "cook eggs",
 			Duration:       duration,
-			PredecessorsId: predId,
-			SuccessorsId:   succId,
+			PredecessorsId: []int{43},
+			SuccessorsId:   []int{22},
 			Start:          start,
 			Finish:         finish,
 		},
@@ -274,20 +309,551 @@ func TestInsertActivities(t *testing.T) {
 			Id:             22,
 			Description:    "eat eggs",
 			Duration:       duration,
-			PredecessorsId: predId,
-			SuccessorsId:   succId,
+			PredecessorsId: []int{35},
+			SuccessorsId:   []int{},
 			Start:          start,
 			Finish:         finish,
 		},
 	}
 
-	err = insertActivities(sqldb, activities, None)
+	s := scope{q: sqldb, project: DefaultProjectId}
+	err = insertActivities(s, activities, None)
+	if err != nil {
+		t.Error(err)
+	}
+
+	dangling := &activity.Activity{Id: 50, Description: "wash dishes", PredecessorsId: []int{22, 99}}
+	if err = insertActivities(s, []*activity.Activity{dangling}, None); err == nil {
+		t.Error("got no error for a link to a missing activity, want an error")
+	}
+	if a, err := getActivity(s, 50); err != nil || a.Description != "" {
+		t.Errorf("got %+v, %v, want the insert to be rolled back", a, err)
+	}
+
+	err = deleteDB()
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpdateRelationships(t *testing.T) {
+	sqldb, err := openDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqldb.DB.Close()
+
+	acts := []*activity.Activity{
+		{Id: 1, Description: "level concrete", Duration: duration, PredecessorsId: []int{2, 3}, Start: start, Finish: finish},
+		{Id: 2, Description: "pour concrete", Duration: duration, Start: start, Finish: finish},
+		{Id: 3, Description: "order sand", Duration: duration, Start: start, Finish: finish},
+	}
+	if err = sqldb.InsertActivities(acts, None); err != nil {
+		t.Error(err)
+	}
+
+	rels := map[int]activity.Relationship{
+		2: {Type: activity.StartToStart, Lag: time.Hour},
+		3: {Type: activity.FinishToFinish, Lag: -30 * time.Minute},
+	}
+	n, err := sqldb.UpdateRelationships(1, rels)
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 2 {
+		t.Errorf("Unexpected error, expected 2 links to be affected, got %d", n)
+	}
+
+	a, err := sqldb.GetActivity(1)
+	if err != nil {
+		t.Error(err)
+	}
+	for k, v := range rels {
+		if a.Relationship(k) != v {
+			t.Errorf("relationship: want %v, got %v", v, a.Relationship(k))
+		}
+	}
+
+	err = deleteDB()
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReferencePolicies(t *testing.T) {
+	sqldb, err := openDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteDB()
+	defer sqldb.DB.Close()
+
+	// 1 -> 2 -> 3 -> 4, and 2 -> 5
+	acts := []*activity.Activity{
+		{Id: 1},
+		{Id: 2, PredecessorsId: []int{1}},
+		{Id: 3, PredecessorsId: []int{2}},
+		{Id: 4, PredecessorsId: []int{3}},
+		{Id: 5, PredecessorsId: []int{2}},
+		{Id: 6},
+	}
+	if err = sqldb.InsertActivities(acts, None); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = sqldb.DeleteActivityWithPolicy(2, Restrict); !errors.Is(err, ErrLinked) {
+		t.Errorf("got %v, want %v", err, ErrLinked)
+	}
+	if _, err = sqldb.UpdateIdWithPolicy(2, 20, Restrict); !errors.Is(err, ErrLinked) {
+		t.Errorf("got %v, want %v", err, ErrLinked)
+	}
+	if n, err := sqldb.UpdateIdsWithPolicy(map[int]int{6: 60}, Restrict); err != nil || n != 1 {
+		t.Errorf("got %d, %v, want 1, <nil>", n, err)
+	}
+	if n, err := sqldb.DeleteActivityWithPolicy(60, Restrict); err != nil || n != 1 {
+		t.Errorf("got %d, %v, want 1, <nil>", n, err)
+	}
+
+	// the chain 2 -> 3 is bridged as a whole
+	if n, err := sqldb.DeleteActivitiesWithPolicy([]int{2, 3}, Bridge); err != nil || n != 2 {
+		t.Errorf("got %d, %v, want 2, <nil>", n, err)
+	}
+	got, err := sqldb.GetActivitiesAllMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got[1].SuccessorsId, []int{4, 5}) || !reflect.DeepEqual(got[4].PredecessorsId, []int{1}) {
+		t.Errorf("got %v and %v, want the predecessors to be linked to the successors", got[1].SuccessorsId, got[4].PredecessorsId)
+	}
+
+	if _, err = sqldb.DeleteActivity(1); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := sqldb.GetActivity(4); err != nil || len(a.PredecessorsId) != 0 {
+		t.Errorf("got %+v, %v, want the links of the deleted activity to be deleted", a, err)
+	}
+}
+
+func TestManyIds(t *testing.T) {
+	sqldb, err := openDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteDB()
+	defer sqldb.DB.Close()
+
+	// more ids than sqlite accepts bound parameters
+	const count = 40000
+	ids := make([]int, count)
+	acts := make([]*activity.Activity, count+1)
+	for i := range ids {
+		ids[i] = i + 1
+		acts[i] = &activity.Activity{Id: i + 1}
+	}
+	acts[count] = &activity.Activity{Id: count + 1}
+	if err = sqldb.InsertActivities(acts, None); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := sqldb.UpdatePredecessors(count+1, ids); err != nil || n != count {
+		t.Errorf("got %d, %v, want %d, <nil>", n, err, count)
+	}
+	if a, err := sqldb.GetActivity(count + 1); err != nil || len(a.PredecessorsId) != count {
+		t.Errorf("got %v, want %d predecessors", err, count)
+	}
+	got, err := sqldb.GetActivities(ids)
+	if err != nil || len(got) != count {
+		t.Fatalf("got %d activities, %v, want %d", len(got), err, count)
+	}
+	if got[count-1].SuccessorsId[0] != count+1 {
+		t.Errorf("got %v, want [%d]", got[count-1].SuccessorsId, count+1)
+	}
+	if _, err = sqldb.DeleteActivitiesWithPolicy(ids, Restrict); !errors.Is(err, ErrLinked) {
+		t.Errorf("got %v, want %v", err, ErrLinked)
+	}
+	if n, err := sqldb.DeleteActivitiesWithPolicy(append(ids, count+1), Restrict); err != nil || n != count+1 {
+		t.Errorf("got %d, %v, want %d, <nil>", n, err, count+1)
+	}
+}
+
+func TestRoundTripAllFields(t *testing.T) {
+	sqldb, err := openDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteDB()
+	defer sqldb.DB.Close()
+
+	want := activitytest.Full()
+	neighbours := []*activity.Activity{{Id: want.PredecessorsId[0]}, {Id: want.SuccessorsId[0]}}
+	if err = sqldb.InsertActivities(neighbours, None); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sqldb.InsertActivity(want, None); err != nil {
+		t.Fatal(err)
+	}
+	got, err := sqldb.GetActivity(want.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	activitytest.Check(t, got, want)
+
+	want.Id++
+	if err = sqldb.InsertActivities([]*activity.Activity{want}, None); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = sqldb.GetActivity(want.Id); err != nil {
+		t.Fatal(err)
+	}
+	activitytest.Check(t, got, want)
+
+	want.Description, want.ConstraintType, want.TotalFloat = "Cure concrete", activity.MustFinishOn, -time.Hour
+	if _, err = sqldb.UpdateActivity(want, want.Id); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = sqldb.GetActivity(want.Id); err != nil {
+		t.Fatal(err)
+	}
+	activitytest.Check(t, got, want)
+}
+
+func TestRelationshipsTable(t *testing.T) {
+	sqldb, err := openDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteDB()
+	defer sqldb.DB.Close()
+
+	acts := []*activity.Activity{
+		{Id: 1, Description: "dig", SuccessorsId: []int{2, 3}},
+		{Id: 2, Description: "pour", PredecessorsId: []int{1}, Relationships: map[int]activity.Relationship{1: {Type: activity.StartToStart}}},
+		{Id: 3, Description: "cure", PredecessorsId: []int{1, 2}},
+	}
+	if err = sqldb.InsertActivities(acts, None); err != nil {
+		t.Fatal(err)
+	}
+
+	var dependents []int
+	rows, err := sqldb.DB.Query(fmt.Sprintf("SELECT successorId FROM %s WHERE predecessorId = ? ORDER BY successorId", RelationshipsTableName), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		dependents = append(dependents, id)
+	}
+	rows.Close()
+	if !reflect.DeepEqual(dependents, []int{2, 3}) {
+		t.Errorf("got %v, want %v", dependents, []int{2, 3})
+	}
+
+	if _, err = sqldb.UpdatePredecessors(2, []int{}); err != nil {
+		t.Error(err)
+	}
+	if _, err = sqldb.UpdatePredecessors(2, []int{1}); err != nil {
+		t.Error(err)
+	}
+	if _, err = sqldb.UpdateSuccessors(1, []int{2, 3}); err != nil {
+		t.Error(err)
+	}
+	a, err := sqldb.GetActivity(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Relationship(1).Type != activity.FinishToStart || !reflect.DeepEqual(a.SuccessorsId, []int{3}) {
+		t.Errorf("got %+v, want a new finish to start link from 1 and the successor 3", a)
+	}
+	if _, err = sqldb.UpdateSuccessors(1, []int{4}); err == nil {
+		t.Error("got no error for a link to a missing activity, want an error")
+	}
+
+	if _, err = sqldb.UpdateId(1, 10); err != nil {
+		t.Error(err)
+	}
+	if a, err = sqldb.GetActivity(3); err != nil || !reflect.DeepEqual(a.PredecessorsId, []int{2, 10}) {
+		t.Errorf("got %v, %v, want the links to follow the new id", a.PredecessorsId, err)
+	}
+
+	if _, err = sqldb.DeleteActivity(2); err != nil {
+		t.Error(err)
+	}
+	if a, err = sqldb.GetActivity(3); err != nil || !reflect.DeepEqual(a.PredecessorsId, []int{10}) {
+		t.Errorf("got %v, %v, want the links of the deleted activity to be deleted", a.PredecessorsId, err)
+	}
+}
+
+var trickyDescriptions = []string{
+	`pour "ready-mix" concrete`,
+	"it's a \\ backslash",
+	"line one\nline two\r\n\ttabbed",
+	"béton armé 鉄筋コンクリート 🏗️",
+	"'); DROP TABLE activities; --",
+	`%q %d %s`,
+}
+
+func TestTrickyDescriptions(t *testing.T) {
+	sqldb, err := openDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteDB()
+	defer sqldb.DB.Close()
+
+	var ids []int
+	for i, descr := range trickyDescriptions {
+		act := &activity.Activity{Id: i + 1, Description: descr, Wbs: descr, Duration: duration, Start: start, Finish: finish}
+		if i%2 == 0 {
+			_, err = sqldb.InsertActivity(act, None)
+		} else {
+			err = sqldb.InsertActivities([]*activity.Activity{act}, None)
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", descr, err)
+		}
+		ids = append(ids, act.Id)
+	}
+
+	acts, err := sqldb.GetActivities(ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(acts) != len(trickyDescriptions) {
+		t.Fatalf("got %d activities, want %d", len(acts), len(trickyDescriptions))
+	}
+	for _, a := range acts {
+		want := trickyDescriptions[a.Id-1]
+		if a.Description != want || a.Wbs != want {
+			t.Errorf("got %q and %q, want %q", a.Description, a.Wbs, want)
+		}
+	}
+
+	for i, descr := range trickyDescriptions {
+		reversed := trickyDescriptions[len(trickyDescriptions)-1-i]
+		if _, err = sqldb.UpdateDescription(i+1, reversed); err != nil {
+			t.Errorf("%q: %v", reversed, err)
+		}
+		a, err := sqldb.GetActivity(i + 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Description != reversed {
+			t.Errorf("got %q, want %q", a.Description, reversed)
+		}
+
+		a.Description = descr
+		if _, err = sqldb.UpdateActivity(a, a.Id); err != nil {
+			t.Errorf("%q: %v", descr, err)
+		}
+		if a, err = sqldb.GetActivity(i + 1); err != nil {
+			t.Fatal(err)
+		}
+		if a.Description != descr {
+			t.Errorf("got %q, want %q", a.Description, descr)
+		}
+	}
+
+	n, err := sqldb.DeleteActivities(ids[:2])
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 2 {
+		t.Errorf("got %d deleted activities, want 2", n)
+	}
+	if acts, err = sqldb.GetActivitiesAll(); err != nil || len(acts) != len(ids)-2 {
+		t.Errorf("got %d activities, %v, want %d", len(acts), err, len(ids)-2)
+	}
+}
+
+func TestTrickyCalendarNames(t *testing.T) {
+	sqldb, err := openDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteDB()
+	defer sqldb.DB.Close()
+
+	for i, name := range trickyDescriptions {
+		if err = sqldb.InsertCalendars([]*calendar.Calendar{calendar.New(i+1, name)}, None); err != nil {
+			t.Fatalf("%q: %v", name, err)
+		}
+		c, err := sqldb.GetCalendar(i + 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Name != name {
+			t.Errorf("got %q, want %q", c.Name, name)
+		}
+	}
+}
+
+func TestCalendars(t *testing.T) {
+	sqldb, err := openDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqldb.DB.Close()
+
+	standard := calendar.New(1, "standard")
+	standard.Default = true
+	standard.Holidays = []time.Time{time.Date(2024, time.December, 25, 0, 0, 0, 0, time.UTC)}
+	night := &calendar.Calendar{Id: 2, Name: "night", WorkDays: []time.Weekday{time.Monday}, Shifts: []calendar.Shift{{Start: 22 * time.Hour, Finish: 24 * time.Hour}}}
+	err = sqldb.InsertCalendars([]*calendar.Calendar{standard, night}, None)
+	if err != nil {
+		t.Error(err)
+	}
+
+	c, err := sqldb.GetCalendar(1)
+	if err != nil {
+		t.Error(err)
+	}
+	if c.Name != standard.Name || !c.Default || len(c.WorkDays) != 5 || len(c.Shifts) != 2 || len(c.Holidays) != 1 || !c.Holidays[0].Equal(standard.Holidays[0]) {
+		t.Errorf("calendar: want %+v, got %+v", standard, c)
+	}
+
+	night.Name = "late night"
+	n, err := sqldb.UpdateCalendar(night, 2)
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 1 {
+		t.Errorf("Unexpected error, expected 1 row to be affected, got %d", n)
+	}
+
+	calendars, err := sqldb.GetCalendarsAll()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(calendars) != 2 || calendars[1].Name != "late night" {
+		t.Errorf("calendars: got %+v", calendars)
+	}
+
+	n, err = sqldb.DeleteCalendar(2)
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 1 {
+		t.Errorf("Unexpected error, expected 1 row to be affected, got %d", n)
+	}
+
+	_, err = sqldb.InsertActivity(&activity.Activity{Id: 1, Description: "night shift", Start: start, Finish: finish}, None)
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = sqldb.UpdateCalendarId(1, 1)
 	if err != nil {
 		t.Error(err)
 	}
+	a, err := sqldb.GetActivity(1)
+	if err != nil {
+		t.Error(err)
+	}
+	if a.CalendarId != 1 {
+		t.Errorf("calendar id: want %d, got %d", 1, a.CalendarId)
+	}
 
 	err = deleteDB()
 	if err != nil {
 		t.Error(err)
 	}
 }
+
+func TestProjects(t *testing.T) {
+	sqldb, err := openDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteDB()
+	defer sqldb.DB.Close()
+
+	projects, err := sqldb.GetProjectsAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Project{{Id: DefaultProjectId, Name: DefaultProjectName}}
+	if !reflect.DeepEqual(projects, want) {
+		t.Errorf("got %+v, want %+v", projects, want)
+	}
+
+	bridge, err := sqldb.CreateProject("bridge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sqldb.CreateProject("bridge"); err == nil {
+		t.Error("got no error for a duplicate project name, want an error")
+	}
+
+	// the same ids are used in both projects
+	acts := []*activity.Activity{
+		{Id: 1, Description: "dig", SuccessorsId: []int{2}},
+		{Id: 2, Description: "pour", PredecessorsId: []int{1}, Relationships: map[int]activity.Relationship{1: {Type: activity.StartToStart, Lag: time.Hour}}},
+	}
+	if err = sqldb.InsertActivities(acts, None); err != nil {
+		t.Fatal(err)
+	}
+	b := sqldb.WithProject(bridge.Id)
+	if err = b.InsertActivities([]*activity.Activity{{Id: 1, Description: "survey"}}, None); err != nil {
+		t.Fatal(err)
+	}
+	if err = b.InsertCalendars([]*calendar.Calendar{calendar.New(1, "standard")}, None); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := b.GetActivity(1); err != nil || a.Description != "survey" || len(a.SuccessorsId) != 0 {
+		t.Errorf("got %+v, %v, want the activity of the bridge project", a, err)
+	}
+	if calendars, err := sqldb.GetCalendarsAll(); err != nil || len(calendars) != 0 {
+		t.Errorf("got %+v, %v, want no calendars in the default project", calendars, err)
+	}
+	if _, err = b.InsertActivity(&activity.Activity{Id: 3, PredecessorsId: []int{2}}, None); err == nil {
+		t.Error("got no error for a link to an activity of another project, want an error")
+	}
+
+	if n, err := sqldb.UpdateProjectName(bridge.Id, "viaduct"); err != nil || n != 1 {
+		t.Errorf("got %d, %v, want 1, <nil>", n, err)
+	}
+
+	copied, err := sqldb.CopyProject(DefaultProjectId, "default copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := sqldb.WithProject(copied.Id).GetActivitiesAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1].Relationship(1) != acts[1].Relationship(1) || !reflect.DeepEqual(got[0].SuccessorsId, []int{2}) {
+		t.Errorf("got %+v, want a copy of %+v", got, acts)
+	}
+	if _, err = sqldb.CopyProject(42, "missing"); err == nil {
+		t.Error("got no error for copying a missing project, want an error")
+	}
+
+	// the copy is independent of the original
+	if _, err = sqldb.WithProject(copied.Id).DeleteActivity(1); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := sqldb.GetActivity(2); err != nil || !reflect.DeepEqual(a.PredecessorsId, []int{1}) {
+		t.Errorf("got %+v, %v, want the original links to be kept", a, err)
+	}
+
+	if n, err := sqldb.DeleteProject(bridge.Id); err != nil || n != 1 {
+		t.Errorf("got %d, %v, want 1, <nil>", n, err)
+	}
+	if acts, err := b.GetActivitiesAll(); err != nil || len(acts) != 0 {
+		t.Errorf("got %+v, %v, want the activities of the deleted project to be deleted", acts, err)
+	}
+	if calendars, err := b.GetCalendarsAll(); err != nil || len(calendars) != 0 {
+		t.Errorf("got %+v, %v, want the calendars of the deleted project to be deleted", calendars, err)
+	}
+
+	projects, err = sqldb.GetProjectsAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []*Project{{Id: DefaultProjectId, Name: DefaultProjectName}, {Id: copied.Id, Name: "default copy"}}
+	if !reflect.DeepEqual(projects, want) {
+		t.Errorf("got %+v, want %+v", projects, want)
+	}
+}
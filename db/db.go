@@ -2,16 +2,37 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/vanillaiice/verano/activity"
+	"github.com/vanillaiice/verano/project/calendar"
 	"github.com/vanillaiice/verano/util"
 )
 
 // TableName is the name of the table in the sqlite database.
 const TableName = "activities"
 
+// RelationshipsTableName is the name of the table holding the links between the activities in the sqlite database.
+const RelationshipsTableName = "relationships"
+
+// CalendarsTableName is the name of the table holding the calendars in the sqlite database.
+const CalendarsTableName = "calendars"
+
+// ProjectsTableName is the name of the table holding the projects in the sqlite database.
+const ProjectsTableName = "projects"
+
+// DefaultProjectId is the id of the project created with the database, to which the DB returned by New is bound.
+// The activities and calendars of databases created before the support of projects are moved to this project.
+const DefaultProjectId = 1
+
+// DefaultProjectName is the name of the project created with the database.
+const DefaultProjectName = "default"
+
 // DuplicateInsertPolicy defines the policy for handling duplicate inserts in a database.
 type DuplicateInsertPolicy int
 
@@ -22,96 +43,313 @@ const (
 	Replace DuplicateInsertPolicy = 2 // Replace duplicate inserts
 )
 
+// ReferencePolicy defines the policy for handling the links of the activities that are deleted or whose id changes.
+type ReferencePolicy int
+
+// Enumeration of available reference policies.
+const (
+	Cascade  ReferencePolicy = 0 // Delete the links of deleted activities, and update the links of renumbered activities
+	Restrict ReferencePolicy = 1 // Refuse to delete or renumber activities linked to other activities
+	Bridge   ReferencePolicy = 2 // Link the predecessors of deleted activities to their successors, renumbered activities cascading
+)
+
+// ErrLinked is returned when deleting or renumbering activities linked to other activities with the Restrict policy.
+var ErrLinked = errors.New("activity is linked to other activities")
+
+// idSet is a subquery selecting the ids of a json array bound as a single parameter, so that lists of ids
+// of any length are bound despite the limit sqlite puts on the number of bound parameters.
+const idSet = "(SELECT value FROM json_each(?))"
+
+// queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	Prepare(query string) (*sql.Stmt, error)
+}
+
+// scope is a project of the database, read and written through the database or a transaction.
+type scope struct {
+	q       queryer // *sql.DB or *sql.Tx
+	project int     // Id of the project
+	user    string  // User recorded in the history of the changes
+	reason  string  // Reason recorded in the history of the changes
+}
+
 func open(path string) (sqldb *sql.DB, err error) {
-	sqldb, err = sql.Open("sqlite", path)
-	if err != nil {
+	// foreign keys are enforced per connection, hence the pragma in the data source name
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	if sqldb, err = sql.Open("sqlite", path+sep+"_pragma=foreign_keys(1)"); err != nil {
 		return
 	}
-	stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s(id INTEGER PRIMARY KEY, description TEXT, duration REAL, predecessorsId TEXT, successorsId TEXT, start INTEGER, finish INTEGER, cost REAL)", TableName)
-	_, err = execStmt(sqldb, stmt)
+	// each connection to an in-memory database opens a distinct database
+	if strings.HasPrefix(path, ":memory:") || strings.Contains(path, "mode=memory") {
+		sqldb.SetMaxOpenConns(1)
+	}
+	if err = migrate(sqldb); err != nil {
+		sqldb.Close()
+		return nil, err
+	}
 	return
 }
 
-func insertActivity(sqldb *sql.DB, act *activity.Activity, duplicateInsertPolicy DuplicateInsertPolicy) (n int64, err error) {
-	stmt := "INSERT "
-	switch duplicateInsertPolicy {
-	case Ignore:
-		stmt += "or IGNORE "
-	case Replace:
-		stmt += "or REPLACE "
+// withTx runs 'f' in a transaction, which is committed if 'f' succeeds, and rolled back otherwise.
+func withTx(sqldb *sql.DB, f func(tx *sql.Tx) error) (err error) {
+	tx, err := sqldb.Begin()
+	if err != nil {
+		return
+	}
+	if err = f(tx); err != nil {
+		tx.Rollback()
+		return
+	}
+	return tx.Commit()
+}
+
+// withTx runs 'f' in a transaction of the project of 's', which is committed if 'f' succeeds and the links
+// of the relationships table reference existing activities, and rolled back otherwise. If 's' already reads
+// and writes through a transaction, 'f' runs in this transaction, whose links are checked when it is committed.
+func (s scope) withTx(f func(s scope) error) (err error) {
+	sqldb, ok := s.q.(*sql.DB)
+	if !ok {
+		return f(s)
 	}
+	return withTx(sqldb, func(tx *sql.Tx) (err error) {
+		s.q = tx
+		if err = f(s); err != nil {
+			return
+		}
+		return checkRelationships(tx)
+	})
+}
 
-	stmt += fmt.Sprintf(
-		"INTO %s(id, description, duration, predecessorsId, successorsId, start, finish, cost) VALUES(%d, %q, %.6f, %q, %q, %d, %d, %.6f)",
+// checkRelationships returns an error if a link of the relationships table references a missing activity.
+// It is called before committing the transactions, as the foreign keys of the links are deferred.
+func checkRelationships(q queryer) (err error) {
+	row := q.QueryRow(fmt.Sprintf(
+		"SELECT r.predecessorId, r.successorId FROM %[1]s r WHERE NOT EXISTS (SELECT 1 FROM %[2]s a WHERE a.projectId = r.projectId AND a.id = r.predecessorId) OR NOT EXISTS (SELECT 1 FROM %[2]s a WHERE a.projectId = r.projectId AND a.id = r.successorId) LIMIT 1",
+		RelationshipsTableName,
 		TableName,
-		act.Id,
-		act.Description,
-		act.Duration.Seconds(),
-		util.Flat(act.PredecessorsId),
-		util.Flat(act.SuccessorsId),
-		act.Start.Unix(),
-		act.Finish.Unix(),
-		act.Cost,
+	))
+	var predecessorId, successorId int
+	if err = row.Scan(&predecessorId, &successorId); err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return
+	}
+	return fmt.Errorf("relationship %d -> %d references a missing activity", predecessorId, successorId)
+}
+
+// writeRelationships writes the links of the activity 'act' whose id is 'id' to the relationships table.
+// The links to the predecessors hold the type and lag of the relationships of the activity, and the links
+// to the successors are added if missing, as their type and lag belong to the successors.
+// If 'exact' is true, the links to predecessors the activity no longer has are also removed, the predecessors
+// of an activity being the reference for its links.
+func writeRelationships(s scope, id int, act *activity.Activity, exact bool) (err error) {
+	if exact {
+		stmt := fmt.Sprintf("DELETE FROM %s WHERE projectId = ? AND successorId = ? AND predecessorId NOT IN %s", RelationshipsTableName, idSet)
+		if _, err = execStmt(s.q, stmt, s.project, id, idsParam(act.PredecessorsId)); err != nil {
+			return
+		}
+	}
+
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s(projectId, predecessorId, successorId, type, lag) VALUES(?, ?, ?, ?, ?) ON CONFLICT(projectId, predecessorId, successorId) DO UPDATE SET type = excluded.type, lag = excluded.lag",
+		RelationshipsTableName,
 	)
+	for _, p := range act.PredecessorsId {
+		rel := act.Relationship(p)
+		if _, err = execStmt(s.q, stmt, s.project, p, id, int(rel.Type), rel.Lag.Seconds()); err != nil {
+			return
+		}
+	}
 
-	return execStmt(sqldb, stmt)
+	stmt = fmt.Sprintf("INSERT OR IGNORE INTO %s(projectId, predecessorId, successorId) VALUES(?, ?, ?)", RelationshipsTableName)
+	for _, succ := range act.SuccessorsId {
+		if _, err = execStmt(s.q, stmt, s.project, id, succ); err != nil {
+			return
+		}
+	}
+	return
 }
 
-func insertActivities(sqldb *sql.DB, activities []*activity.Activity, duplicateInsertPolicy DuplicateInsertPolicy) (err error) {
-	s := "INSERT "
-	switch duplicateInsertPolicy {
-	case Ignore:
-		s += "or IGNORE "
-	case Replace:
-		s += "or REPLACE "
+// readRelationships sets the predecessors, successors and relationships of the 'activities' from the
+// relationships table. The predecessors and successors are sorted by id.
+func readRelationships(s scope, activities []*activity.Activity) (err error) {
+	if len(activities) == 0 {
+		return
+	}
+	byId := make(map[int]*activity.Activity, len(activities))
+	ids := make([]int, len(activities))
+	for i, act := range activities {
+		byId[act.Id] = act
+		ids[i] = act.Id
 	}
-	s += fmt.Sprintf("INTO %s(id, description, duration, predecessorsId, successorsId, start, finish, cost) VALUES(?, ?, ?, ?, ?, ?, ?, ?)", TableName)
 
-	stmt, err := sqldb.Prepare(s)
+	stmt := fmt.Sprintf(
+		"SELECT predecessorId, successorId, type, lag FROM %[1]s WHERE projectId = ? AND (predecessorId IN %[2]s OR successorId IN %[2]s) ORDER BY predecessorId, successorId",
+		RelationshipsTableName,
+		idSet,
+	)
+	rows, err := s.q.Query(stmt, s.project, idsParam(ids), idsParam(ids))
 	if err != nil {
 		return
 	}
-	defer stmt.Close()
-
-	for _, a := range activities {
-		_, err = stmt.Exec(
-			a.Id,
-			a.Description,
-			a.Duration.Seconds(),
-			util.Flat(a.PredecessorsId),
-			util.Flat(a.SuccessorsId),
-			a.Start.Unix(),
-			a.Finish.Unix(),
-			a.Cost,
-		)
-		if err != nil {
+	defer rows.Close()
+
+	for rows.Next() {
+		var predecessorId, successorId, relType int
+		var lag float64
+		if err = rows.Scan(&predecessorId, &successorId, &relType, &lag); err != nil {
 			return
 		}
+		if p, ok := byId[predecessorId]; ok {
+			p.SuccessorsId = append(p.SuccessorsId, successorId)
+		}
+		if succ, ok := byId[successorId]; ok {
+			succ.PredecessorsId = append(succ.PredecessorsId, predecessorId)
+			rel := activity.Relationship{Type: activity.RelationshipType(relType), Lag: time.Duration(lag * float64(time.Second))}
+			if rel != (activity.Relationship{}) {
+				if succ.Relationships == nil {
+					succ.Relationships = make(map[int]activity.Relationship)
+				}
+				succ.Relationships[predecessorId] = rel
+			}
+		}
 	}
+	return rows.Err()
+}
 
+// insertActivityStmt returns the statement inserting an activity of a project with the 'duplicateInsertPolicy',
+// whose bound parameters are the id of the project followed by the values of activityValues.
+// Duplicates are replaced with an upsert rather than with INSERT OR REPLACE, which would delete
+// the links of the replaced activity.
+func insertActivityStmt(duplicateInsertPolicy DuplicateInsertPolicy) string {
+	stmt := "INSERT "
+	if duplicateInsertPolicy == Ignore {
+		stmt += "OR IGNORE "
+	}
+	stmt += fmt.Sprintf("INTO %s(projectId, %s) VALUES(%s)", TableName, activityColumns, placeholders(activityColumnsCount+1))
+	if duplicateInsertPolicy == Replace {
+		var set []string
+		for _, c := range strings.Split(activityColumns, ", ")[1:] {
+			set = append(set, fmt.Sprintf("%[1]s = excluded.%[1]s", c))
+		}
+		stmt += " ON CONFLICT(projectId, id) DO UPDATE SET " + strings.Join(set, ", ")
+	}
+	return stmt
+}
+
+func insertActivity(s scope, act *activity.Activity, duplicateInsertPolicy DuplicateInsertPolicy) (n int64, err error) {
+	err = s.withTx(func(s scope) error {
+		return recordChanges(s, []int{act.Id}, linkedIds(act), nil, func() (err error) {
+			if n, err = execStmt(s.q, insertActivityStmt(duplicateInsertPolicy), append([]any{s.project}, activityValues(act)...)...); err != nil || n == 0 {
+				return
+			}
+			return writeRelationships(s, act.Id, act, duplicateInsertPolicy == Replace)
+		})
+	})
 	return
 }
 
-func getActivity(sqldb *sql.DB, id int) (act *activity.Activity, err error) {
-	stmt, err := sqldb.Prepare(fmt.Sprintf("SELECT description, duration, predecessorsId, successorsId, start, finish, cost FROM %s WHERE id = ?", TableName))
-	if err != nil {
-		return
+func insertActivities(s scope, activities []*activity.Activity, duplicateInsertPolicy DuplicateInsertPolicy) (err error) {
+	ids := make([]int, len(activities))
+	for i, a := range activities {
+		ids[i] = a.Id
 	}
-	defer stmt.Close()
+	return s.withTx(func(s scope) error {
+		return recordChanges(s, ids, linkedIds(activities...), nil, func() (err error) {
+			stmt, err := s.q.Prepare(insertActivityStmt(duplicateInsertPolicy))
+			if err != nil {
+				return
+			}
+			defer stmt.Close()
+
+			for _, a := range activities {
+				res, err := stmt.Exec(append([]any{s.project}, activityValues(a)...)...)
+				if err != nil {
+					return err
+				}
+				if n, err := res.RowsAffected(); err != nil || n == 0 {
+					if err != nil {
+						return err
+					}
+					continue
+				}
+				if err = writeRelationships(s, a.Id, a, duplicateInsertPolicy == Replace); err != nil {
+					return err
+				}
+			}
+			return
+		})
+	})
+}
 
-	var description, predecessorsId, successorsId string
-	var duration, cost float64
-	var start, finish int64
-	err = stmt.QueryRow(id).Scan(&description, &duration, &predecessorsId, &successorsId, &start, &finish, &cost)
-	if err != nil && err != sql.ErrNoRows {
-		return
+// activityColumns lists the columns of the activities table, in the order expected by scanActivity.
+const activityColumns = "id, description, wbs, duration, calendarId, start, finish, progress, actualStart, actualFinish, cost, lateStart, lateFinish, totalFloat, freeFloat, constraintType, constraintDate, code"
+
+// activityColumnsCount is the number of columns listed in activityColumns.
+var activityColumnsCount = strings.Count(activityColumns, ",") + 1
+
+// activityValues returns the values of the columns listed in activityColumns for the activity 'act'.
+func activityValues(act *activity.Activity) []any {
+	return []any{
+		act.Id,
+		act.Description,
+		act.Wbs,
+		act.Duration.Seconds(),
+		act.CalendarId,
+		act.Start.Unix(),
+		act.Finish.Unix(),
+		act.Progress,
+		act.ActualStart.Unix(),
+		act.ActualFinish.Unix(),
+		act.Cost,
+		act.LateStart.Unix(),
+		act.LateFinish.Unix(),
+		act.TotalFloat.Seconds(),
+		act.FreeFloat.Seconds(),
+		int(act.ConstraintType),
+		act.ConstraintDate.Unix(),
+		act.Code,
 	}
+}
 
-	pIds, err := util.Unflat(predecessorsId)
-	if err != nil {
-		return
+// placeholders returns 'n' bound parameters separated by commas (e.g. "?, ?, ?").
+func placeholders(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strings.Repeat("?, ", n-1) + "?"
+}
+
+// idsParam returns the 'ids' as a json array, to be bound to the parameter of idSet.
+func idsParam(ids []int) string {
+	if len(ids) == 0 {
+		// a null json value would be selected as a single null id
+		return "[]"
 	}
-	sIds, err := util.Unflat(successorsId)
+	b, _ := json.Marshal(ids)
+	return string(b)
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanActivity scans a row with the columns listed in activityColumns into an activity,
+// without its links, which are read by readRelationships.
+func scanActivity(row scanner) (act *activity.Activity, err error) {
+	var description, wbs, code string
+	var duration, cost, totalFloat, freeFloat float64
+	var progress float32
+	var start, finish, actualStart, actualFinish, lateStart, lateFinish, constraintDate int64
+	var id, calendarId, constraintType int
+	err = row.Scan(&id, &description, &wbs, &duration, &calendarId, &start, &finish, &progress, &actualStart, &actualFinish, &cost, &lateStart, &lateFinish, &totalFloat, &freeFloat, &constraintType, &constraintDate, &code)
 	if err != nil {
 		return
 	}
@@ -119,253 +357,537 @@ func getActivity(sqldb *sql.DB, id int) (act *activity.Activity, err error) {
 	act = &activity.Activity{
 		Id:             id,
 		Description:    description,
+		Wbs:            wbs,
 		Duration:       time.Duration(duration * float64(time.Second)),
-		PredecessorsId: pIds,
-		SuccessorsId:   sIds,
+		CalendarId:     calendarId,
 		Start:          time.Unix(start, 0),
 		Finish:         time.Unix(finish, 0),
+		LateStart:      time.Unix(lateStart, 0),
+		LateFinish:     time.Unix(lateFinish, 0),
+		TotalFloat:     time.Duration(totalFloat * float64(time.Second)),
+		FreeFloat:      time.Duration(freeFloat * float64(time.Second)),
+		ConstraintType: activity.ConstraintType(constraintType),
+		ConstraintDate: time.Unix(constraintDate, 0),
+		Progress:       progress,
+		ActualStart:    time.Unix(actualStart, 0),
+		ActualFinish:   time.Unix(actualFinish, 0),
 		Cost:           cost,
+		Code:           code,
 	}
 
 	return
 }
 
-func getActivities(sqldb *sql.DB, ids []int) (activities []*activity.Activity, err error) {
-	stmt := fmt.Sprintf("SELECT * FROM %s WHERE id IN (%s)", TableName, util.Flat(ids))
-	rows, err := sqldb.Query(stmt)
+// queryActivities returns the activities of the project of 's' selected by the condition 'where'
+// with the values 'args' of its bound parameters, with their links.
+func queryActivities(s scope, where string, args ...any) (activities []*activity.Activity, err error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE projectId = ?", activityColumns, TableName)
+	if where != "" {
+		stmt += " AND " + where
+	}
+	rows, err := s.q.Query(stmt, append([]any{s.project}, args...)...)
 	if err != nil {
 		return
 	}
 	defer rows.Close()
 
-	var description, predecessorsId, successorsId string
-	var duration, cost float64
-	var start, finish int64
-	var id int
 	for rows.Next() {
-		err = rows.Scan(&id, &description, &duration, &predecessorsId, &successorsId, &start, &finish, &cost)
+		act, err := scanActivity(rows)
 		if err != nil {
-			return
+			return activities, err
 		}
+		activities = append(activities, act)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	rows.Close()
 
-		pIds, err := util.Unflat(predecessorsId)
-		if err != nil {
-			return activities, err
+	return activities, readRelationships(s, activities)
+}
+
+func getActivity(s scope, id int) (act *activity.Activity, err error) {
+	activities, err := queryActivities(s, "id = ?", id)
+	if err != nil {
+		return
+	}
+	if len(activities) == 0 {
+		return &activity.Activity{Id: id}, nil
+	}
+	return activities[0], nil
+}
+
+func getActivities(s scope, ids []int) (activities []*activity.Activity, err error) {
+	return queryActivities(s, "id IN "+idSet, idsParam(ids))
+}
+
+func getActivitiesAll(s scope) (activities []*activity.Activity, err error) {
+	return queryActivities(s, "")
+}
+
+func getActivitiesAllMap(s scope) (activitiesMap map[int]*activity.Activity, err error) {
+	activities, err := getActivitiesAll(s)
+	if err != nil {
+		return
+	}
+	return util.ActivitiesToMap(activities), nil
+}
+
+func updateActivity(s scope, act *activity.Activity, id int) (n int64, err error) {
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET description = ?, wbs = ?, duration = ?, calendarId = ?, start = ?, finish = ?, progress = ?, actualStart = ?, actualFinish = ?, cost = ?, lateStart = ?, lateFinish = ?, totalFloat = ?, freeFloat = ?, constraintType = ?, constraintDate = ?, code = ? WHERE projectId = ? AND id = ?",
+		TableName,
+	)
+	err = s.withTx(func(s scope) error {
+		return recordChanges(s, []int{id}, linkedIds(act), nil, func() (err error) {
+			if n, err = execStmt(s.q, stmt, append(activityValues(act)[1:], s.project, id)...); err != nil || n == 0 {
+				return
+			}
+			return writeRelationships(s, id, act, true)
+		})
+	})
+	return
+}
+
+// updateColumn sets the column 'column' of the activity 'id' to 'value'.
+func updateColumn(s scope, id int, column string, value any) (n int64, err error) {
+	stmt := fmt.Sprintf("UPDATE %s SET %s = ? WHERE projectId = ? AND id = ?", TableName, column)
+	err = s.withTx(func(s scope) error {
+		return recordChanges(s, []int{id}, nil, nil, func() (err error) {
+			n, err = execStmt(s.q, stmt, value, s.project, id)
+			return
+		})
+	})
+	return
+}
+
+// updateId changes the id of the activity 'oldId' to 'newId', with the 'referencePolicy'.
+func updateId(s scope, oldId, newId int, referencePolicy ReferencePolicy) (n int64, err error) {
+	return updateIds(s, map[int]int{oldId: newId}, referencePolicy)
+}
+
+// updateIds changes the ids of the activities from the keys of 'newIds' to their values, their links following them
+// unless the 'referencePolicy' is Restrict, which refuses to change the ids of activities linked to other activities.
+// The activities are first moved to temporary ids above the ids of the project, so that ids can be swapped.
+// It returns the number of activities whose id changed.
+func updateIds(s scope, newIds map[int]int, referencePolicy ReferencePolicy) (n int64, err error) {
+	oldIds := make([]int, 0, len(newIds))
+	for oldId := range newIds {
+		oldIds = append(oldIds, oldId)
+	}
+	slices.Sort(oldIds)
+
+	err = s.withTx(func(s scope) (err error) {
+		if referencePolicy == Restrict {
+			if err = checkUnlinked(s, oldIds); err != nil {
+				return
+			}
 		}
-		sIds, err := util.Unflat(successorsId)
-		if err != nil {
-			return activities, err
+		var maxId int
+		row := s.q.QueryRow(fmt.Sprintf("SELECT IFNULL(MAX(id), 0) FROM %s WHERE projectId = ?", TableName), s.project)
+		if err = row.Scan(&maxId); err != nil {
+			return
+		}
+		for _, newId := range newIds {
+			maxId = max(maxId, newId)
 		}
 
-		activities = append(activities, &activity.Activity{
-			Id:             id,
-			Description:    description,
-			Duration:       time.Duration(duration * float64(time.Second)),
-			PredecessorsId: pIds,
-			SuccessorsId:   sIds,
-			Start:          time.Unix(start, 0),
-			Finish:         time.Unix(finish, 0),
-			Cost:           cost,
+		stmt := fmt.Sprintf("UPDATE %s SET id = ? WHERE projectId = ? AND id = ?", TableName)
+		return recordChanges(s, oldIds, nil, newIds, func() (err error) {
+			for i, oldId := range oldIds {
+				if _, err = execStmt(s.q, stmt, maxId+1+i, s.project, oldId); err != nil {
+					return
+				}
+			}
+			for i, oldId := range oldIds {
+				changed, err := execStmt(s.q, stmt, newIds[oldId], s.project, maxId+1+i)
+				if err != nil {
+					return err
+				}
+				n += changed
+			}
+			return
 		})
-	}
+	})
+	return
+}
 
+func updateDescription(s scope, id int, newDescription string) (n int64, err error) {
+	return updateColumn(s, id, "description", newDescription)
+}
+
+func updateDuration(s scope, id int, newDuration time.Duration) (n int64, err error) {
+	return updateColumn(s, id, "duration", newDuration.Seconds())
+}
+
+func updateStart(s scope, id int, newStart time.Time) (n int64, err error) {
+	return updateColumn(s, id, "start", newStart.Unix())
+}
+
+func updateFinish(s scope, id int, newFinish time.Time) (n int64, err error) {
+	return updateColumn(s, id, "finish", newFinish.Unix())
+}
+
+func updateProgress(s scope, id int, newProgress float32) (n int64, err error) {
+	return updateColumn(s, id, "progress", newProgress)
+}
+
+func updateActualStart(s scope, id int, newActualStart time.Time) (n int64, err error) {
+	return updateColumn(s, id, "actualStart", newActualStart.Unix())
+}
+
+func updateActualFinish(s scope, id int, newActualFinish time.Time) (n int64, err error) {
+	return updateColumn(s, id, "actualFinish", newActualFinish.Unix())
+}
+
+// updatePredecessors replaces the links to the predecessors of the activity 'id' with links to 'newPredecessorsId',
+// keeping the type and lag of the links to the predecessors the activity already had.
+// It returns the number of links added or removed.
+func updatePredecessors(s scope, id int, newPredecessorsId []int) (n int64, err error) {
+	return replaceLinks(s, "successorId", "predecessorId", id, newPredecessorsId)
+}
+
+// updateSuccessors replaces the links to the successors of the activity 'id' with links to 'newSuccessorsId',
+// keeping the type and lag of the links to the successors the activity already had.
+// It returns the number of links added or removed.
+func updateSuccessors(s scope, id int, newSuccessorsId []int) (n int64, err error) {
+	return replaceLinks(s, "predecessorId", "successorId", id, newSuccessorsId)
+}
+
+// replaceLinks replaces the links whose column 'column' is 'id' with links to the 'others', in the column 'other'.
+func replaceLinks(s scope, column, other string, id int, others []int) (n int64, err error) {
+	err = s.withTx(func(s scope) error {
+		return recordChanges(s, []int{id}, others, nil, func() (err error) {
+			stmt := fmt.Sprintf("DELETE FROM %s WHERE projectId = ? AND %s = ? AND %s NOT IN %s", RelationshipsTableName, column, other, idSet)
+			if n, err = execStmt(s.q, stmt, s.project, id, idsParam(others)); err != nil {
+				return
+			}
+			stmt = fmt.Sprintf("INSERT OR IGNORE INTO %s(projectId, %s, %s) VALUES(?, ?, ?)", RelationshipsTableName, column, other)
+			for _, o := range others {
+				added, err := execStmt(s.q, stmt, s.project, id, o)
+				if err != nil {
+					return err
+				}
+				n += added
+			}
+			return
+		})
+	})
 	return
 }
 
-func getActivitiesAll(sqldb *sql.DB) (activities []*activity.Activity, err error) {
-	stmt := fmt.Sprintf("SELECT * FROM %s", TableName)
-	rows, err := sqldb.Query(stmt)
-	if err != nil {
+func updateCalendarId(s scope, id int, newCalendarId int) (n int64, err error) {
+	return updateColumn(s, id, "calendarId", newCalendarId)
+}
+
+// updateRelationships sets the type and lag of the links to the predecessors of the activity 'id'
+// from 'newRelationships', the links missing from 'newRelationships' being finish to start links
+// without lag. It returns the number of links of the activity to its predecessors.
+func updateRelationships(s scope, id int, newRelationships map[int]activity.Relationship) (n int64, err error) {
+	err = s.withTx(func(s scope) error {
+		return recordChanges(s, []int{id}, nil, nil, func() (err error) {
+			stmt := fmt.Sprintf("UPDATE %s SET type = 0, lag = 0 WHERE projectId = ? AND successorId = ?", RelationshipsTableName)
+			if n, err = execStmt(s.q, stmt, s.project, id); err != nil {
+				return
+			}
+			stmt = fmt.Sprintf("UPDATE %s SET type = ?, lag = ? WHERE projectId = ? AND predecessorId = ? AND successorId = ?", RelationshipsTableName)
+			for p, rel := range newRelationships {
+				if _, err = execStmt(s.q, stmt, int(rel.Type), rel.Lag.Seconds(), s.project, p, id); err != nil {
+					return
+				}
+			}
+			return
+		})
+	})
+	return
+}
+
+func updateCost(s scope, id int, newCost float64) (n int64, err error) {
+	return updateColumn(s, id, "cost", newCost)
+}
+
+func deleteActivity(s scope, id int, referencePolicy ReferencePolicy) (n int64, err error) {
+	return deleteActivities(s, []int{id}, referencePolicy)
+}
+
+// deleteActivities deletes the activities 'ids' with the 'referencePolicy'. Their links are deleted with them,
+// after linking their predecessors to their successors if the policy is Bridge.
+func deleteActivities(s scope, ids []int, referencePolicy ReferencePolicy) (n int64, err error) {
+	err = s.withTx(func(s scope) error {
+		// the links bridging the deleted activities are recorded with their deletion
+		return recordChanges(s, ids, nil, nil, func() (err error) {
+			switch referencePolicy {
+			case Restrict:
+				if err = checkUnlinked(s, ids); err != nil {
+					return
+				}
+			case Bridge:
+				// the activities are bridged one after the other, so that chains of deleted activities are bridged too
+				for _, id := range ids {
+					if err = bridgeLinks(s, id); err != nil {
+						return
+					}
+				}
+			}
+			stmt := fmt.Sprintf("DELETE FROM %s WHERE projectId = ? AND id IN %s", TableName, idSet)
+			n, err = execStmt(s.q, stmt, s.project, idsParam(ids))
+			return
+		})
+	})
+	return
+}
+
+// checkUnlinked returns an error wrapping ErrLinked if one of the activities 'ids' is linked to an activity
+// that is not one of the 'ids'.
+func checkUnlinked(s scope, ids []int) (err error) {
+	stmt := fmt.Sprintf(
+		"SELECT predecessorId, successorId FROM %[1]s WHERE projectId = ? AND ((predecessorId IN %[2]s AND successorId NOT IN %[2]s) OR (successorId IN %[2]s AND predecessorId NOT IN %[2]s)) LIMIT 1",
+		RelationshipsTableName,
+		idSet,
+	)
+	args := []any{s.project}
+	for i := 0; i < 4; i++ {
+		args = append(args, idsParam(ids))
+	}
+	var predecessorId, successorId int
+	if err = s.q.QueryRow(stmt, args...).Scan(&predecessorId, &successorId); err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
 		return
 	}
-	defer rows.Close()
+	return fmt.Errorf("%w: relationship %d -> %d", ErrLinked, predecessorId, successorId)
+}
 
-	var description, predecessorsId, successorsId string
-	var duration, cost float64
-	var start, finish int64
-	var id int
-	for rows.Next() {
-		err = rows.Scan(&id, &description, &duration, &predecessorsId, &successorsId, &start, &finish, &cost)
-		if err != nil {
-			return activities, err
-		}
+// bridgeLinks links the predecessors of the activity 'id' to its successors, finish to start without lag.
+// The predecessors and successors already linked keep their relationship.
+func bridgeLinks(s scope, id int) (err error) {
+	stmt := fmt.Sprintf(
+		"INSERT OR IGNORE INTO %[1]s(projectId, predecessorId, successorId) SELECT p.projectId, p.predecessorId, s.successorId FROM %[1]s p JOIN %[1]s s ON s.projectId = p.projectId AND s.predecessorId = p.successorId WHERE p.projectId = ? AND p.successorId = ? AND p.predecessorId != s.successorId",
+		RelationshipsTableName,
+	)
+	_, err = execStmt(s.q, stmt, s.project, id)
+	return
+}
+
+func insertCalendars(s scope, calendars []*calendar.Calendar, duplicateInsertPolicy DuplicateInsertPolicy) (err error) {
+	query := "INSERT "
+	switch duplicateInsertPolicy {
+	case Ignore:
+		query += "or IGNORE "
+	case Replace:
+		query += "or REPLACE "
+	}
+	query += fmt.Sprintf("INTO %s(projectId, id, name, isDefault, workDays, shifts, holidays) VALUES(?, ?, ?, ?, ?, ?, ?)", CalendarsTableName)
 
-		pIds, err := util.Unflat(predecessorsId)
+	return s.withTx(func(s scope) (err error) {
+		stmt, err := s.q.Prepare(query)
 		if err != nil {
-			return activities, err
+			return
 		}
-		sIds, err := util.Unflat(successorsId)
-		if err != nil {
-			return activities, err
+		defer stmt.Close()
+
+		for _, c := range calendars {
+			_, err = stmt.Exec(
+				s.project,
+				c.Id,
+				c.Name,
+				c.Default,
+				calendar.FormatWorkDays(c.WorkDays),
+				calendar.FormatShifts(c.Shifts),
+				calendar.FormatHolidays(c.Holidays),
+			)
+			if err != nil {
+				return
+			}
 		}
+		return
+	})
+}
 
-		activities = append(
-			activities,
-			&activity.Activity{
-				Id:             id,
-				Description:    description,
-				Duration:       time.Duration(duration * float64(time.Second)),
-				PredecessorsId: pIds,
-				SuccessorsId:   sIds,
-				Start:          time.Unix(start, 0),
-				Finish:         time.Unix(finish, 0),
-				Cost:           cost,
-			})
+// scanCalendar scans a row with the columns of the calendars table into a calendar.
+func scanCalendar(row scanner) (c *calendar.Calendar, err error) {
+	var name, workDays, shifts, holidays string
+	var id int
+	var isDefault bool
+	if err = row.Scan(&id, &name, &isDefault, &workDays, &shifts, &holidays); err != nil {
+		return
 	}
 
+	c = &calendar.Calendar{Id: id, Name: name, Default: isDefault}
+	if c.WorkDays, err = calendar.ParseWorkDays(workDays); err != nil {
+		return
+	}
+	if c.Shifts, err = calendar.ParseShifts(shifts); err != nil {
+		return
+	}
+	c.Holidays, err = calendar.ParseHolidays(holidays)
 	return
 }
 
-func getActivitiesAllMap(sqldb *sql.DB) (activitiesMap map[int]*activity.Activity, err error) {
-	stmt := fmt.Sprintf("SELECT * FROM %s", TableName)
-	rows, err := sqldb.Query(stmt)
+func getCalendar(s scope, id int) (c *calendar.Calendar, err error) {
+	row := s.q.QueryRow(fmt.Sprintf("SELECT id, name, isDefault, workDays, shifts, holidays FROM %s WHERE projectId = ? AND id = ?", CalendarsTableName), s.project, id)
+	return scanCalendar(row)
+}
+
+func getCalendarsAll(s scope) (calendars []*calendar.Calendar, err error) {
+	rows, err := s.q.Query(fmt.Sprintf("SELECT id, name, isDefault, workDays, shifts, holidays FROM %s WHERE projectId = ?", CalendarsTableName), s.project)
 	if err != nil {
 		return
 	}
 	defer rows.Close()
 
-	var description, predecessorsId, successorsId string
-	var duration, cost float64
-	var start, finish int64
-	var id int
 	for rows.Next() {
-		err = rows.Scan(&id, &description, &duration, &predecessorsId, &successorsId, &start, &finish)
-		if err != nil {
-			return activitiesMap, err
-		}
-
-		pIds, err := util.Unflat(predecessorsId)
-		if err != nil {
-			return activitiesMap, err
-		}
-		sIds, err := util.Unflat(successorsId)
+		c, err := scanCalendar(rows)
 		if err != nil {
-			return activitiesMap, err
-		}
-
-		activitiesMap[id] = &activity.Activity{
-			Id:             id,
-			Description:    description,
-			Duration:       time.Duration(duration * float64(time.Second)),
-			PredecessorsId: pIds,
-			SuccessorsId:   sIds,
-			Start:          time.Unix(start, 0),
-			Finish:         time.Unix(finish, 0),
-			Cost:           cost,
+			return calendars, err
 		}
+		calendars = append(calendars, c)
 	}
 
-	return
+	return calendars, rows.Err()
 }
 
-func updateActivity(sqldb *sql.DB, act *activity.Activity, id int) (n int64, err error) {
-	stmt := fmt.Sprintf(
-		"UPDATE %s SET description = %q, duration = %.6f, predecessorsId=%q, successorsId=%q, start = %d, finish = %d, cost = %.6f WHERE id = %d",
-		TableName,
-		act.Description,
-		act.Duration.Seconds(),
-		util.Flat(act.PredecessorsId),
-		util.Flat(act.SuccessorsId),
-		act.Start.Unix(),
-		act.Finish.Unix(),
-		act.Cost,
+func updateCalendar(s scope, c *calendar.Calendar, id int) (n int64, err error) {
+	return execStmt(
+		s.q,
+		fmt.Sprintf("UPDATE %s SET name = ?, isDefault = ?, workDays = ?, shifts = ?, holidays = ? WHERE projectId = ? AND id = ?", CalendarsTableName),
+		c.Name,
+		c.Default,
+		calendar.FormatWorkDays(c.WorkDays),
+		calendar.FormatShifts(c.Shifts),
+		calendar.FormatHolidays(c.Holidays),
+		s.project,
 		id,
 	)
-	return execStmt(sqldb, stmt)
 }
 
-func updateId(sqldb *sql.DB, oldId, newId int) (n int64, err error) {
-	stmt := fmt.Sprintf(
-		"UPDATE %s SET id=%d WHERE id=%d",
-		TableName,
-		newId,
-		oldId,
-	)
-	return execStmt(sqldb, stmt)
+func deleteCalendar(s scope, id int) (n int64, err error) {
+	return execStmt(s.q, fmt.Sprintf("DELETE FROM %s WHERE projectId = ? AND id = ?", CalendarsTableName), s.project, id)
 }
 
-func updateDescription(sqldb *sql.DB, id int, newDescription string) (n int64, err error) {
-	stmt := fmt.Sprintf(
-		"UPDATE %s SET description=%q WHERE id=%d",
-		TableName,
-		newDescription,
-		id,
-	)
-	return execStmt(sqldb, stmt)
+// scanProject scans a row with the columns of the projects table into a project.
+func scanProject(row scanner) (p *Project, err error) {
+	p = &Project{}
+	if err = row.Scan(&p.Id, &p.Name); err != nil {
+		return nil, err
+	}
+	return
 }
 
-func updateDuration(sqldb *sql.DB, id int, newDuration time.Duration) (n int64, err error) {
-	stmt := fmt.Sprintf(
-		"UPDATE %s SET duration=%.6f WHERE id=%d",
-		TableName,
-		newDuration.Seconds(),
-		id,
-	)
-	return execStmt(sqldb, stmt)
+func createProject(q queryer, name string) (p *Project, err error) {
+	res, err := q.Exec(fmt.Sprintf("INSERT INTO %s(name) VALUES(?)", ProjectsTableName), name)
+	if err != nil {
+		return
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return
+	}
+	return &Project{Id: int(id), Name: name}, nil
 }
 
-func updateStart(sqldb *sql.DB, id int, newStart time.Time) (n int64, err error) {
-	stmt := fmt.Sprintf(
-		"UPDATE %s SET start=%d WHERE id=%d",
-		TableName,
-		newStart.Unix(),
-		id,
-	)
-	return execStmt(sqldb, stmt)
+func getProject(q queryer, id int) (p *Project, err error) {
+	row := q.QueryRow(fmt.Sprintf("SELECT id, name FROM %s WHERE id = ?", ProjectsTableName), id)
+	return scanProject(row)
 }
 
-func updateFinish(sqldb *sql.DB, id int, newFinish time.Time) (n int64, err error) {
-	stmt := fmt.Sprintf(
-		"UPDATE %s SET finish=%d WHERE id=%d",
-		TableName,
-		newFinish.Unix(),
-		id,
-	)
-	return execStmt(sqldb, stmt)
-}
+func getProjectsAll(q queryer) (projects []*Project, err error) {
+	rows, err := q.Query(fmt.Sprintf("SELECT id, name FROM %s ORDER BY id", ProjectsTableName))
+	if err != nil {
+		return
+	}
+	defer rows.Close()
 
-func updatePredecessors(sqldb *sql.DB, id int, newPredecessorsId []int) (n int64, err error) {
-	stmt := fmt.Sprintf(
-		"UPDATE %s SET predecessorsId=%q WHERE id = %d",
-		TableName,
-		util.Flat(newPredecessorsId),
-		id,
-	)
-	return execStmt(sqldb, stmt)
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return projects, err
+		}
+		projects = append(projects, p)
+	}
+
+	return projects, rows.Err()
 }
 
-func updateSuccessors(sqldb *sql.DB, id int, newSuccessorsId []int) (n int64, err error) {
-	stmt := fmt.Sprintf(
-		"UPDATE %s SET successorsId=%q WHERE id = %d",
-		TableName,
-		util.Flat(newSuccessorsId),
-		id,
-	)
-	return execStmt(sqldb, stmt)
+func updateProjectName(q queryer, id int, newName string) (n int64, err error) {
+	return execStmt(q, fmt.Sprintf("UPDATE %s SET name = ? WHERE id = ?", ProjectsTableName), newName, id)
 }
 
-func updateCost(sqldb *sql.DB, id int, newCost float64) (n int64, err error) {
-	stmt := fmt.Sprintf(
-		"UPDATE %s SET cost=%.6f WHERE id=%d",
-		TableName,
-		newCost,
-		id,
-	)
-	return execStmt(sqldb, stmt)
+// projectTables lists the tables holding the rows of the projects, with their columns other than projectId.
+var projectTables = []struct{ name, columns string }{
+	{TableName, activityColumns},
+	{RelationshipsTableName, "predecessorId, successorId, type, lag"},
+	{CalendarsTableName, "id, name, isDefault, workDays, shifts, holidays"},
 }
 
-func deleteActivity(sqldb *sql.DB, id int) (n int64, err error) {
-	stmt := fmt.Sprintf("DELETE FROM %s WHERE id = %d", TableName, id)
-	return execStmt(sqldb, stmt)
+// copyProject creates the project 'name' with a copy of the activities, links and calendars of the project of 's'.
+// The copied activities are recorded as inserted in the history of the created project.
+func copyProject(s scope, name string) (p *Project, err error) {
+	err = s.withTx(func(s scope) (err error) {
+		if _, err = getProject(s.q, s.project); err != nil {
+			return
+		}
+		if p, err = createProject(s.q, name); err != nil {
+			return
+		}
+		for _, t := range projectTables {
+			stmt := fmt.Sprintf("INSERT INTO %[1]s(projectId, %[2]s) SELECT ?, %[2]s FROM %[1]s WHERE projectId = ?", t.name, t.columns)
+			if _, err = execStmt(s.q, stmt, p.Id, s.project); err != nil {
+				return
+			}
+		}
+
+		s.project = p.Id
+		activities, err := getActivitiesAll(s)
+		if err != nil {
+			return
+		}
+		now, err := changeTime(s)
+		if err != nil {
+			return
+		}
+		for _, a := range activities {
+			if err = appendChange(s, now, nil, a); err != nil {
+				return
+			}
+		}
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
 }
 
-func deleteActivities(sqldb *sql.DB, ids []int) (n int64, err error) {
-	stmt := fmt.Sprintf("DELETE FROM %s WHERE id IN (%s)", TableName, util.Flat(ids))
-	return execStmt(sqldb, stmt)
+// deleteProject deletes the project of 's', with its activities, links and calendars.
+// The activities are recorded as deleted in the history of the project.
+func deleteProject(s scope) (n int64, err error) {
+	err = s.withTx(func(s scope) (err error) {
+		activities, err := getActivitiesAll(s)
+		if err != nil {
+			return
+		}
+		if n, err = execStmt(s.q, fmt.Sprintf("DELETE FROM %s WHERE id = ?", ProjectsTableName), s.project); err != nil || n == 0 {
+			return
+		}
+		now, err := changeTime(s)
+		if err != nil {
+			return
+		}
+		for _, a := range activities {
+			if err = appendChange(s, now, a, nil); err != nil {
+				return
+			}
+		}
+		return
+	})
+	return
 }
 
-func execStmt(sqldb *sql.DB, stmt string) (n int64, err error) {
-	res, err := sqldb.Exec(stmt)
+// execStmt executes the statement 'stmt' with the values 'args' of its bound parameters,
+// and returns the number of rows affected.
+func execStmt(q queryer, stmt string, args ...any) (n int64, err error) {
+	res, err := q.Exec(stmt, args...)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,314 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/vanillaiice/verano/activity"
+)
+
+// HistoryTableName is the name of the append-only table holding the changes of the activities in the sqlite database.
+const HistoryTableName = "history"
+
+// ChangeType defines how an activity was changed.
+type ChangeType int
+
+// Enumeration of available change types.
+const (
+	Inserted ChangeType = 0 // The activity was inserted
+	Updated  ChangeType = 1 // The activity was updated, including its links and its id
+	Deleted  ChangeType = 2 // The activity was deleted
+)
+
+// String returns the name of the change type (inserted, updated or deleted).
+func (t ChangeType) String() string {
+	switch t {
+	case Inserted:
+		return "inserted"
+	case Updated:
+		return "updated"
+	case Deleted:
+		return "deleted"
+	}
+	return fmt.Sprintf("ChangeType(%d)", int(t))
+}
+
+// A Change is an entry of the history of the activities, recording an activity before and after it was changed.
+type Change struct {
+	Id     int                // Id of the entry, increasing with the time of the changes
+	Time   time.Time          // Time of the change
+	Type   ChangeType         // How the activity was changed
+	Old    *activity.Activity // Activity before the change, nil if it was inserted
+	New    *activity.Activity // Activity after the change, nil if it was deleted
+	User   string             // User who made the change, if any
+	Reason string             // Reason of the change, if any
+}
+
+// linkedIds returns the ids of the predecessors and successors of the 'activities'.
+func linkedIds(activities ...*activity.Activity) (ids []int) {
+	for _, a := range activities {
+		ids = append(ids, a.PredecessorsId...)
+		ids = append(ids, a.SuccessorsId...)
+	}
+	return
+}
+
+// snapshot returns the activities 'ids' of the project of 's' and the activities linked to them, by id.
+func snapshot(s scope, ids []int) (activities map[int]*activity.Activity, err error) {
+	activities = make(map[int]*activity.Activity)
+	if len(ids) == 0 {
+		return
+	}
+	acts, err := getActivities(s, ids)
+	if err != nil {
+		return
+	}
+	for _, a := range acts {
+		activities[a.Id] = a
+	}
+
+	var linked []int
+	seen := make(map[int]bool)
+	for _, id := range linkedIds(acts...) {
+		if _, ok := activities[id]; !ok && !seen[id] {
+			linked = append(linked, id)
+			seen[id] = true
+		}
+	}
+	if len(linked) == 0 {
+		return
+	}
+	if acts, err = getActivities(s, linked); err != nil {
+		return
+	}
+	for _, a := range acts {
+		activities[a.Id] = a
+	}
+	return
+}
+
+// recordChanges runs 'f', which changes the activities 'ids' of the project of 's' and may link them to the
+// activities 'linked', and appends the changes of these activities and of the activities they were linked to
+// to the history. The ids of the activities renumbered by 'f' are mapped to their new ids in 'newIds'.
+// The changes are recorded in the transaction of 'f'.
+func recordChanges(s scope, ids, linked []int, newIds map[int]int, f func() error) (err error) {
+	before, err := snapshot(s, append(slices.Clone(ids), linked...))
+	if err != nil {
+		return
+	}
+	if err = f(); err != nil {
+		return
+	}
+
+	renumbered := func(id int) int {
+		if newId, ok := newIds[id]; ok {
+			return newId
+		}
+		return id
+	}
+	var afterIds []int
+	seen := make(map[int]bool)
+	for _, list := range [][]int{sortedKeys(before), ids, linked} {
+		for _, id := range list {
+			if id = renumbered(id); !seen[id] {
+				afterIds = append(afterIds, id)
+				seen[id] = true
+			}
+		}
+	}
+	after := make(map[int]*activity.Activity)
+	if len(afterIds) > 0 {
+		acts, err := getActivities(s, afterIds)
+		if err != nil {
+			return err
+		}
+		for _, a := range acts {
+			after[a.Id] = a
+		}
+	}
+
+	now, err := changeTime(s)
+	if err != nil {
+		return
+	}
+	paired := make(map[int]bool)
+	for _, id := range sortedKeys(before) {
+		paired[renumbered(id)] = true
+		if err = appendChange(s, now, before[id], after[renumbered(id)]); err != nil {
+			return
+		}
+	}
+	for _, id := range afterIds {
+		if paired[id] {
+			continue
+		}
+		if err = appendChange(s, now, nil, after[id]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// changeTime returns the time of the changes being recorded in the history of the project of 's'.
+// It is later than the time of the changes already recorded, even with a coarse clock, so that the changes
+// recorded together, which share their time, can be told apart from the others.
+func changeTime(s scope) (now time.Time, err error) {
+	var last int64
+	row := s.q.QueryRow(fmt.Sprintf("SELECT IFNULL(MAX(time), 0) FROM %s WHERE projectId = ?", HistoryTableName), s.project)
+	if err = row.Scan(&last); err != nil {
+		return
+	}
+	if now = time.Now(); now.UnixNano() <= last {
+		now = time.Unix(0, last+1)
+	}
+	return
+}
+
+// sortedKeys returns the keys of 'activities', sorted.
+func sortedKeys(activities map[int]*activity.Activity) (ids []int) {
+	ids = make([]int, 0, len(activities))
+	for id := range activities {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return
+}
+
+// appendChange appends the change of an activity from 'before' to 'after' at 'now' to the history, unless both
+// are equal. 'before' is nil for inserted activities, and 'after' is nil for deleted ones.
+func appendChange(s scope, now time.Time, before, after *activity.Activity) (err error) {
+	var (
+		changeType         ChangeType
+		oldId, newId       sql.NullInt64
+		oldValue, newValue sql.NullString
+	)
+	switch {
+	case before == nil && after == nil, reflect.DeepEqual(before, after):
+		return
+	case before == nil:
+		changeType = Inserted
+	case after == nil:
+		changeType = Deleted
+	default:
+		changeType = Updated
+	}
+	if before != nil {
+		oldId = sql.NullInt64{Int64: int64(before.Id), Valid: true}
+		if oldValue, err = marshalActivity(before); err != nil {
+			return
+		}
+	}
+	if after != nil {
+		newId = sql.NullInt64{Int64: int64(after.Id), Valid: true}
+		if newValue, err = marshalActivity(after); err != nil {
+			return
+		}
+	}
+
+	stmt := fmt.Sprintf("INSERT INTO %s(projectId, oldId, newId, time, type, oldValue, newValue, userName, reason) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)", HistoryTableName)
+	_, err = execStmt(s.q, stmt, s.project, oldId, newId, now.UnixNano(), int(changeType), oldValue, newValue, s.user, s.reason)
+	return
+}
+
+// marshalActivity returns the activity 'act' in json format.
+func marshalActivity(act *activity.Activity) (value sql.NullString, err error) {
+	b, err := json.Marshal(act)
+	if err != nil {
+		return
+	}
+	return sql.NullString{String: string(b), Valid: true}, nil
+}
+
+// unmarshalActivity returns the activity in json format 'value', or nil if 'value' is null.
+// The dates are in local time, as are the dates of the activities read from the database.
+func unmarshalActivity(value sql.NullString) (act *activity.Activity, err error) {
+	if !value.Valid {
+		return
+	}
+	act = &activity.Activity{}
+	if err = json.Unmarshal([]byte(value.String), act); err != nil {
+		return nil, err
+	}
+	for _, t := range []*time.Time{&act.Start, &act.Finish, &act.LateStart, &act.LateFinish, &act.ConstraintDate, &act.ActualStart, &act.ActualFinish} {
+		*t = t.Local()
+	}
+	return
+}
+
+// queryChanges returns the changes of the history of the project of 's' selected by the condition 'where'
+// with the values 'args' of its bound parameters, sorted by the 'order' of their ids (ASC or DESC).
+func queryChanges(s scope, where, order string, args ...any) (changes []*Change, err error) {
+	stmt := fmt.Sprintf("SELECT id, time, type, oldValue, newValue, userName, reason FROM %s WHERE projectId = ? AND %s ORDER BY id %s", HistoryTableName, where, order)
+	rows, err := s.q.Query(stmt, append([]any{s.project}, args...)...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		c := &Change{}
+		var changeTime int64
+		var changeType int
+		var oldValue, newValue sql.NullString
+		if err = rows.Scan(&c.Id, &changeTime, &changeType, &oldValue, &newValue, &c.User, &c.Reason); err != nil {
+			return
+		}
+		c.Time = time.Unix(0, changeTime)
+		c.Type = ChangeType(changeType)
+		if c.Old, err = unmarshalActivity(oldValue); err != nil {
+			return
+		}
+		if c.New, err = unmarshalActivity(newValue); err != nil {
+			return
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// getHistory returns the changes of the activity 'id', including the changes from or to the id 'id', oldest first.
+func getHistory(s scope, id int) (changes []*Change, err error) {
+	return queryChanges(s, "(oldId = ? OR newId = ?)", "ASC", id, id)
+}
+
+// getActivitiesAllAt returns the activities of the project as they were at the time 't', sorted by id.
+// They are rebuilt by undoing the changes of the history made after 't' on the current activities, latest first.
+// The changes recorded together are undone at once, as the ids of the activities can be swapped within them.
+func getActivitiesAllAt(s scope, t time.Time) (activities []*activity.Activity, err error) {
+	var (
+		activitiesMap map[int]*activity.Activity
+		changes       []*Change
+	)
+	err = s.withTx(func(s scope) (err error) {
+		if activitiesMap, err = getActivitiesAllMap(s); err != nil {
+			return
+		}
+		changes, err = queryChanges(s, "time > ?", "DESC", t.UnixNano())
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	for i := 0; i < len(changes); {
+		j := i
+		for ; j < len(changes) && changes[j].Time.Equal(changes[i].Time); j++ {
+			if changes[j].New != nil {
+				delete(activitiesMap, changes[j].New.Id)
+			}
+		}
+		for ; i < j; i++ {
+			if changes[i].Old != nil {
+				activitiesMap[changes[i].Old.Id] = changes[i].Old
+			}
+		}
+	}
+	for _, id := range sortedKeys(activitiesMap) {
+		activities = append(activities, activitiesMap[id])
+	}
+	return
+}
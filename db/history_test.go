@@ -0,0 +1,144 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vanillaiice/verano/activity"
+)
+
+func TestGetHistory(t *testing.T) {
+	sqldb, err := openDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteDB()
+	defer sqldb.DB.Close()
+
+	sqldb.User, sqldb.Reason = "alice", "baseline"
+	if err = sqldb.InsertActivities([]*activity.Activity{{Id: 1, Description: "dig"}, {Id: 2, Description: "pour"}}, None); err != nil {
+		t.Fatal(err)
+	}
+	sqldb.User, sqldb.Reason = "bob", "rework"
+	if _, err = sqldb.UpdateDescription(1, "excavate"); err != nil {
+		t.Fatal(err)
+	}
+	// the link changes both activities
+	if _, err = sqldb.UpdatePredecessors(2, []int{1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sqldb.UpdateId(1, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := sqldb.GetHistory(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := []ChangeType{Inserted, Updated, Updated, Updated}
+	if len(changes) != len(types) {
+		t.Fatalf("got %d changes, want %d", len(changes), len(types))
+	}
+	for i, c := range changes {
+		if c.Type != types[i] {
+			t.Errorf("got %v, want %v", c.Type, types[i])
+		}
+	}
+	if c := changes[0]; c.Old != nil || c.New.Description != "dig" || c.User != "alice" || c.Reason != "baseline" {
+		t.Errorf("got %+v, want the insertion of the activity", c)
+	}
+	if c := changes[1]; c.Old.Description != "dig" || c.New.Description != "excavate" || c.User != "bob" || c.Reason != "rework" {
+		t.Errorf("got %+v, want the update of the description", c)
+	}
+	if c := changes[2]; len(c.Old.SuccessorsId) != 0 || !reflect.DeepEqual(c.New.SuccessorsId, []int{2}) {
+		t.Errorf("got %+v, want the link to the successor", c)
+	}
+	if c := changes[3]; c.Old.Id != 1 || c.New.Id != 10 {
+		t.Errorf("got %+v, want the change of the id", c)
+	}
+	// the history of the new id starts with the change of the id
+	if renumbered, err := sqldb.GetHistory(10); err != nil || !reflect.DeepEqual(renumbered, changes[3:]) {
+		t.Errorf("got %+v, %v, want %+v", renumbered, err, changes[3:])
+	}
+
+	// the history is append-only
+	if _, err = sqldb.DB.Exec("UPDATE history SET reason = 'none'"); err == nil {
+		t.Error("got no error updating the history, want an error")
+	}
+	if _, err = sqldb.DB.Exec("DELETE FROM history"); err == nil {
+		t.Error("got no error deleting the history, want an error")
+	}
+}
+
+func TestGetActivitiesAllAt(t *testing.T) {
+	sqldb, err := openDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteDB()
+	defer sqldb.DB.Close()
+
+	acts := []*activity.Activity{
+		{Id: 1, Description: "dig", Start: start, Finish: finish},
+		{Id: 2, Description: "pour", PredecessorsId: []int{1}, Relationships: map[int]activity.Relationship{1: {Type: activity.StartToStart, Lag: time.Hour}}},
+		{Id: 3, Description: "cure", PredecessorsId: []int{2}},
+	}
+	if err = sqldb.InsertActivities(acts, None); err != nil {
+		t.Fatal(err)
+	}
+	want, err := sqldb.GetActivitiesAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now()
+	time.Sleep(time.Millisecond)
+
+	if _, err = sqldb.UpdateDuration(1, duration); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sqldb.DeleteActivityWithPolicy(2, Bridge); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sqldb.UpdateIds(map[int]int{1: 3, 3: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sqldb.InsertActivity(&activity.Activity{Id: 4, Description: "paint"}, None); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := sqldb.GetActivitiesAllAt(past)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	now, err := sqldb.GetActivitiesAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err = sqldb.GetActivitiesAllAt(time.Now()); err != nil || !reflect.DeepEqual(got, now) {
+		t.Errorf("got %+v, %v, want the current activities", got, err)
+	}
+	if got, err = sqldb.GetActivitiesAllAt(time.Time{}); err != nil || len(got) != 0 {
+		t.Errorf("got %+v, %v, want no activities", got, err)
+	}
+
+	// the history of a deleted project is kept
+	p, err := sqldb.CopyProject(DefaultProjectId, "copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sqldb.DeleteProject(p.Id); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := sqldb.WithProject(p.Id).GetHistory(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Type != Inserted || changes[1].Type != Deleted {
+		t.Errorf("got %+v, want the insertion and deletion of the activity", changes)
+	}
+}
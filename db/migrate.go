@@ -0,0 +1,339 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vanillaiice/verano/activity"
+	"github.com/vanillaiice/verano/util"
+)
+
+// ErrNewerSchema is returned when opening a database whose schema was written by a newer version of the package.
+var ErrNewerSchema = errors.New("database schema is newer than the supported schema")
+
+// migration is a step upgrading the schema of the database to the next version.
+type migration struct {
+	description string              // What the step does
+	up          func(*sql.Tx) error // Upgrades the schema, in the transaction of the migration
+}
+
+// migrations lists the steps upgrading the schema of the database, in order. The version of the schema
+// of a database is the number of steps applied to it, recorded in its user_version pragma.
+// Databases created before the versioning of the schema have the version 0, whatever columns they hold,
+// so the first steps create or complete their tables. New steps must only be appended to the list.
+var migrations = []migration{
+	{"create the activities and calendars tables", createTables},
+	{"add the columns missing from the activities table", addMissingColumns},
+	{"move the links between the activities to the relationships table", migrateRelationships},
+	{"add the projects table and scope the activities, links and calendars to projects", addProjects},
+	{"add the history table of the activities", addHistory},
+	{"add the code column to the activities table", addCode},
+}
+
+// SchemaVersion is the version of the schema of the databases written by this package.
+var SchemaVersion = len(migrations)
+
+// migrate upgrades the schema of the database to SchemaVersion, in a single transaction.
+// It returns ErrNewerSchema if the schema of the database is newer than SchemaVersion.
+func migrate(sqldb *sql.DB) (err error) {
+	ctx := context.Background()
+	conn, err := sqldb.Conn(ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// the schema is migrated without enforcing the foreign keys, as rebuilding a table referenced by
+	// foreign keys would otherwise delete the rows referencing it. The pragma has no effect in a transaction.
+	if _, err = conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return
+	}
+	defer func() {
+		if _, fkErr := conn.ExecContext(ctx, "PRAGMA foreign_keys = ON"); err == nil {
+			err = fkErr
+		}
+	}()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	if err = migrateTx(tx); err != nil {
+		tx.Rollback()
+		return
+	}
+	return tx.Commit()
+}
+
+// migrateTx upgrades the schema of the database to SchemaVersion in the transaction 'tx'.
+func migrateTx(tx *sql.Tx) (err error) {
+	version, err := schemaVersion(tx)
+	if err != nil {
+		return
+	}
+	if version > SchemaVersion {
+		return fmt.Errorf("%w: version %d, supported version %d", ErrNewerSchema, version, SchemaVersion)
+	}
+	if version == SchemaVersion {
+		return
+	}
+
+	for i := version; i < SchemaVersion; i++ {
+		if err = migrations[i].up(tx); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", i+1, migrations[i].description, err)
+		}
+	}
+	if err = checkRelationships(tx); err != nil {
+		return
+	}
+	// pragma statements do not accept bound parameters
+	_, err = execStmt(tx, fmt.Sprintf("PRAGMA user_version = %d", SchemaVersion))
+	return
+}
+
+// schemaVersion returns the version of the schema of the database.
+func schemaVersion(q queryer) (version int, err error) {
+	err = q.QueryRow("PRAGMA user_version").Scan(&version)
+	return
+}
+
+// createTables creates the activities table, with the columns it had before the versioning of the schema,
+// and the calendars table, if they do not exist.
+func createTables(tx *sql.Tx) (err error) {
+	stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s(id INTEGER PRIMARY KEY, description TEXT, duration REAL, predecessorsId TEXT, successorsId TEXT, start INTEGER, finish INTEGER, cost REAL)", TableName)
+	if _, err = execStmt(tx, stmt); err != nil {
+		return
+	}
+	stmt = fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s(id INTEGER PRIMARY KEY, name TEXT, isDefault INTEGER, workDays TEXT, shifts TEXT, holidays TEXT)", CalendarsTableName)
+	_, err = execStmt(tx, stmt)
+	return
+}
+
+// addedColumns lists the columns added to the activities table before the versioning of the schema,
+// with their definition. They hold the values of the zero activity in the existing rows.
+var addedColumns = []struct{ name, definition string }{
+	{"wbs", "TEXT NOT NULL DEFAULT ''"},
+	{"calendarId", "INTEGER NOT NULL DEFAULT 0"},
+	{"relationships", "TEXT NOT NULL DEFAULT ''"},
+	{"progress", "REAL NOT NULL DEFAULT 0"},
+	{"actualStart", fmt.Sprintf("INTEGER NOT NULL DEFAULT %d", time.Time{}.Unix())},
+	{"actualFinish", fmt.Sprintf("INTEGER NOT NULL DEFAULT %d", time.Time{}.Unix())},
+	{"lateStart", fmt.Sprintf("INTEGER NOT NULL DEFAULT %d", time.Time{}.Unix())},
+	{"lateFinish", fmt.Sprintf("INTEGER NOT NULL DEFAULT %d", time.Time{}.Unix())},
+	{"totalFloat", "REAL NOT NULL DEFAULT 0"},
+	{"freeFloat", "REAL NOT NULL DEFAULT 0"},
+	{"constraintType", "INTEGER NOT NULL DEFAULT 0"},
+	{"constraintDate", fmt.Sprintf("INTEGER NOT NULL DEFAULT %d", time.Time{}.Unix())},
+}
+
+// tableColumns returns the names of the columns of the table 'table'.
+func tableColumns(q queryer, table string) (columns map[string]bool, err error) {
+	rows, err := q.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	columns = make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+// addMissingColumns adds the columns of addedColumns missing from the activities table.
+func addMissingColumns(tx *sql.Tx) (err error) {
+	columns, err := tableColumns(tx, TableName)
+	if err != nil {
+		return
+	}
+	for _, c := range addedColumns {
+		if columns[c.name] {
+			continue
+		}
+		if _, err = execStmt(tx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", TableName, c.name, c.definition)); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// migrateRelationships creates the relationships table, moves the links stored in the predecessorsId,
+// successorsId and relationships text columns of the activities table to it, and drops the text columns.
+// The links to activities missing from the database cannot be kept, and are dropped.
+func migrateRelationships(tx *sql.Tx) (err error) {
+	// the foreign keys are deferred so that activities can be inserted in any order in a transaction
+	stmt := fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %[1]s(predecessorId INTEGER NOT NULL REFERENCES %[2]s(id) ON UPDATE CASCADE ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED, successorId INTEGER NOT NULL REFERENCES %[2]s(id) ON UPDATE CASCADE ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED, type INTEGER NOT NULL DEFAULT 0, lag REAL NOT NULL DEFAULT 0, PRIMARY KEY(predecessorId, successorId))",
+		RelationshipsTableName,
+		TableName,
+	)
+	if _, err = execStmt(tx, stmt); err != nil {
+		return
+	}
+	stmt = fmt.Sprintf("CREATE INDEX IF NOT EXISTS %[1]sSuccessorId ON %[1]s(successorId)", RelationshipsTableName)
+	if _, err = execStmt(tx, stmt); err != nil {
+		return
+	}
+
+	columns, err := tableColumns(tx, TableName)
+	if err != nil || !columns["predecessorsId"] {
+		return
+	}
+	rows, err := tx.Query(fmt.Sprintf("SELECT id, predecessorsId, successorsId, relationships FROM %s", TableName))
+	if err != nil {
+		return
+	}
+	var activities []*activity.Activity
+	ids := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		var predecessorsId, successorsId, relationships sql.NullString
+		if err = rows.Scan(&id, &predecessorsId, &successorsId, &relationships); err != nil {
+			rows.Close()
+			return
+		}
+		act := &activity.Activity{Id: id}
+		if act.PredecessorsId, err = util.Unflat(predecessorsId.String); err != nil {
+			rows.Close()
+			return
+		}
+		if act.SuccessorsId, err = util.Unflat(successorsId.String); err != nil {
+			rows.Close()
+			return
+		}
+		if act.Relationships, err = util.UnflatRelationships(relationships.String); err != nil {
+			rows.Close()
+			return
+		}
+		activities = append(activities, act)
+		ids[id] = true
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	// the statements of this step are written against the schema of its version, rather than with writeRelationships
+	upsert := fmt.Sprintf(
+		"INSERT INTO %s(predecessorId, successorId, type, lag) VALUES(?, ?, ?, ?) ON CONFLICT(predecessorId, successorId) DO UPDATE SET type = excluded.type, lag = excluded.lag",
+		RelationshipsTableName,
+	)
+	insert := fmt.Sprintf("INSERT OR IGNORE INTO %s(predecessorId, successorId) VALUES(?, ?)", RelationshipsTableName)
+	for _, act := range activities {
+		for _, p := range act.PredecessorsId {
+			if !ids[p] {
+				continue
+			}
+			rel := act.Relationship(p)
+			if _, err = execStmt(tx, upsert, p, act.Id, int(rel.Type), rel.Lag.Seconds()); err != nil {
+				return
+			}
+		}
+		for _, s := range act.SuccessorsId {
+			if !ids[s] {
+				continue
+			}
+			if _, err = execStmt(tx, insert, act.Id, s); err != nil {
+				return
+			}
+		}
+	}
+
+	for _, column := range []string{"predecessorsId", "successorsId", "relationships"} {
+		if _, err = execStmt(tx, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", TableName, column)); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// addProjects creates the projects table with the default project, and rebuilds the activities, relationships
+// and calendars tables with the id of their project as the first column of their primary key, moving their rows
+// to the default project. The tables are rebuilt as sqlite cannot alter primary keys, hence the migration of the
+// schema without enforcing the foreign keys.
+func addProjects(tx *sql.Tx) (err error) {
+	zero := time.Time{}.Unix()
+	stmts := []string{
+		fmt.Sprintf("CREATE TABLE %s(id INTEGER PRIMARY KEY, name TEXT NOT NULL UNIQUE)", ProjectsTableName),
+		fmt.Sprintf("INSERT INTO %s(id, name) VALUES(%d, '%s')", ProjectsTableName, DefaultProjectId, DefaultProjectName),
+
+		fmt.Sprintf(
+			"CREATE TABLE %[1]sNew(projectId INTEGER NOT NULL REFERENCES %[2]s(id) ON DELETE CASCADE, id INTEGER NOT NULL, description TEXT NOT NULL DEFAULT '', wbs TEXT NOT NULL DEFAULT '', duration REAL NOT NULL DEFAULT 0, calendarId INTEGER NOT NULL DEFAULT 0, start INTEGER NOT NULL DEFAULT %[3]d, finish INTEGER NOT NULL DEFAULT %[3]d, progress REAL NOT NULL DEFAULT 0, actualStart INTEGER NOT NULL DEFAULT %[3]d, actualFinish INTEGER NOT NULL DEFAULT %[3]d, cost REAL NOT NULL DEFAULT 0, lateStart INTEGER NOT NULL DEFAULT %[3]d, lateFinish INTEGER NOT NULL DEFAULT %[3]d, totalFloat REAL NOT NULL DEFAULT 0, freeFloat REAL NOT NULL DEFAULT 0, constraintType INTEGER NOT NULL DEFAULT 0, constraintDate INTEGER NOT NULL DEFAULT %[3]d, PRIMARY KEY(projectId, id))",
+			TableName, ProjectsTableName, zero,
+		),
+		fmt.Sprintf(
+			"INSERT INTO %[1]sNew(projectId, id, description, wbs, duration, calendarId, start, finish, progress, actualStart, actualFinish, cost, lateStart, lateFinish, totalFloat, freeFloat, constraintType, constraintDate) SELECT %[2]d, id, IFNULL(description, ''), wbs, IFNULL(duration, 0), calendarId, IFNULL(start, %[3]d), IFNULL(finish, %[3]d), progress, actualStart, actualFinish, IFNULL(cost, 0), lateStart, lateFinish, totalFloat, freeFloat, constraintType, constraintDate FROM %[1]s",
+			TableName, DefaultProjectId, zero,
+		),
+
+		fmt.Sprintf(
+			"CREATE TABLE %[1]sNew(projectId INTEGER NOT NULL, predecessorId INTEGER NOT NULL, successorId INTEGER NOT NULL, type INTEGER NOT NULL DEFAULT 0, lag REAL NOT NULL DEFAULT 0, PRIMARY KEY(projectId, predecessorId, successorId), FOREIGN KEY(projectId, predecessorId) REFERENCES %[2]s(projectId, id) ON UPDATE CASCADE ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED, FOREIGN KEY(projectId, successorId) REFERENCES %[2]s(projectId, id) ON UPDATE CASCADE ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED)",
+			RelationshipsTableName, TableName,
+		),
+		fmt.Sprintf("INSERT INTO %[1]sNew(projectId, predecessorId, successorId, type, lag) SELECT %[2]d, predecessorId, successorId, type, lag FROM %[1]s", RelationshipsTableName, DefaultProjectId),
+
+		fmt.Sprintf(
+			"CREATE TABLE %[1]sNew(projectId INTEGER NOT NULL REFERENCES %[2]s(id) ON DELETE CASCADE, id INTEGER NOT NULL, name TEXT, isDefault INTEGER, workDays TEXT, shifts TEXT, holidays TEXT, PRIMARY KEY(projectId, id))",
+			CalendarsTableName, ProjectsTableName,
+		),
+		fmt.Sprintf("INSERT INTO %[1]sNew(projectId, id, name, isDefault, workDays, shifts, holidays) SELECT %[2]d, id, name, isDefault, workDays, shifts, holidays FROM %[1]s", CalendarsTableName, DefaultProjectId),
+	}
+	for _, table := range []string{RelationshipsTableName, TableName, CalendarsTableName} {
+		stmts = append(stmts, fmt.Sprintf("DROP TABLE %s", table), fmt.Sprintf("ALTER TABLE %[1]sNew RENAME TO %[1]s", table))
+	}
+	stmts = append(stmts, fmt.Sprintf("CREATE INDEX %[1]sSuccessorId ON %[1]s(projectId, successorId)", RelationshipsTableName))
+
+	for _, stmt := range stmts {
+		if _, err = execStmt(tx, stmt); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// addHistory creates the append-only history table, whose rows cannot be updated or deleted, and rebuilds
+// the projects table so that the ids of deleted projects, which are kept in the history, are not reused.
+func addHistory(tx *sql.Tx) (err error) {
+	stmts := []string{
+		fmt.Sprintf("CREATE TABLE %sNew(id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL UNIQUE)", ProjectsTableName),
+		fmt.Sprintf("INSERT INTO %[1]sNew(id, name) SELECT id, name FROM %[1]s", ProjectsTableName),
+		fmt.Sprintf("DROP TABLE %s", ProjectsTableName),
+		fmt.Sprintf("ALTER TABLE %[1]sNew RENAME TO %[1]s", ProjectsTableName),
+
+		// the history is not linked to the projects, so that it outlives them
+		fmt.Sprintf(
+			"CREATE TABLE %s(id INTEGER PRIMARY KEY AUTOINCREMENT, projectId INTEGER NOT NULL, oldId INTEGER, newId INTEGER, time INTEGER NOT NULL, type INTEGER NOT NULL, oldValue TEXT, newValue TEXT, userName TEXT NOT NULL DEFAULT '', reason TEXT NOT NULL DEFAULT '')",
+			HistoryTableName,
+		),
+		fmt.Sprintf("CREATE INDEX %[1]sOldId ON %[1]s(projectId, oldId)", HistoryTableName),
+		fmt.Sprintf("CREATE INDEX %[1]sNewId ON %[1]s(projectId, newId)", HistoryTableName),
+		fmt.Sprintf("CREATE INDEX %[1]sTime ON %[1]s(projectId, time)", HistoryTableName),
+	}
+	for _, event := range []string{"UPDATE", "DELETE"} {
+		stmts = append(stmts, fmt.Sprintf(
+			"CREATE TRIGGER %[1]s%[2]s BEFORE %[2]s ON %[1]s BEGIN SELECT RAISE(ABORT, '%[1]s is append-only'); END",
+			HistoryTableName, event,
+		))
+	}
+
+	for _, stmt := range stmts {
+		if _, err = execStmt(tx, stmt); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// addCode adds the code column, holding the codes of the activities used by the planners, to the activities table.
+func addCode(tx *sql.Tx) (err error) {
+	_, err = execStmt(tx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN code TEXT NOT NULL DEFAULT ''", TableName))
+	return
+}
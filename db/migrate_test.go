@@ -0,0 +1,199 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vanillaiice/verano/activity"
+)
+
+// createLegacyDB creates the database test.db with the statements 'stmts', without migrating it.
+func createLegacyDB(t *testing.T, stmts ...string) {
+	t.Helper()
+	sqldb, err := sql.Open("sqlite", "test.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqldb.Close()
+	for _, stmt := range stmts {
+		if _, err = sqldb.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMigrateNewDB(t *testing.T) {
+	sqldb, err := openDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteDB()
+	defer sqldb.DB.Close()
+
+	version, err := schemaVersion(sqldb.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != SchemaVersion {
+		t.Errorf("got version %d, want %d", version, SchemaVersion)
+	}
+	columns, err := tableColumns(sqldb.DB, TableName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []string{"id", "description", "wbs", "progress", "constraintDate"} {
+		if !columns[c] {
+			t.Errorf("got columns %v, want a column %s", columns, c)
+		}
+	}
+
+	// reopening a database with the current schema leaves it unchanged
+	reopened, err := openDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened.DB.Close()
+}
+
+func TestMigrateBaselineDB(t *testing.T) {
+	createLegacyDB(t,
+		fmt.Sprintf("CREATE TABLE %s(id INTEGER PRIMARY KEY, description TEXT, duration REAL, predecessorsId TEXT, successorsId TEXT, start INTEGER, finish INTEGER, cost REAL)", TableName),
+		fmt.Sprintf("INSERT INTO %s VALUES(1, 'Dig', 3600, '', '2', 0, 3600, 10)", TableName),
+		fmt.Sprintf("INSERT INTO %s VALUES(2, 'Pour', 7200, '1', '', 3600, 10800, 0)", TableName),
+	)
+	defer deleteDB()
+
+	sqldb, err := openDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqldb.DB.Close()
+	a, err := sqldb.GetActivity(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Description != "Pour" || a.Duration != 2*time.Hour || !reflect.DeepEqual(a.PredecessorsId, []int{1}) || a.Wbs != "" || a.IsStarted() {
+		t.Errorf("got %+v, want the baseline activity with the added fields holding their zero value", a)
+	}
+}
+
+func TestOpenAddsMissingColumns(t *testing.T) {
+	sqldb, err := sql.Open("sqlite", "test.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteDB()
+	stmt := fmt.Sprintf("CREATE TABLE %s(id INTEGER PRIMARY KEY, description TEXT, wbs TEXT, duration REAL, calendarId INTEGER, predecessorsId TEXT, successorsId TEXT, relationships TEXT, start INTEGER, finish INTEGER, progress REAL, actualStart INTEGER, actualFinish INTEGER, cost REAL)", TableName)
+	if _, err = sqldb.Exec(stmt); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sqldb.Exec(fmt.Sprintf("INSERT INTO %s VALUES(1, 'Dig', '', 3600, 0, '', '', '', 0, 3600, 0, 0, 0, 0)", TableName)); err != nil {
+		t.Fatal(err)
+	}
+	sqldb.Close()
+
+	db, err := openDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.DB.Close()
+	a, err := db.GetActivity(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Description != "Dig" || a.ConstraintType != activity.NoConstraint || a.TotalFloat != 0 || !a.LateStart.IsZero() || !a.ConstraintDate.IsZero() {
+		t.Errorf("got %+v, want the added fields to hold their zero value", a)
+	}
+}
+
+func TestOpenMigratesRelationships(t *testing.T) {
+	sqldb, err := sql.Open("sqlite", "test.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteDB()
+	stmt := fmt.Sprintf("CREATE TABLE %s(id INTEGER PRIMARY KEY, description TEXT, wbs TEXT, duration REAL, calendarId INTEGER, predecessorsId TEXT, successorsId TEXT, relationships TEXT, start INTEGER, finish INTEGER, progress REAL, actualStart INTEGER, actualFinish INTEGER, cost REAL)", TableName)
+	if _, err = sqldb.Exec(stmt); err != nil {
+		t.Fatal(err)
+	}
+	for _, values := range []string{
+		"(1, 'Dig', '', 3600, 0, '', '2,3', '', 0, 0, 0, 0, 0, 0)",
+		"(2, 'Pour', '', 3600, 0, '1', '', '1:SS:30m0s', 0, 0, 0, 0, 0, 0)",
+		"(3, 'Cure', '', 3600, 0, '1,9', '', '', 0, 0, 0, 0, 0, 0)",
+	} {
+		if _, err = sqldb.Exec(fmt.Sprintf("INSERT INTO %s VALUES%s", TableName, values)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sqldb.Close()
+
+	db, err := openDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.DB.Close()
+	columns, err := tableColumns(db.DB, TableName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if columns["predecessorsId"] || columns["successorsId"] || columns["relationships"] {
+		t.Errorf("got columns %v, want the text columns to be dropped", columns)
+	}
+
+	acts, err := db.GetActivitiesAllMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := acts[1].SuccessorsId; !reflect.DeepEqual(got, []int{2, 3}) {
+		t.Errorf("got %v, want %v", got, []int{2, 3})
+	}
+	if got := acts[3].PredecessorsId; !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("got %v, want the link to the missing activity to be dropped", got)
+	}
+	if got, want := acts[2].Relationship(1), (activity.Relationship{Type: activity.StartToStart, Lag: 30 * time.Minute}); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMigrateNewerDB(t *testing.T) {
+	createLegacyDB(t, fmt.Sprintf("PRAGMA user_version = %d", SchemaVersion+1))
+	defer deleteDB()
+
+	_, err := openDB()
+	if !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("got %v, want %v", err, ErrNewerSchema)
+	}
+}
+
+func TestMigrateRollback(t *testing.T) {
+	createLegacyDB(t,
+		fmt.Sprintf("CREATE TABLE %s(id INTEGER PRIMARY KEY, description TEXT, duration REAL, predecessorsId TEXT, successorsId TEXT, start INTEGER, finish INTEGER, cost REAL)", TableName),
+		fmt.Sprintf("INSERT INTO %s VALUES(1, 'Dig', 3600, 'not an id', '', 0, 3600, 10)", TableName),
+	)
+	defer deleteDB()
+
+	if _, err := openDB(); err == nil {
+		t.Fatal("got no error for an invalid predecessor, want an error")
+	}
+
+	sqldb, err := sql.Open("sqlite", "test.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqldb.Close()
+	version, err := schemaVersion(sqldb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns, err := tableColumns(sqldb, TableName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 0 || columns["wbs"] || !columns["predecessorsId"] {
+		t.Errorf("got version %d and columns %v, want the database to be left unchanged", version, columns)
+	}
+}
@@ -0,0 +1,282 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/vanillaiice/verano/activity"
+	"github.com/vanillaiice/verano/project/calendar"
+)
+
+// A Tx is a transaction of a database, bound to the project of the DB that began it.
+// Its methods are those of DB, and their changes are only visible outside of the transaction once it is committed.
+// The links between the activities are checked when the transaction is committed, so that activities can be
+// inserted, renumbered or deleted in any order within it.
+// As sqlite allows a single writer, the DB should not be written while a transaction is open.
+type Tx struct {
+	Tx        *sql.Tx
+	ProjectId int
+	User      string
+	Reason    string
+}
+
+// Begin starts a transaction bound to the project of 'db', recording its user and reason in the history.
+// The transaction must be ended with Commit or Rollback.
+func (db *DB) Begin() (tx *Tx, err error) {
+	sqltx, err := db.DB.Begin()
+	if err != nil {
+		return
+	}
+	return &Tx{Tx: sqltx, ProjectId: db.ProjectId, User: db.User, Reason: db.Reason}, nil
+}
+
+// InTx runs 'f' in a transaction bound to the project of 'db', which is committed if 'f' succeeds,
+// and rolled back otherwise.
+func (db *DB) InTx(f func(tx *Tx) error) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return
+	}
+	if err = f(tx); err != nil {
+		tx.Rollback()
+		return
+	}
+	return tx.Commit()
+}
+
+// Commit commits the transaction. If a link between the activities references an activity missing
+// from the project, the transaction is rolled back and an error is returned.
+func (tx *Tx) Commit() (err error) {
+	if err = checkRelationships(tx.Tx); err != nil {
+		tx.Tx.Rollback()
+		return
+	}
+	return tx.Tx.Commit()
+}
+
+// Rollback aborts the transaction.
+func (tx *Tx) Rollback() error {
+	return tx.Tx.Rollback()
+}
+
+// WithProject returns a Tx bound to the project with the specified id, sharing the transaction, user and reason of 'tx'.
+// Committing or rolling back either Tx ends the shared transaction.
+func (tx *Tx) WithProject(id int) *Tx {
+	p := *tx
+	p.ProjectId = id
+	return &p
+}
+
+// scope returns the project of 'tx', read and written through the transaction.
+func (tx *Tx) scope() scope {
+	return scope{q: tx.Tx, project: tx.ProjectId, user: tx.User, reason: tx.Reason}
+}
+
+// InsertActivity inserts the provided activity in the transaction.
+// Its predecessors and successors must be in the database when the transaction is committed.
+func (tx *Tx) InsertActivity(act *activity.Activity, duplicateInsertPolicy DuplicateInsertPolicy) (n int64, err error) {
+	return insertActivity(tx.scope(), act, duplicateInsertPolicy)
+}
+
+// InsertActivities inserts the provided activities in the transaction.
+func (tx *Tx) InsertActivities(activities []*activity.Activity, duplicateInsertPolicy DuplicateInsertPolicy) (err error) {
+	return insertActivities(tx.scope(), activities, duplicateInsertPolicy)
+}
+
+// GetActivity retrieves the activity with the specified id in the transaction.
+func (tx *Tx) GetActivity(id int) (act *activity.Activity, err error) {
+	return getActivity(tx.scope(), id)
+}
+
+// GetActivities retrieves the activities with the specified ids in the transaction.
+func (tx *Tx) GetActivities(ids []int) (activities []*activity.Activity, err error) {
+	return getActivities(tx.scope(), ids)
+}
+
+// GetActivitiesAll retrieves all activities in the transaction.
+func (tx *Tx) GetActivitiesAll() (activities []*activity.Activity, err error) {
+	return getActivitiesAll(tx.scope())
+}
+
+// GetActivitiesAllMap retrieves all activities in the transaction, as a map with activity ids as keys.
+func (tx *Tx) GetActivitiesAllMap() (activitiesMap map[int]*activity.Activity, err error) {
+	return getActivitiesAllMap(tx.scope())
+}
+
+// UpdateActivity updates the activity with the specified id in the transaction
+// using the information provided in the activity.
+func (tx *Tx) UpdateActivity(act *activity.Activity, id int) (n int64, err error) {
+	return updateActivity(tx.scope(), act, id)
+}
+
+// UpdateId updates the id of an activity with the specified id in the transaction, its links following it.
+func (tx *Tx) UpdateId(oldId, newId int) (n int64, err error) {
+	return updateId(tx.scope(), oldId, newId, Cascade)
+}
+
+// UpdateIdWithPolicy updates the id of an activity with the specified id in the transaction, with the 'referencePolicy'.
+func (tx *Tx) UpdateIdWithPolicy(oldId, newId int, referencePolicy ReferencePolicy) (n int64, err error) {
+	return updateId(tx.scope(), oldId, newId, referencePolicy)
+}
+
+// UpdateIds updates the ids of the activities from the keys of 'newIds' to their values in the transaction,
+// their links following them.
+func (tx *Tx) UpdateIds(newIds map[int]int) (n int64, err error) {
+	return updateIds(tx.scope(), newIds, Cascade)
+}
+
+// UpdateIdsWithPolicy updates the ids of the activities from the keys of 'newIds' to their values in the transaction,
+// with the 'referencePolicy'.
+func (tx *Tx) UpdateIdsWithPolicy(newIds map[int]int, referencePolicy ReferencePolicy) (n int64, err error) {
+	return updateIds(tx.scope(), newIds, referencePolicy)
+}
+
+// UpdateDescription updates the description of an activity with the specified id in the transaction
+func (tx *Tx) UpdateDescription(id int, newDescription string) (n int64, err error) {
+	return updateDescription(tx.scope(), id, newDescription)
+}
+
+// UpdateDuration updates the duration of an activity with the specified id in the transaction
+func (tx *Tx) UpdateDuration(id int, newDuration time.Duration) (n int64, err error) {
+	return updateDuration(tx.scope(), id, newDuration)
+}
+
+// UpdateStart updates the start time of an activity with the specified id in the transaction
+func (tx *Tx) UpdateStart(id int, newStart time.Time) (n int64, err error) {
+	return updateStart(tx.scope(), id, newStart)
+}
+
+// UpdateFinish updates the finish time of an activity with the specified id in the transaction
+func (tx *Tx) UpdateFinish(id int, newFinish time.Time) (n int64, err error) {
+	return updateFinish(tx.scope(), id, newFinish)
+}
+
+// UpdateProgress updates the progress of an activity with the specified id in the transaction
+func (tx *Tx) UpdateProgress(id int, newProgress float32) (n int64, err error) {
+	return updateProgress(tx.scope(), id, newProgress)
+}
+
+// UpdateActualStart updates the actual start time of an activity with the specified id in the transaction
+func (tx *Tx) UpdateActualStart(id int, newActualStart time.Time) (n int64, err error) {
+	return updateActualStart(tx.scope(), id, newActualStart)
+}
+
+// UpdateActualFinish updates the actual finish time of an activity with the specified id in the transaction
+func (tx *Tx) UpdateActualFinish(id int, newActualFinish time.Time) (n int64, err error) {
+	return updateActualFinish(tx.scope(), id, newActualFinish)
+}
+
+// UpdateSuccessors updates the successors of the activity with the specified id in the transaction,
+// keeping the type and lag of its existing links. It returns the number of links added or removed.
+func (tx *Tx) UpdateSuccessors(id int, successorsId []int) (n int64, err error) {
+	return updateSuccessors(tx.scope(), id, successorsId)
+}
+
+// UpdateCalendarId updates the calendar of an activity with the specified id in the transaction
+func (tx *Tx) UpdateCalendarId(id int, newCalendarId int) (n int64, err error) {
+	return updateCalendarId(tx.scope(), id, newCalendarId)
+}
+
+// UpdateRelationships updates the relationships of the activity with the specified id with its predecessors
+// in the transaction. It returns the number of links to the predecessors.
+func (tx *Tx) UpdateRelationships(id int, relationships map[int]activity.Relationship) (n int64, err error) {
+	return updateRelationships(tx.scope(), id, relationships)
+}
+
+// UpdateCost updates the cost of an activity with the specified id in the transaction
+func (tx *Tx) UpdateCost(id int, newCost float64) (n int64, err error) {
+	return updateCost(tx.scope(), id, newCost)
+}
+
+// UpdatePredecessors updates the predecessors of the activity with the specified id in the transaction,
+// keeping the type and lag of its existing links. It returns the number of links added or removed.
+func (tx *Tx) UpdatePredecessors(id int, predecessorsId []int) (n int64, err error) {
+	return updatePredecessors(tx.scope(), id, predecessorsId)
+}
+
+// DeleteActivity deletes the activity with the specified id in the transaction, with its links.
+func (tx *Tx) DeleteActivity(id int) (n int64, err error) {
+	return deleteActivity(tx.scope(), id, Cascade)
+}
+
+// DeleteActivityWithPolicy deletes the activity with the specified id in the transaction, with the 'referencePolicy'.
+func (tx *Tx) DeleteActivityWithPolicy(id int, referencePolicy ReferencePolicy) (n int64, err error) {
+	return deleteActivity(tx.scope(), id, referencePolicy)
+}
+
+// DeleteActivities deletes the activities with the specified ids in the transaction, with their links.
+func (tx *Tx) DeleteActivities(ids []int) (n int64, err error) {
+	return deleteActivities(tx.scope(), ids, Cascade)
+}
+
+// DeleteActivitiesWithPolicy deletes the activities with the specified ids in the transaction, with the 'referencePolicy'.
+func (tx *Tx) DeleteActivitiesWithPolicy(ids []int, referencePolicy ReferencePolicy) (n int64, err error) {
+	return deleteActivities(tx.scope(), ids, referencePolicy)
+}
+
+// GetHistory retrieves the changes of the activity with the specified id in the transaction, oldest first.
+func (tx *Tx) GetHistory(id int) (changes []*Change, err error) {
+	return getHistory(tx.scope(), id)
+}
+
+// GetActivitiesAllAt retrieves all activities in the transaction as they were at the time 't', sorted by id.
+func (tx *Tx) GetActivitiesAllAt(t time.Time) (activities []*activity.Activity, err error) {
+	return getActivitiesAllAt(tx.scope(), t)
+}
+
+// InsertCalendars inserts the provided calendars in the transaction.
+func (tx *Tx) InsertCalendars(calendars []*calendar.Calendar, duplicateInsertPolicy DuplicateInsertPolicy) (err error) {
+	return insertCalendars(tx.scope(), calendars, duplicateInsertPolicy)
+}
+
+// GetCalendar retrieves the calendar with the specified id in the transaction.
+func (tx *Tx) GetCalendar(id int) (c *calendar.Calendar, err error) {
+	return getCalendar(tx.scope(), id)
+}
+
+// GetCalendarsAll retrieves all calendars in the transaction.
+func (tx *Tx) GetCalendarsAll() (calendars []*calendar.Calendar, err error) {
+	return getCalendarsAll(tx.scope())
+}
+
+// UpdateCalendar updates the calendar with the specified id in the transaction
+// using the information provided in the calendar.
+func (tx *Tx) UpdateCalendar(c *calendar.Calendar, id int) (n int64, err error) {
+	return updateCalendar(tx.scope(), c, id)
+}
+
+// DeleteCalendar deletes the calendar with the specified id in the transaction.
+func (tx *Tx) DeleteCalendar(id int) (n int64, err error) {
+	return deleteCalendar(tx.scope(), id)
+}
+
+// CreateProject creates a project with the specified name, which must be unique, in the transaction.
+func (tx *Tx) CreateProject(name string) (p *Project, err error) {
+	return createProject(tx.Tx, name)
+}
+
+// GetProject retrieves the project with the specified id in the transaction.
+func (tx *Tx) GetProject(id int) (p *Project, err error) {
+	return getProject(tx.Tx, id)
+}
+
+// GetProjectsAll retrieves all projects in the transaction, sorted by id.
+func (tx *Tx) GetProjectsAll() (projects []*Project, err error) {
+	return getProjectsAll(tx.Tx)
+}
+
+// UpdateProjectName updates the name of the project with the specified id in the transaction
+func (tx *Tx) UpdateProjectName(id int, newName string) (n int64, err error) {
+	return updateProjectName(tx.Tx, id, newName)
+}
+
+// CopyProject creates a project with the specified name holding a copy of the activities, relationships
+// and calendars of the project with the specified id in the transaction, and returns the created project.
+func (tx *Tx) CopyProject(id int, name string) (p *Project, err error) {
+	return copyProject(tx.WithProject(id).scope(), name)
+}
+
+// DeleteProject deletes the project with the specified id in the transaction, with its activities, relationships and calendars.
+func (tx *Tx) DeleteProject(id int) (n int64, err error) {
+	return deleteProject(tx.WithProject(id).scope())
+}
@@ -0,0 +1,183 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/vanillaiice/verano/activity"
+	"github.com/vanillaiice/verano/project/calendar"
+)
+
+func TestTxCommit(t *testing.T) {
+	sqldb, err := openDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteDB()
+	defer sqldb.DB.Close()
+
+	tx, err := sqldb.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the successor is inserted before its predecessor, the links being checked on commit
+	if _, err = tx.InsertActivity(&activity.Activity{Id: 2, Description: "pour", PredecessorsId: []int{1}}, None); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tx.InsertActivity(&activity.Activity{Id: 1, Description: "dig"}, None); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.InsertCalendars([]*calendar.Calendar{calendar.New(1, "standard")}, None); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := tx.GetActivity(1); err != nil || !reflect.DeepEqual(a.SuccessorsId, []int{2}) {
+		t.Errorf("got %+v, %v, want the activity to be visible in the transaction", a, err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	acts, err := sqldb.GetActivitiesAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(acts) != 2 || !reflect.DeepEqual(acts[1].PredecessorsId, []int{1}) {
+		t.Errorf("got %+v, want the committed activities", acts)
+	}
+}
+
+func TestTxRollback(t *testing.T) {
+	sqldb, err := openDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteDB()
+	defer sqldb.DB.Close()
+
+	errAbort := errors.New("abort")
+	err = sqldb.InTx(func(tx *Tx) (err error) {
+		if err = tx.InsertActivities([]*activity.Activity{{Id: 1}, {Id: 2, PredecessorsId: []int{1}}}, None); err != nil {
+			return
+		}
+		return errAbort
+	})
+	if err != errAbort {
+		t.Errorf("got %v, want %v", err, errAbort)
+	}
+
+	// the links are checked on commit, which rolls the transaction back
+	err = sqldb.InTx(func(tx *Tx) (err error) {
+		_, err = tx.InsertActivity(&activity.Activity{Id: 3, PredecessorsId: []int{9}}, None)
+		return
+	})
+	if err == nil {
+		t.Error("got no error for a link to a missing activity, want an error")
+	}
+
+	if acts, err := sqldb.GetActivitiesAll(); err != nil || len(acts) != 0 {
+		t.Errorf("got %+v, %v, want no activities", acts, err)
+	}
+}
+
+func TestUpdateIds(t *testing.T) {
+	sqldb, err := openDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteDB()
+	defer sqldb.DB.Close()
+
+	acts := []*activity.Activity{
+		{Id: 1, Description: "dig"},
+		{Id: 2, Description: "pour", PredecessorsId: []int{1}, Relationships: map[int]activity.Relationship{1: {Type: activity.StartToStart}}},
+		{Id: 3, Description: "cure", PredecessorsId: []int{2}},
+	}
+	if err = sqldb.InsertActivities(acts, None); err != nil {
+		t.Fatal(err)
+	}
+
+	// swap 1 and 2, and renumber 3
+	n, err := sqldb.UpdateIds(map[int]int{1: 2, 2: 1, 3: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("got %d, want %d", n, 3)
+	}
+	got, err := sqldb.GetActivitiesAllMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[2].Description != "dig" || got[1].Description != "pour" || got[30].Description != "cure" {
+		t.Errorf("got %+v, want the renumbered activities", got)
+	}
+	if !reflect.DeepEqual(got[1].PredecessorsId, []int{2}) || got[1].Relationship(2).Type != activity.StartToStart {
+		t.Errorf("got %v and %v, want the links to follow the activities", got[1].PredecessorsId, got[1].Relationships)
+	}
+	if !reflect.DeepEqual(got[30].PredecessorsId, []int{1}) {
+		t.Errorf("got %v, want %v", got[30].PredecessorsId, []int{1})
+	}
+
+	// an id already taken makes the whole change fail
+	if _, err = sqldb.UpdateIds(map[int]int{2: 5, 1: 30}); err == nil {
+		t.Error("got no error for a duplicate id, want an error")
+	}
+	if a, err := sqldb.GetActivity(5); err != nil || a.Description != "" {
+		t.Errorf("got %+v, %v, want the change to be rolled back", a, err)
+	}
+}
+
+func TestTxProjects(t *testing.T) {
+	sqldb, err := openDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteDB()
+	defer sqldb.DB.Close()
+
+	if err = sqldb.InsertActivities([]*activity.Activity{{Id: 1, Description: "dig"}}, None); err != nil {
+		t.Fatal(err)
+	}
+
+	// the project is created and filled atomically
+	var created *Project
+	err = sqldb.InTx(func(tx *Tx) (err error) {
+		if created, err = tx.CreateProject("extension"); err != nil {
+			return
+		}
+		_, err = tx.WithProject(created.Id).InsertActivity(&activity.Activity{Id: 1, Description: "survey"}, None)
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, err := sqldb.WithProject(created.Id).GetActivity(1); err != nil || a.Description != "survey" {
+		t.Errorf("got %+v, %v, want the activity of the created project", a, err)
+	}
+
+	errAbort := errors.New("abort")
+	err = sqldb.InTx(func(tx *Tx) (err error) {
+		copied, err := tx.CopyProject(DefaultProjectId, "copy")
+		if err != nil {
+			return
+		}
+		if _, err = tx.WithProject(copied.Id).UpdateDescription(1, "excavate"); err != nil {
+			return
+		}
+		if _, err = tx.DeleteProject(created.Id); err != nil {
+			return
+		}
+		return errAbort
+	})
+	if err != errAbort {
+		t.Errorf("got %v, want %v", err, errAbort)
+	}
+	projects, err := sqldb.GetProjectsAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != 2 || projects[1].Name != "extension" {
+		t.Errorf("got %+v, want the changes of the projects to be rolled back", projects)
+	}
+}
@@ -7,44 +7,49 @@ import (
 	"time"
 
 	"github.com/vanillaiice/verano/parser/pcsv"
-	"github.com/vanillaiice/verano/project/timeline"
-	"github.com/vanillaiice/verano/sorter"
-	"github.com/vanillaiice/verano/util"
+	"github.com/vanillaiice/verano/parser/tabular"
+	"github.com/vanillaiice/verano/project"
 )
 
-// List of activities in CSV format
+// List of activities in CSV format, with durations in working days and hours
 var scsv = `Id,Description,Duration,Start,Finish,PredecessorsId,SuccessorsId,Cost
-1,Tip landlord,12h0s,-62135596800,-62135596800,2,3,1000000
-2,Get money,6h0s,-62135596800,-62135596800,,1,0
-3,Edge,12h0s,-62135596800,-62135596800,1,,1000
+1,Tip landlord,1d 4h,,,2,3,1000000
+2,Get money,6h,,,,1,0
+3,Edge,1.5d,,,1,,1000
 `
 
 func main() {
-	// Parse activities in CSV format to an activity slice
+	// Parse activities in CSV format to an activity slice, with dates such as "2026-03-01 08:00"
+	format := &pcsv.Format{DateLayout: "2006-01-02 15:04", DurationUnit: pcsv.Days}
 	r := bytes.NewReader([]byte(scsv))
-	activities, err := pcsv.CSVToActivities(r)
+	activities, _, _, err := pcsv.CSVToActivitiesWithFormat(r, tabular.DefaultMapping(), format)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// Convert the activity slice to a graph
-	activitiesGraph, err := util.ActivitiesToGraph(activities)
+	// Create a project holding the activities, starting now
+	p, err := project.New("eggs", time.Now(), activities)
 	if err != nil {
 		log.Fatal(err)
 	}
-	// Sort the activities by dependencies using topological sorting on the graph
-	order := sorter.SortActivitiesByDeps(activitiesGraph)
 
-	// Define the start of the project
-	projectStartDate := time.Now()
-	// convert the activity slice to a map with the activity Id as key
-	// and the activity as the value
-	activitiesMap := util.ActivitiesToMap(activities)
-	// Update the Start and Finish time of the activities after getting sorted
-	timeline.UpdateStartFinishTime(activitiesMap, order, projectStartDate)
+	// Compute the start and finish times, the late start and finish times,
+	// and the float of the activities
+	for _, v := range p.Schedule() {
+		fmt.Printf("Constraint violation: %v\n", v)
+	}
 
 	// Print activities in order
-	for i, o := range order {
-		fmt.Printf("Activity #%d: %v\n", i+1, activitiesMap[o])
+	for i, o := range p.Order() {
+		a, err := p.Activity(o)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Activity #%d: %v\n", i+1, a)
+	}
+
+	// Print the critical path(s) of the project
+	for _, path := range p.CriticalPath() {
+		fmt.Printf("Critical path: %v\n", path)
 	}
 }
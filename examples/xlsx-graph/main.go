@@ -9,7 +9,7 @@ import (
 	"github.com/tealeg/xlsx/v3"
 	"github.com/vanillaiice/verano/graph"
 	"github.com/vanillaiice/verano/parser/pxlsx"
-	"github.com/vanillaiice/verano/project/timeline"
+	"github.com/vanillaiice/verano/project"
 	"github.com/vanillaiice/verano/sorter"
 	"github.com/vanillaiice/verano/util"
 )
@@ -30,17 +30,15 @@ func main() {
 	// put the activities in a slice
 	activities, err := pxlsx.XLSXToActivities(sheet)
 	die(err)
-	// convert the activities to a graph in order to sort them
-	activitiesGraph, err := util.ActivitiesToGraph(activities)
+	// create a project holding the activities, starting now
+	p, err := project.New("activities", time.Now(), activities)
 	die(err)
-	// get the order of the activities according to their dependencies
-	order := sorter.SortActivitiesByDeps(activitiesGraph)
-	// convert the activities to a map to sort them by order
-	activitiesMap := util.ActivitiesToMap(activities)
-	// define the start date of the project
-	projectStartDate := time.Now()
 	// update the start and finish times of all activities
-	timeline.UpdateStartFinishTime(activitiesMap, order, projectStartDate)
+	p.Schedule()
+	// get the order of the activities according to their dependencies
+	order := p.Order()
+	// get the activities in a map
+	activitiesMap := p.ActivitiesMap()
 	// init a graphviz graph
 	gviz := graphviz.New()
 	g, err := gviz.Graph()
@@ -27,7 +27,9 @@ func DrawAndRender(graph *graphviz.Graphviz, activitiesMap map[int]*activity.Act
 
 // Draw draws a graphviz graph from a map of activities.
 // The graph shows the relationships between activities,
-// and the order of activities.
+// and the order of activities. Critical activities are drawn in red
+// once their float has been computed. Edges are labeled with the type and lag
+// of the relationship, unless it is finish to start without lag.
 func Draw(graph *cgraph.Graph, activities map[int]*activity.Activity) (err error) {
 	graph.SetRankDir(cgraph.LRRank)
 	for k, v := range activities {
@@ -36,6 +38,9 @@ func Draw(graph *cgraph.Graph, activities map[int]*activity.Activity) (err error
 			return err
 		}
 		node.SetLabel(fmt.Sprintf("%s, FOR %s, START @%s, FINISH @%s", v.Description, v.Duration.String(), v.Start.Format(timeFormat), v.Finish.Format(timeFormat)))
+		if !v.LateFinish.IsZero() && v.IsCritical() {
+			node.SetColor("red")
+		}
 	}
 	for k, v := range activities {
 		node, err := graph.Node(fmt.Sprint(k))
@@ -47,9 +52,15 @@ func Draw(graph *cgraph.Graph, activities map[int]*activity.Activity) (err error
 			if err != nil {
 				return err
 			}
-			if _, err = graph.CreateEdge("", node, node2); err != nil {
+			edge, err := graph.CreateEdge("", node, node2)
+			if err != nil {
 				return err
 			}
+			if successor, ok := activities[successorsId]; ok {
+				if rel := successor.Relationship(k); rel != (activity.Relationship{}) {
+					edge.SetLabel(rel.String())
+				}
+			}
 		}
 	}
 	return
@@ -0,0 +1,68 @@
+// Package activitytest helps testing that every field of activities survives a round trip
+// through a format or the database.
+package activitytest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vanillaiice/verano/activity"
+)
+
+// Full returns an activity whose fields all hold a value other than their zero value.
+// The dates and durations are whole seconds, the finest precision stored by the formats.
+func Full() *activity.Activity {
+	t := time.Date(2026, time.March, 2, 8, 0, 0, 0, time.UTC)
+	return &activity.Activity{
+		Id:             4,
+		Description:    "Pour concrete",
+		Wbs:            "1.2",
+		Duration:       26 * time.Hour,
+		CalendarId:     1,
+		Start:          t,
+		Finish:         t.Add(50 * time.Hour),
+		LateStart:      t.Add(24 * time.Hour),
+		LateFinish:     t.Add(74 * time.Hour),
+		TotalFloat:     24 * time.Hour,
+		FreeFloat:      90 * time.Minute,
+		PredecessorsId: []int{2},
+		SuccessorsId:   []int{3},
+		Relationships:  map[int]activity.Relationship{2: {Type: activity.StartToStart, Lag: 5 * time.Minute}},
+		ConstraintType: activity.StartNoEarlierThan,
+		ConstraintDate: t,
+		Progress:       0.25,
+		ActualStart:    t.Add(time.Hour),
+		ActualFinish:   t.Add(48 * time.Hour),
+		Cost:           12.5,
+		Code:           "A1040",
+	}
+}
+
+// Check reports the fields of 'got' that differ from 'want', the dates being compared with time.Time.Equal.
+// It also reports the fields of 'want' holding their zero value, so that a field added to activity.Activity
+// fails the round trip tests until it is given a value in Full, and is supported by the tested format.
+func Check(t testing.TB, got, want *activity.Activity) {
+	t.Helper()
+	if got == nil {
+		t.Errorf("got no activity, want %+v", want)
+		return
+	}
+
+	g, w := reflect.ValueOf(got).Elem(), reflect.ValueOf(want).Elem()
+	for i := 0; i < w.NumField(); i++ {
+		name := w.Type().Field(i).Name
+		if w.Field(i).IsZero() {
+			t.Errorf("%s: want a value other than the zero value", name)
+			continue
+		}
+		gf, wf := g.Field(i).Interface(), w.Field(i).Interface()
+		if wt, ok := wf.(time.Time); ok {
+			if !gf.(time.Time).Equal(wt) {
+				t.Errorf("%s: got %v, want %v", name, gf, wf)
+			}
+		} else if !reflect.DeepEqual(gf, wf) {
+			t.Errorf("%s: got %v, want %v", name, gf, wf)
+		}
+	}
+}
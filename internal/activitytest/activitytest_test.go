@@ -0,0 +1,21 @@
+package activitytest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	Check(t, Full(), Full())
+
+	got := Full()
+	got.Start = got.Start.In(time.FixedZone("UTC+2", 2*60*60))
+	Check(t, got, Full())
+
+	rec := &testing.T{}
+	got.Progress = 0.5
+	Check(rec, got, Full())
+	if !rec.Failed() {
+		t.Error("got no failure for a different progress, want a failure")
+	}
+}
@@ -0,0 +1,159 @@
+package pcsv
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DurationUnit is the largest unit of the durations written to csv files.
+type DurationUnit int
+
+const (
+	GoDuration DurationUnit = 0 // Go duration strings (e.g. "27h30m0s")
+	Hours      DurationUnit = 1 // Hours and minutes (e.g. "27h 30m")
+	Days       DurationUnit = 2 // Working days, hours and minutes (e.g. "3d 3h 30m")
+	Weeks      DurationUnit = 3 // Working weeks, days, hours and minutes (e.g. "1w 2d")
+)
+
+// Format defines how the dates and durations of activities are written to and read from csv files.
+// The zero value is the historical format, with dates as Unix seconds and durations as Go duration strings.
+//
+// Durations are always read in any of the formats, so that "3d", "1w 2d 4h", "1.5d" and "12h0s" are all valid.
+type Format struct {
+	DateLayout   string         // Layout of the dates (e.g. "2006-01-02 15:04"), dates are Unix seconds if empty
+	Location     *time.Location // Time zone of the dates read and written with the layout, time.Local if nil
+	DurationUnit DurationUnit   // Largest unit of the durations written
+	HoursPerDay  float64        // Working hours in a day, 8 if zero
+	DaysPerWeek  float64        // Working days in a week, 5 if zero
+}
+
+// DefaultFormat returns the format used when none is provided, with dates as Unix seconds
+// and durations as Go duration strings.
+func DefaultFormat() *Format {
+	return &Format{}
+}
+
+// formatDate returns the csv representation of the date 't'. The zero time is written as an empty cell
+// when the dates are written with a layout.
+func (f *Format) formatDate(t time.Time) string {
+	if f.DateLayout == "" {
+		return fmt.Sprint(t.Unix())
+	}
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(f.location()).Format(f.DateLayout)
+}
+
+// parseDate parses the csv representation of a date 'value'.
+func (f *Format) parseDate(value string) (t time.Time, err error) {
+	if f.DateLayout == "" {
+		return parseUnix(value)
+	}
+	return time.ParseInLocation(f.DateLayout, value, f.location())
+}
+
+// location returns the time zone of the dates.
+func (f *Format) location() *time.Location {
+	if f.Location == nil {
+		return time.Local
+	}
+	return f.Location
+}
+
+// day returns the duration of a working day.
+func (f *Format) day() time.Duration {
+	if f.HoursPerDay == 0 {
+		return 8 * time.Hour
+	}
+	return time.Duration(f.HoursPerDay * float64(time.Hour))
+}
+
+// week returns the duration of a working week.
+func (f *Format) week() time.Duration {
+	if f.DaysPerWeek == 0 {
+		return 5 * f.day()
+	}
+	return time.Duration(f.DaysPerWeek * float64(f.day()))
+}
+
+// formatDuration returns the csv representation of the duration 'd', starting with the largest unit of the format
+// and omitting the units whose value is zero (e.g. "1w 2d 30m"). The part of the duration below the second,
+// if any, is written as a Go duration string (e.g. "1d 1.5s").
+func (f *Format) formatDuration(d time.Duration) string {
+	if f.DurationUnit <= GoDuration || f.DurationUnit > Weeks {
+		return d.String()
+	}
+
+	units := []struct {
+		name string
+		d    time.Duration
+	}{{"w", f.week()}, {"d", f.day()}, {"h", time.Hour}, {"m", time.Minute}}
+	units = units[Weeks-f.DurationUnit:]
+
+	var parts []string
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	for _, u := range units {
+		if n := d / u.d; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d%s", n, u.name))
+			d -= n * u.d
+		}
+	}
+	if d > 0 {
+		parts = append(parts, d.String())
+	}
+	if len(parts) == 0 {
+		return "0" + units[0].name
+	}
+	return sign + strings.Join(parts, " ")
+}
+
+var (
+	durationFormat = regexp.MustCompile(`^(\s*\d+(\.\d+)?\s*[a-zµ]+)+\s*$`)
+	durationPart   = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*([a-zµ]+)`)
+)
+
+// parseDuration parses the csv representation of a duration 'value', either a Go duration string (e.g. "12h0s"),
+// or numbers followed by units, with the w (working weeks) and d (working days) units in addition to the units
+// of Go duration strings (e.g. "1w 2.5d 4h").
+func (f *Format) parseDuration(value string) (d time.Duration, err error) {
+	if d, err = time.ParseDuration(value); err == nil {
+		return
+	}
+
+	s := strings.TrimSpace(value)
+	sign := time.Duration(1)
+	if strings.HasPrefix(s, "-") {
+		sign, s = -1, s[1:]
+	}
+	if !durationFormat.MatchString(s) {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	d = 0
+	for _, part := range durationPart.FindAllStringSubmatch(s, -1) {
+		n, err := strconv.ParseFloat(part[1], 64)
+		if err != nil {
+			return 0, err
+		}
+		switch part[2] {
+		case "w":
+			d += time.Duration(n * float64(f.week()))
+		case "d":
+			d += time.Duration(n * float64(f.day()))
+		default:
+			unit, err := time.ParseDuration("1" + part[2])
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q: unknown unit %q", value, part[2])
+			}
+			d += time.Duration(n * float64(unit))
+		}
+	}
+	return sign * d, nil
+}
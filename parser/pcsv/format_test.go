@@ -0,0 +1,87 @@
+package pcsv
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		unit DurationUnit
+		d    time.Duration
+		want string
+	}{
+		{GoDuration, 12 * time.Hour, "12h0m0s"},
+		{Hours, 27*time.Hour + 30*time.Minute, "27h 30m"},
+		{Days, 27*time.Hour + 30*time.Minute, "3d 3h 30m"},
+		{Weeks, 7 * 8 * time.Hour, "1w 2d"},
+		{Days, 0, "0d"},
+		{Hours, 90 * time.Second, "1m 30s"},
+		{Days, -16 * time.Hour, "-2d"},
+	}
+	for _, test := range tests {
+		f := &Format{DurationUnit: test.unit}
+		if got := f.formatDuration(test.d); got != test.want {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+		if got, err := f.parseDuration(test.want); err != nil || got != test.d {
+			t.Errorf("%q: got %v, %v, want %v", test.want, got, err, test.d)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	f := &Format{HoursPerDay: 10, DaysPerWeek: 4}
+	tests := []struct {
+		s    string
+		want time.Duration
+	}{
+		{"12h0s", 12 * time.Hour},
+		{"3d", 30 * time.Hour},
+		{"1.5d", 15 * time.Hour},
+		{"1w 2d 4h", 64 * time.Hour},
+		{" 2 d 30m ", 20*time.Hour + 30*time.Minute},
+	}
+	for _, test := range tests {
+		got, err := f.parseDuration(test.s)
+		if err != nil {
+			t.Errorf("%q: %v", test.s, err)
+		}
+		if got != test.want {
+			t.Errorf("got %v, want %v", got, test.want)
+		}
+	}
+
+	for _, s := range []string{"", "3", "d", "3x", "1mo", "3d-2h"} {
+		if _, err := f.parseDuration(s); err == nil {
+			t.Errorf("got no error for %q, want an error", s)
+		}
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip(err)
+	}
+	date := time.Date(2026, time.March, 1, 7, 0, 0, 0, time.UTC)
+
+	f := &Format{DateLayout: "2006-01-02 15:04", Location: paris}
+	if got := f.formatDate(date); got != "2026-03-01 08:00" {
+		t.Errorf("got %q, want %q", got, "2026-03-01 08:00")
+	}
+	if got, err := f.parseDate("2026-03-01 08:00"); err != nil || !got.Equal(date) {
+		t.Errorf("got %v, %v, want %v", got, err, date)
+	}
+	if got := f.formatDate(time.Time{}); got != "" {
+		t.Errorf("got %q, want an empty date", got)
+	}
+
+	f = DefaultFormat()
+	if got := f.formatDate(date); got != "1772348400" {
+		t.Errorf("got %q, want %q", got, "1772348400")
+	}
+	if got, err := f.parseDate("1772348400"); err != nil || !got.Equal(date) {
+		t.Errorf("got %v, %v, want %v", got, err, date)
+	}
+}
@@ -9,10 +9,14 @@ import (
 
 	"github.com/vanillaiice/verano/activity"
 	"github.com/vanillaiice/verano/db"
+	"github.com/vanillaiice/verano/parser/tabular"
+	"github.com/vanillaiice/verano/project/calendar"
 	"github.com/vanillaiice/verano/util"
 )
 
-var recordHeader = []string{"Id", "Description", "Duration", "Start", "Finish", "PredecessorsId", "SuccessorsId", "Cost"}
+var recordHeader = tabular.Header()
+
+var calendarRecordHeader = []string{"Id", "Name", "Default", "WorkDays", "Shifts", "Holidays"}
 
 // ExportToDb populates the database with activities in csv format.
 func ExportToDb(sqldb *db.DB, reader io.Reader, duplicateInsertPolicy db.DuplicateInsertPolicy) (err error) {
@@ -23,101 +27,276 @@ func ExportToDb(sqldb *db.DB, reader io.Reader, duplicateInsertPolicy db.Duplica
 	return sqldb.InsertActivities(activities, duplicateInsertPolicy)
 }
 
-// ActivitiesToCSV converts a slice of activities to csv format.
+// ActivitiesToCSV converts a slice of activities to csv format, with the default format.
 func ActivitiesToCSV(activities []*activity.Activity, w io.Writer) (err error) {
+	return ActivitiesToCSVWithFormat(activities, w, DefaultFormat())
+}
+
+// ActivitiesToCSVWithFormat converts a slice of activities to csv format, writing the dates and durations
+// with the 'format'.
+func ActivitiesToCSVWithFormat(activities []*activity.Activity, w io.Writer, format *Format) (err error) {
 	var records [][]string
 	records = append(records, recordHeader)
 	for _, act := range activities {
-		records = append(records, activityToRecord(act))
+		records = append(records, activityToRecord(act, format))
 	}
 	writer := csv.NewWriter(w)
 	defer writer.Flush()
 	return writer.WriteAll(records)
 }
 
-// CSVToActivities converts csv format to a slice of activities.
+// CSVToActivities converts csv format to a slice of activities, with the default column mapping.
 func CSVToActivities(reader io.Reader) (activities []*activity.Activity, err error) {
+	activities, _, _, err = CSVToActivitiesWithMapping(reader, tabular.DefaultMapping())
+	return
+}
+
+// CSVToActivitiesWithMapping converts csv format to a slice of activities, mapping the columns to the fields
+// of the activities from the header with the 'mapping'. The values of the unknown columns captured by the mapping
+// are returned in 'unknown', at the index of their activity. The invalid values of all the rows are listed in
+// 'report', and the invalid rows are either skipped or make the import fail, depending on the mode of the mapping.
+func CSVToActivitiesWithMapping(reader io.Reader, mapping *tabular.Mapping) (activities []*activity.Activity, unknown []map[string]string, report *tabular.Report, err error) {
+	return CSVToActivitiesWithFormat(reader, mapping, DefaultFormat())
+}
+
+// CSVToActivitiesWithFormat is like CSVToActivitiesWithMapping, reading the dates and durations with the 'format'.
+func CSVToActivitiesWithFormat(reader io.Reader, mapping *tabular.Mapping, format *Format) (activities []*activity.Activity, unknown []map[string]string, report *tabular.Report, err error) {
 	csvReader := csv.NewReader(reader)
-	records, err := csvReader.ReadAll()
-	if err != nil {
-		return
-	}
-	for _, record := range records {
-		if record[0] == "Id" {
+	// the rows of files with extra or missing optional columns may have different lengths
+	csvReader.FieldsPerRecord = -1
+	report = &tabular.Report{}
+	var layout *tabular.Layout
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return activities, unknown, report, err
+		}
+		if layout == nil {
+			if layout, err = mapping.Layout(record); err != nil {
+				return activities, unknown, report, err
+			}
+			if layout.HasHeader() {
+				continue
+			}
+		}
+
+		report.Rows++
+		row, _ := csvReader.FieldPos(0)
+		act, errs := recordToActivity(record, row, layout, mapping, format)
+		if len(errs) > 0 {
+			report.Errors = append(report.Errors, errs...)
 			continue
 		}
-		activity, err := recordToActivity(record)
+		activities = append(activities, act)
+		unknown = append(unknown, layout.Unknown(record))
+	}
+
+	if err = report.Err(); err != nil && mapping.Mode == tabular.Strict {
+		return nil, nil, report, err
+	}
+	return activities, unknown, report, nil
+}
+
+// recordToActivity converts a record, the row number 'row' of the file, to an Activity pointer,
+// with the columns of 'layout', the default values of 'mapping' and the dates and durations of 'format'.
+// It returns the errors of all the invalid values of the record.
+func recordToActivity(record []string, row int, layout *tabular.Layout, mapping *tabular.Mapping, format *Format) (act *activity.Activity, errs []tabular.RowError) {
+	act = mapping.NewActivity()
+	for _, f := range tabular.Fields() {
+		value, ok, err := layout.Value(record, f)
+		if err == nil && ok {
+			err = setField(act, f, value, format)
+		}
 		if err != nil {
-			return activities, err
+			errs = append(errs, layout.RowError(row, record, f, err))
 		}
-		activities = append(activities, activity)
+	}
+	if act.PredecessorsId == nil {
+		act.PredecessorsId = []int{}
+	}
+	if act.SuccessorsId == nil {
+		act.SuccessorsId = []int{}
 	}
 	return
 }
 
-// recordToActivity converts a record to an Activity pointer.
-func recordToActivity(record []string) (act *activity.Activity, err error) {
-	id, err := strconv.Atoi(record[0])
+// setField sets the field 'f' of the activity 'act' from its csv representation 'value', written with the 'format'.
+func setField(act *activity.Activity, f tabular.Field, value string, format *Format) (err error) {
+	switch f {
+	case tabular.Id:
+		act.Id, err = strconv.Atoi(value)
+	case tabular.Description:
+		act.Description = value
+	case tabular.Duration:
+		act.Duration, err = format.parseDuration(value)
+	case tabular.Start:
+		act.Start, err = format.parseDate(value)
+	case tabular.Finish:
+		act.Finish, err = format.parseDate(value)
+	case tabular.PredecessorsId:
+		act.PredecessorsId, err = util.Unflat(value)
+	case tabular.SuccessorsId:
+		act.SuccessorsId, err = util.Unflat(value)
+	case tabular.Cost:
+		act.Cost, err = strconv.ParseFloat(value, 64)
+	case tabular.Relationships:
+		act.Relationships, err = util.UnflatRelationships(value)
+	case tabular.CalendarId:
+		act.CalendarId, err = strconv.Atoi(value)
+	case tabular.Progress:
+		var progress float64
+		progress, err = strconv.ParseFloat(value, 32)
+		act.Progress = float32(progress)
+	case tabular.ActualStart:
+		act.ActualStart, err = format.parseDate(value)
+	case tabular.ActualFinish:
+		act.ActualFinish, err = format.parseDate(value)
+	case tabular.Wbs:
+		act.Wbs = value
+	case tabular.LateStart:
+		act.LateStart, err = format.parseDate(value)
+	case tabular.LateFinish:
+		act.LateFinish, err = format.parseDate(value)
+	case tabular.TotalFloat:
+		act.TotalFloat, err = format.parseDuration(value)
+	case tabular.FreeFloat:
+		act.FreeFloat, err = format.parseDuration(value)
+	case tabular.ConstraintType:
+		act.ConstraintType, err = activity.ParseConstraintType(value)
+	case tabular.ConstraintDate:
+		act.ConstraintDate, err = format.parseDate(value)
+	case tabular.Code:
+		act.Code = value
+	}
+	return
+}
+
+// parseUnix parses a date written as a number of seconds since the Unix epoch.
+func parseUnix(value string) (t time.Time, err error) {
+	seconds, err := strconv.ParseInt(value, 10, 64)
 	if err != nil {
 		return
 	}
+	return time.Unix(seconds, 0), nil
+}
 
-	duration, err := time.ParseDuration(record[2])
+// activityToRecord converts an Activity struct to a slice of strings, with the dates and durations of 'format'.
+func activityToRecord(act *activity.Activity, format *Format) []string {
+	return []string{
+		fmt.Sprint(act.Id),
+		act.Description,
+		format.formatDuration(act.Duration),
+		format.formatDate(act.Start),
+		format.formatDate(act.Finish),
+		util.Flat(act.PredecessorsId),
+		util.Flat(act.SuccessorsId),
+		fmt.Sprint(act.Cost),
+		util.FlatRelationships(act.Relationships),
+		fmt.Sprint(act.CalendarId),
+		fmt.Sprint(act.Progress),
+		format.formatDate(act.ActualStart),
+		format.formatDate(act.ActualFinish),
+		act.Wbs,
+		format.formatDate(act.LateStart),
+		format.formatDate(act.LateFinish),
+		format.formatDuration(act.TotalFloat),
+		format.formatDuration(act.FreeFloat),
+		act.ConstraintType.String(),
+		format.formatDate(act.ConstraintDate),
+		act.Code,
+	}
+}
+
+// ExportCalendarsToDb populates the database with calendars in csv format.
+func ExportCalendarsToDb(sqldb *db.DB, reader io.Reader, duplicateInsertPolicy db.DuplicateInsertPolicy) (err error) {
+	calendars, err := CSVToCalendars(reader)
 	if err != nil {
 		return
 	}
+	return sqldb.InsertCalendars(calendars, duplicateInsertPolicy)
+}
 
-	startTimeInt64, err := strconv.ParseInt(record[3], 10, 64)
+// CalendarsToCSV converts a slice of calendars to csv format.
+func CalendarsToCSV(calendars []*calendar.Calendar, w io.Writer) (err error) {
+	var records [][]string
+	records = append(records, calendarRecordHeader)
+	for _, c := range calendars {
+		records = append(records, []string{
+			fmt.Sprint(c.Id),
+			c.Name,
+			fmt.Sprint(c.Default),
+			calendar.FormatWorkDays(c.WorkDays),
+			calendar.FormatShifts(c.Shifts),
+			calendar.FormatHolidays(c.Holidays),
+		})
+	}
+	writer := csv.NewWriter(w)
+	defer writer.Flush()
+	return writer.WriteAll(records)
+}
+
+// CSVToCalendars converts csv format to a slice of calendars.
+func CSVToCalendars(reader io.Reader) (calendars []*calendar.Calendar, err error) {
+	csvReader := csv.NewReader(reader)
+	records, err := csvReader.ReadAll()
 	if err != nil {
 		return
 	}
-	startTime := time.Unix(startTimeInt64, 0)
+	for _, record := range records {
+		if record[0] == "Id" {
+			continue
+		}
+		c, err := recordToCalendar(record)
+		if err != nil {
+			return calendars, err
+		}
+		calendars = append(calendars, c)
+	}
+	return
+}
 
-	finishTimeInt64, err := strconv.ParseInt(record[4], 10, 64)
+// recordToCalendar converts a record to a Calendar pointer.
+func recordToCalendar(record []string) (c *calendar.Calendar, err error) {
+	if len(record) != len(calendarRecordHeader) {
+		return nil, fmt.Errorf("wrong number of fields in calendar record, want %d, got %d", len(calendarRecordHeader), len(record))
+	}
+
+	id, err := strconv.Atoi(record[0])
 	if err != nil {
 		return
 	}
-	finishTime := time.Unix(finishTimeInt64, 0)
 
-	predecessors, err := util.Unflat(record[5])
+	isDefault, err := strconv.ParseBool(record[2])
 	if err != nil {
 		return
 	}
 
-	successors, err := util.Unflat(record[6])
+	workDays, err := calendar.ParseWorkDays(record[3])
 	if err != nil {
 		return
 	}
 
-	cost, err := strconv.ParseFloat(record[7], 64)
+	shifts, err := calendar.ParseShifts(record[4])
 	if err != nil {
 		return
 	}
 
-	act = &activity.Activity{
-		Id:             id,
-		Description:    record[1],
-		Duration:       duration,
-		Start:          startTime,
-		Finish:         finishTime,
-		PredecessorsId: predecessors,
-		SuccessorsId:   successors,
-		Cost:           cost,
+	holidays, err := calendar.ParseHolidays(record[5])
+	if err != nil {
+		return
 	}
 
-	return act, nil
-}
-
-// activityToRecord converts an Activity struct to a slice of strings.
-func activityToRecord(act *activity.Activity) []string {
-	return []string{
-		fmt.Sprint(act.Id),
-		act.Description,
-		act.Duration.String(),
-		fmt.Sprint(act.Start.Unix()),
-		fmt.Sprint(act.Finish.Unix()),
-		util.Flat(act.PredecessorsId),
-		util.Flat(act.SuccessorsId),
-		fmt.Sprint(act.Cost),
+	c = &calendar.Calendar{
+		Id:       id,
+		Name:     record[1],
+		Default:  isDefault,
+		WorkDays: workDays,
+		Shifts:   shifts,
+		Holidays: holidays,
 	}
+
+	return c, nil
 }
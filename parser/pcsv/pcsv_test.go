@@ -4,25 +4,35 @@ import (
 	"bufio"
 	"bytes"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/vanillaiice/verano/activity"
 	"github.com/vanillaiice/verano/db"
+	"github.com/vanillaiice/verano/internal/activitytest"
+	"github.com/vanillaiice/verano/parser/tabular"
 )
 
-var scsv = `Id,Description,Duration,Start,Finish,PredecessorsId,SuccessorsId,Cost
-3,Cook eggs,10m0s,-62135596800,-62135596800,2,1,0
-2,Buy eggs,30m0s,-62135596800,-62135596800,,3,100
-1,Eat eggs,20m0s,-62135596800,-62135596800,3,,0
+var scsv = `Id,Description,Duration,Start,Finish,PredecessorsId,SuccessorsId,Cost,Relationships,CalendarId,Progress,ActualStart,ActualFinish,Wbs,LateStart,LateFinish,TotalFloat,FreeFloat,ConstraintType,ConstraintDate,Code
+3,Cook eggs,10m0s,-62135596800,-62135596800,2,1,0,2:SS:5m0s,1,0.5,1704443400,-62135596800,1.2,-62135596800,-62135596800,0s,0s,,-62135596800,
+2,Buy eggs,30m0s,-62135596800,-62135596800,,3,100,,0,1,1704441600,1704443400,1.1,-62135596800,-62135596800,0s,0s,,-62135596800,
+1,Eat eggs,20m0s,-62135596800,-62135596800,3,,0,,0,0,-62135596800,-62135596800,,-62135596800,-62135596800,0s,0s,,-62135596800,
+`
+var scsvCalendars = `Id,Name,Default,WorkDays,Shifts,Holidays
+1,standard,true,"Mon,Tue,Wed,Thu,Fri","08:00-12:00,13:00-17:00","2024-12-25,2025-01-01"
+2,weekend,false,"Sat,Sun",07:00-19:00,
 `
 var d1 = time.Minute * 10
 var d2 = time.Minute * 30
 var d3 = time.Minute * 20
 var tt = time.Time{}
+var t1 = time.Unix(1704441600, 0)
+var t2 = time.Unix(1704443400, 0)
 var activities = []*activity.Activity{
-	{Id: 3, Description: "Cook eggs", Duration: d1, PredecessorsId: []int{2}, SuccessorsId: []int{1}, Start: tt, Finish: tt, Cost: 0},
-	{Id: 2, Description: "Buy eggs", Duration: d2, PredecessorsId: []int{}, SuccessorsId: []int{3}, Start: tt, Finish: tt, Cost: 100},
+	{Id: 3, Description: "Cook eggs", Wbs: "1.2", Duration: d1, PredecessorsId: []int{2}, SuccessorsId: []int{1}, Relationships: map[int]activity.Relationship{2: {Type: activity.StartToStart, Lag: 5 * time.Minute}}, CalendarId: 1, Start: tt, Finish: tt, Progress: 0.5, ActualStart: t2, Cost: 0},
+	{Id: 2, Description: "Buy eggs", Wbs: "1.1", Duration: d2, PredecessorsId: []int{}, SuccessorsId: []int{3}, Start: tt, Finish: tt, Progress: 1, ActualStart: t1, ActualFinish: t2, Cost: 100},
 	{Id: 1, Description: "Eat eggs", Duration: d3, PredecessorsId: []int{3}, SuccessorsId: []int{}, Start: tt, Finish: tt, Cost: 0},
 }
 
@@ -66,5 +76,199 @@ func TestCSVToActivities(t *testing.T) {
 		if acts[i].Id != activities[i].Id {
 			t.Errorf("got %+v, want %+v", acts[i], activities[i])
 		}
+		if acts[i].Relationship(2) != activities[i].Relationship(2) {
+			t.Errorf("relationship: got %v, want %v", acts[i].Relationship(2), activities[i].Relationship(2))
+		}
+		if acts[i].CalendarId != activities[i].CalendarId {
+			t.Errorf("calendar: got %d, want %d", acts[i].CalendarId, activities[i].CalendarId)
+		}
+		if acts[i].Wbs != activities[i].Wbs {
+			t.Errorf("wbs: got %q, want %q", acts[i].Wbs, activities[i].Wbs)
+		}
+		if acts[i].Progress != activities[i].Progress {
+			t.Errorf("progress: got %v, want %v", acts[i].Progress, activities[i].Progress)
+		}
+		if !acts[i].ActualStart.Equal(activities[i].ActualStart) || !acts[i].ActualFinish.Equal(activities[i].ActualFinish) {
+			t.Errorf("actual dates: got %v and %v, want %v and %v", acts[i].ActualStart, acts[i].ActualFinish, activities[i].ActualStart, activities[i].ActualFinish)
+		}
+	}
+}
+
+func TestCSVToActivitiesWithoutRelationships(t *testing.T) {
+	s := `Id,Description,Duration,Start,Finish,PredecessorsId,SuccessorsId,Cost
+1,Tip landlord,12h0s,-62135596800,-62135596800,2,,1000000
+2,Get money,6h0s,-62135596800,-62135596800,,1,0
+`
+	acts, err := CSVToActivities(bytes.NewReader([]byte(s)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(acts) != 2 {
+		t.Fatalf("got %d activities, want %d", len(acts), 2)
+	}
+	if acts[0].Relationships != nil {
+		t.Errorf("got %v, want no relationships", acts[0].Relationships)
+	}
+}
+
+func TestCSVToActivitiesWithMapping(t *testing.T) {
+	s := `Durée,Remarque,Identifiant,Libellé,Prédécesseurs
+1h0m0s,urgent,1,Buy eggs,
+,,2,Cook eggs,1
+`
+	mapping := &tabular.Mapping{
+		Headers:  tabular.FrenchHeaders,
+		Required: []tabular.Field{tabular.Id},
+		Defaults: &activity.Activity{Duration: 30 * time.Minute, CalendarId: 1},
+		Unknown:  tabular.CaptureUnknown,
+	}
+	acts, unknown, _, err := CSVToActivitiesWithMapping(bytes.NewReader([]byte(s)), mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(acts) != 2 {
+		t.Fatalf("got %d activities, want %d", len(acts), 2)
+	}
+	want := []*activity.Activity{
+		{Id: 1, Description: "Buy eggs", Duration: time.Hour, CalendarId: 1, PredecessorsId: []int{}, SuccessorsId: []int{}},
+		{Id: 2, Description: "Cook eggs", Duration: 30 * time.Minute, CalendarId: 1, PredecessorsId: []int{1}, SuccessorsId: []int{}},
+	}
+	if !reflect.DeepEqual(acts, want) {
+		t.Errorf("got %+v, want %+v", acts, want)
+	}
+	wantUnknown := []map[string]string{{"Remarque": "urgent"}, {"Remarque": ""}}
+	if !reflect.DeepEqual(unknown, wantUnknown) {
+		t.Errorf("got %v, want %v", unknown, wantUnknown)
+	}
+
+	if _, err = CSVToActivities(bytes.NewReader([]byte(s))); err == nil {
+		t.Error("got no error for french headers with the default mapping, want an error")
+	}
+}
+
+func TestCSVToActivitiesReport(t *testing.T) {
+	s := `Id,Description,Duration,Cost
+1,Buy eggs,30m,10
+x,Cook eggs,10m,0
+3,"Eat
+eggs",20 minutes,abc
+4,Wash dishes,,0
+5,Sleep,8h,0
+`
+	want := []tabular.RowError{
+		{Row: 3, Column: "Id", Value: "x"},
+		{Row: 4, Column: "Duration", Value: "20 minutes"},
+		{Row: 4, Column: "Cost", Value: "abc"},
+		{Row: 6, Column: "Duration", Value: ""},
+	}
+	mapping := tabular.DefaultMapping()
+	acts, _, report, err := CSVToActivitiesWithMapping(bytes.NewReader([]byte(s)), mapping)
+	if err == nil {
+		t.Error("got no error in strict mode, want an error")
+	}
+	if acts != nil {
+		t.Errorf("got %d activities in strict mode, want none", len(acts))
+	}
+	if len(report.Errors) != len(want) {
+		t.Fatalf("got %d errors, want %d: %v", len(report.Errors), len(want), report.Errors)
+	}
+	for i, e := range report.Errors {
+		if e.Row != want[i].Row || e.Column != want[i].Column || e.Value != want[i].Value || e.Err == nil {
+			t.Errorf("got %+v, want %+v", e, want[i])
+		}
+	}
+
+	mapping.Mode = tabular.BestEffort
+	acts, _, report, err = CSVToActivitiesWithMapping(bytes.NewReader([]byte(s)), mapping)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(acts) != 2 || acts[0].Id != 1 || acts[1].Id != 5 {
+		t.Errorf("got %+v, want activities 1 and 5", acts)
+	}
+	if report.Rows != 5 || report.Invalid() != 3 {
+		t.Errorf("got %d invalid rows out of %d, want 3 out of 5", report.Invalid(), report.Rows)
+	}
+}
+
+func TestCSVWithFormat(t *testing.T) {
+	format := &Format{DateLayout: "2006-01-02 15:04", Location: time.UTC, DurationUnit: Days}
+	date := time.Date(2026, time.March, 1, 8, 0, 0, 0, time.UTC)
+	acts := []*activity.Activity{
+		{Id: 1, Description: "Pour concrete", Duration: 3 * 8 * time.Hour, Start: date, PredecessorsId: []int{}, SuccessorsId: []int{}, ActualStart: date},
+	}
+
+	var buf bytes.Buffer
+	if err := ActivitiesToCSVWithFormat(acts, &buf, format); err != nil {
+		t.Fatal(err)
+	}
+	want := "1,Pour concrete,3d,2026-03-01 08:00,,,,0,,0,0,2026-03-01 08:00,,,,,0d,0d,,,\n"
+	if got := strings.SplitAfterN(buf.String(), "\n", 2)[1]; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	got, _, _, err := CSVToActivitiesWithFormat(&buf, tabular.DefaultMapping(), format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, acts) {
+		t.Errorf("got %+v, want %+v", got, acts)
+	}
+}
+
+func TestRoundTripAllFields(t *testing.T) {
+	for _, format := range []*Format{DefaultFormat(), {DateLayout: "2006-01-02 15:04:05", Location: time.UTC, DurationUnit: Weeks}} {
+		want := activitytest.Full()
+		var buf bytes.Buffer
+		if err := ActivitiesToCSVWithFormat([]*activity.Activity{want}, &buf, format); err != nil {
+			t.Fatal(err)
+		}
+		got, _, _, err := CSVToActivitiesWithFormat(&buf, tabular.DefaultMapping(), format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("got %d activities, want 1", len(got))
+		}
+		activitytest.Check(t, got[0], want)
+	}
+}
+
+func TestCalendarsCSV(t *testing.T) {
+	calendars, err := CSVToCalendars(bytes.NewReader([]byte(scsvCalendars)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calendars) != 2 {
+		t.Fatalf("got %d calendars, want %d", len(calendars), 2)
+	}
+	if !calendars[0].Default || len(calendars[0].Holidays) != 2 || len(calendars[1].WorkDays) != 2 {
+		t.Errorf("got %+v and %+v", calendars[0], calendars[1])
+	}
+
+	var buf bytes.Buffer
+	err = CalendarsToCSV(calendars, &buf)
+	if err != nil {
+		t.Error(err)
+	}
+	if buf.String() != scsvCalendars {
+		t.Errorf("error parsing csv: want %s, got %s\n", scsvCalendars, buf.String())
+	}
+}
+
+func TestExportCalendarsToDb(t *testing.T) {
+	sqldb, err := db.New("test.db")
+	if err != nil {
+		t.Error(err)
+	}
+	defer sqldb.DB.Close()
+	err = ExportCalendarsToDb(sqldb, bytes.NewReader([]byte(scsvCalendars)), db.None)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = os.Remove("test.db")
+	if err != nil {
+		t.Error(err)
 	}
 }
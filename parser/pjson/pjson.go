@@ -6,6 +6,7 @@ import (
 
 	"github.com/vanillaiice/verano/activity"
 	"github.com/vanillaiice/verano/db"
+	"github.com/vanillaiice/verano/project/calendar"
 )
 
 // ExportToDb populates the database with activities in json format.
@@ -36,3 +37,32 @@ func JSONtoActivities(reader io.Reader) (activities []*activity.Activity, err er
 	err = json.Unmarshal(j, &activities)
 	return
 }
+
+// ExportCalendarsToDb populates the database with calendars in json format.
+func ExportCalendarsToDb(sqldb *db.DB, reader io.Reader, duplicateInsertPolicy db.DuplicateInsertPolicy) (err error) {
+	calendars, err := JSONtoCalendars(reader)
+	if err != nil {
+		return
+	}
+	return sqldb.InsertCalendars(calendars, duplicateInsertPolicy)
+}
+
+// CalendarsToJSON converts a slice of calendars to json format.
+func CalendarsToJSON(calendars []*calendar.Calendar, writer io.Writer) (err error) {
+	j, err := json.MarshalIndent(calendars, "", "\t")
+	if err != nil {
+		return
+	}
+	_, err = writer.Write(j)
+	return
+}
+
+// JSONtoCalendars converts calendars in json format to a slice of calendars.
+func JSONtoCalendars(reader io.Reader) (calendars []*calendar.Calendar, err error) {
+	j, err := io.ReadAll(reader)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(j, &calendars)
+	return
+}
@@ -9,48 +9,80 @@ import (
 
 	"github.com/vanillaiice/verano/activity"
 	"github.com/vanillaiice/verano/db"
+	"github.com/vanillaiice/verano/internal/activitytest"
+	"github.com/vanillaiice/verano/project/calendar"
 )
 
 var j = `[
 	{
 		"id": 3,
 		"description": "cook eggs",
+		"wbs": "",
 		"duration": 600000000000,
+		"calendarId": 1,
 		"start": "0001-01-01T00:00:00Z",
 		"finish": "0001-01-01T00:00:00Z",
+		"lateStart": "0001-01-01T00:00:00Z",
+		"lateFinish": "0001-01-01T00:00:00Z",
+		"totalFloat": 0,
+		"freeFloat": 0,
 		"predecessorsId": [
 			2
 		],
 		"successorsId": [
 			1
 		],
+		"constraintType": 0,
+		"constraintDate": "0001-01-01T00:00:00Z",
 		"progress": 0,
+		"actualStart": "0001-01-01T00:00:00Z",
+		"actualFinish": "0001-01-01T00:00:00Z",
 		"cost": 0
 	},
 	{
 		"id": 2,
 		"description": "Buy eggs",
+		"wbs": "",
 		"duration": 1800000000000,
+		"calendarId": 0,
 		"start": "0001-01-01T00:00:00Z",
 		"finish": "0001-01-01T00:00:00Z",
+		"lateStart": "0001-01-01T00:00:00Z",
+		"lateFinish": "0001-01-01T00:00:00Z",
+		"totalFloat": 0,
+		"freeFloat": 0,
 		"predecessorsId": [],
 		"successorsId": [
 			3
 		],
+		"constraintType": 0,
+		"constraintDate": "0001-01-01T00:00:00Z",
 		"progress": 0,
+		"actualStart": "0001-01-01T00:00:00Z",
+		"actualFinish": "0001-01-01T00:00:00Z",
 		"cost": 100
 	},
 	{
 		"id": 1,
 		"description": "Eat eggs",
+		"wbs": "",
 		"duration": 1200000000000,
+		"calendarId": 0,
 		"start": "0001-01-01T00:00:00Z",
 		"finish": "0001-01-01T00:00:00Z",
+		"lateStart": "0001-01-01T00:00:00Z",
+		"lateFinish": "0001-01-01T00:00:00Z",
+		"totalFloat": 0,
+		"freeFloat": 0,
 		"predecessorsId": [
 			3
 		],
 		"successorsId": [],
+		"constraintType": 0,
+		"constraintDate": "0001-01-01T00:00:00Z",
 		"progress": 0,
+		"actualStart": "0001-01-01T00:00:00Z",
+		"actualFinish": "0001-01-01T00:00:00Z",
 		"cost": 0
 	}
 ]`
@@ -59,7 +91,7 @@ var d2 = time.Minute * 30
 var d3 = time.Minute * 20
 var tt = time.Time{}
 var activities = []*activity.Activity{
-	{Id: 3, Description: "cook eggs", Duration: d1, PredecessorsId: []int{2}, SuccessorsId: []int{1}, Start: tt, Finish: tt, Cost: 0},
+	{Id: 3, Description: "cook eggs", Duration: d1, CalendarId: 1, PredecessorsId: []int{2}, SuccessorsId: []int{1}, Start: tt, Finish: tt, Cost: 0},
 	{Id: 2, Description: "Buy eggs", Duration: d2, PredecessorsId: []int{}, SuccessorsId: []int{3}, Start: tt, Finish: tt, Cost: 100},
 	{Id: 1, Description: "Eat eggs", Duration: d3, PredecessorsId: []int{3}, SuccessorsId: []int{}, Start: tt, Finish: tt, Cost: 0},
 }
@@ -108,3 +140,42 @@ func TestJSONToActivities(t *testing.T) {
 		}
 	}
 }
+
+func TestRoundTripAllFields(t *testing.T) {
+	want := activitytest.Full()
+	var buf bytes.Buffer
+	if err := ActivitiesToJSON([]*activity.Activity{want}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := JSONtoActivities(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d activities, want 1", len(got))
+	}
+	activitytest.Check(t, got[0], want)
+}
+
+func TestCalendarsJSON(t *testing.T) {
+	standard := calendar.New(1, "standard")
+	standard.Default = true
+	standard.Holidays = []time.Time{time.Date(2024, time.December, 25, 0, 0, 0, 0, time.UTC)}
+
+	var buf bytes.Buffer
+	err := CalendarsToJSON([]*calendar.Calendar{standard}, &buf)
+	if err != nil {
+		t.Error(err)
+	}
+	calendars, err := JSONtoCalendars(&buf)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(calendars) != 1 {
+		t.Fatalf("got %d calendars, want %d", len(calendars), 1)
+	}
+	c := calendars[0]
+	if c.Id != standard.Id || c.Name != standard.Name || !c.Default || len(c.Shifts) != 2 || !c.Holidays[0].Equal(standard.Holidays[0]) {
+		t.Errorf("got %+v, want %+v", c, standard)
+	}
+}
@@ -0,0 +1,334 @@
+package pmspdi
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/vanillaiice/verano/activity"
+	"github.com/vanillaiice/verano/db"
+	"github.com/vanillaiice/verano/project"
+)
+
+// Namespace of MSPDI files.
+const namespace = "http://schemas.microsoft.com/project"
+
+// Layout of the dates in MSPDI files, which are in local time.
+const dateFormat = "2006-01-02T15:04:05"
+
+// Format of the durations written in MSPDI files, which is days (the format is only used for display).
+const durationFormat = 7
+
+// Types of the predecessor links, by relationship type.
+var linkTypes = map[activity.RelationshipType]int{
+	activity.FinishToFinish: 0,
+	activity.FinishToStart:  1,
+	activity.StartToFinish:  2,
+	activity.StartToStart:   3,
+}
+
+// Relationship types, by type of the predecessor links.
+var relationshipTypes = func() map[int]activity.RelationshipType {
+	types := make(map[int]activity.RelationshipType, len(linkTypes))
+	for relType, value := range linkTypes {
+		types[value] = relType
+	}
+	return types
+}()
+
+// Working time of the days and weeks of the durations of MSPDI files that do not define them, as in Microsoft Project.
+const (
+	defaultMinutesPerDay  = 8 * 60
+	defaultMinutesPerWeek = 40 * 60
+)
+
+// Types of the constraints of the tasks, by constraint type.
+var constraintTypes = map[activity.ConstraintType]int{
+	activity.NoConstraint:        0,
+	activity.AsLateAsPossible:    1,
+	activity.MustStartOn:         2,
+	activity.MustFinishOn:        3,
+	activity.StartNoEarlierThan:  4,
+	activity.StartNoLaterThan:    5,
+	activity.FinishNoEarlierThan: 6,
+	activity.FinishNoLaterThan:   7,
+}
+
+// Constraint types, by type of the constraints of the tasks.
+var taskConstraintTypes = func() map[int]activity.ConstraintType {
+	types := make(map[int]activity.ConstraintType, len(constraintTypes))
+	for constraintType, value := range constraintTypes {
+		types[value] = constraintType
+	}
+	return types
+}()
+
+// xmlProject is the root element of a MSPDI file.
+type xmlProject struct {
+	XMLName        xml.Name  `xml:"Project"`
+	Xmlns          string    `xml:"xmlns,attr"`
+	StartDate      string    `xml:"StartDate,omitempty"`
+	MinutesPerDay  int       `xml:"MinutesPerDay,omitempty"`  // Working time of the days of the durations
+	MinutesPerWeek int       `xml:"MinutesPerWeek,omitempty"` // Working time of the weeks of the durations
+	Tasks          []xmlTask `xml:"Tasks>Task"`
+}
+
+// xmlTask is a task of a MSPDI file, with the elements mapped to activities in the order of the MSPDI schema.
+type xmlTask struct {
+	UID             int       `xml:"UID"`
+	ID              int       `xml:"ID"`
+	Name            string    `xml:"Name"`
+	IsNull          int       `xml:"IsNull"`
+	WBS             string    `xml:"WBS,omitempty"`
+	OutlineLevel    int       `xml:"OutlineLevel"`
+	Start           string    `xml:"Start,omitempty"`
+	Finish          string    `xml:"Finish,omitempty"`
+	Duration        string    `xml:"Duration"`
+	DurationFormat  int       `xml:"DurationFormat"`
+	Milestone       int       `xml:"Milestone"`
+	Summary         int       `xml:"Summary"`
+	PercentComplete int       `xml:"PercentComplete"`
+	Cost            string    `xml:"Cost"` // Cost in hundredths of the currency unit
+	ActualStart     string    `xml:"ActualStart,omitempty"`
+	ActualFinish    string    `xml:"ActualFinish,omitempty"`
+	ConstraintType  int       `xml:"ConstraintType"`
+	ConstraintDate  string    `xml:"ConstraintDate,omitempty"`
+	PredecessorLink []xmlLink `xml:"PredecessorLink"`
+}
+
+// xmlLink is a link between a task and one of its predecessors in a MSPDI file.
+type xmlLink struct {
+	PredecessorUID int `xml:"PredecessorUID"`
+	Type           int `xml:"Type"`
+	CrossProject   int `xml:"CrossProject"`
+	LinkLag        int `xml:"LinkLag"` // Lag in tenths of minutes
+	LagFormat      int `xml:"LagFormat"`
+}
+
+// ExportToDb populates the database with activities in MSPDI format.
+func ExportToDb(sqldb *db.DB, reader io.Reader, duplicateInsertPolicy db.DuplicateInsertPolicy) (err error) {
+	activities, err := MSPDIToActivities(reader)
+	if err != nil {
+		return
+	}
+	return sqldb.InsertActivities(activities, duplicateInsertPolicy)
+}
+
+// ActivitiesToMSPDI converts a slice of activities to MSPDI format (Microsoft Project XML).
+// The activities are written as tasks identified by their ids (the UID of the tasks), and costs are
+// written in hundredths of the currency unit, like Microsoft Project does. The progress is rounded to a whole percentage.
+func ActivitiesToMSPDI(activities []*activity.Activity, writer io.Writer) (err error) {
+	p := xmlProject{Xmlns: namespace}
+	var startDate time.Time
+	for i, a := range activities {
+		if !a.Start.IsZero() && (startDate.IsZero() || a.Start.Before(startDate)) {
+			startDate = a.Start
+		}
+
+		milestone := 0
+		if a.Duration == 0 {
+			milestone = 1
+		}
+		task := xmlTask{
+			UID:             a.Id,
+			ID:              i + 1,
+			Name:            a.Description,
+			WBS:             a.Wbs,
+			OutlineLevel:    1,
+			Start:           formatDate(a.Start),
+			Finish:          formatDate(a.Finish),
+			Duration:        formatDuration(a.Duration),
+			DurationFormat:  durationFormat,
+			Milestone:       milestone,
+			PercentComplete: int(math.Round(float64(a.Progress) * 100)),
+			Cost:            strconv.FormatFloat(math.Round(a.Cost*100), 'f', -1, 64),
+			ActualStart:     formatDate(a.ActualStart),
+			ActualFinish:    formatDate(a.ActualFinish),
+			ConstraintType:  constraintTypes[a.ConstraintType],
+		}
+		if a.ConstraintType != activity.NoConstraint && a.ConstraintType != activity.AsLateAsPossible {
+			task.ConstraintDate = formatDate(a.ConstraintDate)
+		}
+		for _, predecessorId := range a.PredecessorsId {
+			rel := a.Relationship(predecessorId)
+			task.PredecessorLink = append(task.PredecessorLink, xmlLink{
+				PredecessorUID: predecessorId,
+				Type:           linkTypes[rel.Type],
+				LinkLag:        int(math.Round(rel.Lag.Minutes() * 10)),
+				LagFormat:      durationFormat,
+			})
+		}
+		p.Tasks = append(p.Tasks, task)
+	}
+	p.StartDate = formatDate(startDate)
+
+	if _, err = io.WriteString(writer, xml.Header); err != nil {
+		return
+	}
+	encoder := xml.NewEncoder(writer)
+	encoder.Indent("", "\t")
+	if err = encoder.Encode(p); err != nil {
+		return
+	}
+	_, err = io.WriteString(writer, "\n")
+	return
+}
+
+// MSPDIToActivities converts activities in MSPDI format (Microsoft Project XML) to a slice of activities.
+// Summary tasks and empty rows are skipped, along with the links to them, since their dates
+// are rolled up from the other tasks. The successors of the activities are rebuilt from their predecessors.
+// The days and weeks of the durations are the working time defined by the project, 8 and 40 hours by default.
+func MSPDIToActivities(reader io.Reader) (activities []*activity.Activity, err error) {
+	var p xmlProject
+	if err = xml.NewDecoder(reader).Decode(&p); err != nil {
+		return
+	}
+	if p.MinutesPerDay <= 0 {
+		p.MinutesPerDay = defaultMinutesPerDay
+	}
+	if p.MinutesPerWeek <= 0 {
+		p.MinutesPerWeek = defaultMinutesPerWeek
+	}
+	day, week := time.Duration(p.MinutesPerDay)*time.Minute, time.Duration(p.MinutesPerWeek)*time.Minute
+
+	ids := make(map[int]bool)
+	for _, task := range p.Tasks {
+		if task.Summary == 0 && task.IsNull == 0 {
+			ids[task.UID] = true
+		}
+	}
+
+	for _, task := range p.Tasks {
+		if !ids[task.UID] {
+			continue
+		}
+		a, err := taskToActivity(task, ids, day, week)
+		if err != nil {
+			return nil, fmt.Errorf("task %d: %w", task.UID, err)
+		}
+		activities = append(activities, a)
+	}
+	project.Normalize(activities, project.FromPredecessors)
+	return
+}
+
+// taskToActivity converts a task to an activity, keeping only the links to the tasks in 'ids',
+// the days and weeks of the durations being 'day' and 'week' of working time.
+func taskToActivity(task xmlTask, ids map[int]bool, day, week time.Duration) (a *activity.Activity, err error) {
+	a = &activity.Activity{
+		Id:             task.UID,
+		Description:    task.Name,
+		Wbs:            task.WBS,
+		Progress:       float32(task.PercentComplete) / 100,
+		PredecessorsId: []int{},
+		SuccessorsId:   []int{},
+	}
+	if a.Duration, err = parseDuration(task.Duration, day, week); err != nil {
+		return
+	}
+	if task.Cost != "" {
+		cost, err := strconv.ParseFloat(task.Cost, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cost %q: %w", task.Cost, err)
+		}
+		a.Cost = cost / 100
+	}
+
+	dates := []struct {
+		value string
+		date  *time.Time
+	}{
+		{task.Start, &a.Start},
+		{task.Finish, &a.Finish},
+		{task.ActualStart, &a.ActualStart},
+		{task.ActualFinish, &a.ActualFinish},
+		{task.ConstraintDate, &a.ConstraintDate},
+	}
+	for _, d := range dates {
+		if *d.date, err = parseDate(d.value); err != nil {
+			return
+		}
+	}
+
+	constraintType, ok := taskConstraintTypes[task.ConstraintType]
+	if !ok {
+		return nil, fmt.Errorf("unknown constraint type %d", task.ConstraintType)
+	}
+	a.ConstraintType = constraintType
+	if a.ConstraintType == activity.NoConstraint || a.ConstraintType == activity.AsLateAsPossible {
+		a.ConstraintDate = time.Time{}
+	}
+
+	for _, link := range task.PredecessorLink {
+		if !ids[link.PredecessorUID] || slices.Contains(a.PredecessorsId, link.PredecessorUID) {
+			continue
+		}
+		relType, ok := relationshipTypes[link.Type]
+		if !ok {
+			return nil, fmt.Errorf("unknown link type %d", link.Type)
+		}
+		rel := activity.Relationship{Type: relType, Lag: time.Duration(link.LinkLag) * time.Minute / 10}
+		a.PredecessorsId = append(a.PredecessorsId, link.PredecessorUID)
+		if rel != (activity.Relationship{}) {
+			if err = a.SetRelationship(link.PredecessorUID, rel); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// isoDuration matches the durations of MSPDI files, in ISO 8601 format (e.g. "PT8H0M0S").
+var isoDuration = regexp.MustCompile(`^P(?:([\d.]+)W)?(?:([\d.]+)D)?(?:T(?:([\d.]+)H)?(?:([\d.]+)M)?(?:([\d.]+)S)?)?$`)
+
+// parseDuration parses a duration in ISO 8601 format, weeks and days being 'week' and 'day' of working time.
+// Years and months are not supported, since their length varies.
+func parseDuration(s string, day, week time.Duration) (d time.Duration, err error) {
+	if s == "" {
+		return
+	}
+	matches := isoDuration.FindStringSubmatch(s)
+	if matches == nil || s == "P" || s == "PT" {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	units := []time.Duration{week, day, time.Hour, time.Minute, time.Second}
+	var total float64
+	for i, unit := range units {
+		if matches[i+1] == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(matches[i+1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: %w", s, err)
+		}
+		total += value * float64(unit)
+	}
+	return time.Duration(math.Round(total/float64(time.Second))) * time.Second, nil
+}
+
+// formatDuration formats a duration in ISO 8601 format, in hours, minutes and seconds like Microsoft Project does.
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("PT%dH%dM%dS", int(d/time.Hour), int(d%time.Hour/time.Minute), int(d%time.Minute/time.Second))
+}
+
+// parseDate parses a date of a MSPDI file, an empty string being parsed as the zero time.
+func parseDate(s string) (t time.Time, err error) {
+	if s == "" {
+		return
+	}
+	return time.ParseInLocation(dateFormat, s, time.Local)
+}
+
+// formatDate formats a date in the layout of MSPDI files, the zero time being formatted as an empty string.
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(time.Local).Format(dateFormat)
+}
@@ -0,0 +1,264 @@
+package pmspdi
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vanillaiice/verano/activity"
+	"github.com/vanillaiice/verano/db"
+)
+
+var smspdi = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Project xmlns="http://schemas.microsoft.com/project">
+	<Name>eggs.xml</Name>
+	<StartDate>2024-01-05T08:00:00</StartDate>
+	<Tasks>
+		<Task>
+			<UID>0</UID>
+			<ID>0</ID>
+			<Name>Eggs</Name>
+			<OutlineLevel>0</OutlineLevel>
+			<Duration>PT1H0M0S</Duration>
+			<Summary>1</Summary>
+		</Task>
+		<Task>
+			<UID>2</UID>
+			<ID>1</ID>
+			<Name>Buy eggs</Name>
+			<WBS>1.1</WBS>
+			<OutlineLevel>1</OutlineLevel>
+			<Start>2024-01-05T08:00:00</Start>
+			<Finish>2024-01-05T08:30:00</Finish>
+			<Duration>PT0H30M0S</Duration>
+			<DurationFormat>7</DurationFormat>
+			<Milestone>0</Milestone>
+			<Summary>0</Summary>
+			<PercentComplete>100</PercentComplete>
+			<Cost>10000</Cost>
+			<ActualStart>2024-01-05T08:00:00</ActualStart>
+			<ActualFinish>2024-01-05T08:30:00</ActualFinish>
+			<ConstraintType>0</ConstraintType>
+			<PredecessorLink>
+				<PredecessorUID>0</PredecessorUID>
+				<Type>1</Type>
+			</PredecessorLink>
+		</Task>
+		<Task>
+			<UID>3</UID>
+			<ID>2</ID>
+			<Name>Cook eggs</Name>
+			<WBS>1.2</WBS>
+			<OutlineLevel>1</OutlineLevel>
+			<Start>2024-01-05T08:35:00</Start>
+			<Finish>2024-01-05T08:45:00</Finish>
+			<Duration>PT0H10M0S</Duration>
+			<PercentComplete>50</PercentComplete>
+			<Cost>0</Cost>
+			<ActualStart>2024-01-05T08:35:00</ActualStart>
+			<ConstraintType>4</ConstraintType>
+			<ConstraintDate>2024-01-05T08:35:00</ConstraintDate>
+			<PredecessorLink>
+				<PredecessorUID>2</PredecessorUID>
+				<Type>3</Type>
+				<CrossProject>0</CrossProject>
+				<LinkLag>50</LinkLag>
+				<LagFormat>7</LagFormat>
+			</PredecessorLink>
+		</Task>
+		<Task>
+			<UID>1</UID>
+			<ID>3</ID>
+			<Name>Eat eggs</Name>
+			<Start>2024-01-05T08:45:00</Start>
+			<Finish>2024-01-05T09:05:00</Finish>
+			<Duration>PT0H20M0S</Duration>
+			<PercentComplete>0</PercentComplete>
+			<Cost>1250</Cost>
+			<PredecessorLink>
+				<PredecessorUID>3</PredecessorUID>
+				<Type>1</Type>
+			</PredecessorLink>
+		</Task>
+		<Task>
+			<UID>4</UID>
+			<ID>4</ID>
+			<IsNull>1</IsNull>
+		</Task>
+	</Tasks>
+</Project>
+`
+
+var t1 = time.Date(2024, time.January, 5, 8, 0, 0, 0, time.Local)
+
+var activities = []*activity.Activity{
+	{Id: 2, Description: "Buy eggs", Wbs: "1.1", Duration: 30 * time.Minute, Start: t1, Finish: t1.Add(30 * time.Minute), PredecessorsId: []int{}, SuccessorsId: []int{3}, Progress: 1, ActualStart: t1, ActualFinish: t1.Add(30 * time.Minute), Cost: 100},
+	{Id: 3, Description: "Cook eggs", Wbs: "1.2", Duration: 10 * time.Minute, Start: t1.Add(35 * time.Minute), Finish: t1.Add(45 * time.Minute), PredecessorsId: []int{2}, SuccessorsId: []int{1}, Relationships: map[int]activity.Relationship{2: {Type: activity.StartToStart, Lag: 5 * time.Minute}}, Progress: 0.5, ActualStart: t1.Add(35 * time.Minute), ConstraintType: activity.StartNoEarlierThan, ConstraintDate: t1.Add(35 * time.Minute)},
+	{Id: 1, Description: "Eat eggs", Duration: 20 * time.Minute, Start: t1.Add(45 * time.Minute), Finish: t1.Add(65 * time.Minute), PredecessorsId: []int{3}, SuccessorsId: []int{}, Cost: 12.5},
+}
+
+func TestMSPDIToActivities(t *testing.T) {
+	got, err := MSPDIToActivities(strings.NewReader(smspdi))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(activities) {
+		t.Fatalf("got %d activities, want %d", len(got), len(activities))
+	}
+	for i, a := range got {
+		if !reflect.DeepEqual(a, activities[i]) {
+			t.Errorf("got %+v, want %+v", a, activities[i])
+		}
+	}
+
+	unknown := strings.Replace(smspdi, "<ConstraintType>4</ConstraintType>", "<ConstraintType>9</ConstraintType>", 1)
+	if _, err = MSPDIToActivities(strings.NewReader(unknown)); err == nil {
+		t.Error("got no error for an unknown constraint type, want an error")
+	}
+	unknown = strings.Replace(smspdi, "<Type>3</Type>", "<Type>4</Type>", 1)
+	if _, err = MSPDIToActivities(strings.NewReader(unknown)); err == nil {
+		t.Error("got no error for an unknown link type, want an error")
+	}
+
+	// the days of the durations are working days, of 8 hours unless the project defines them
+	days := strings.Replace(smspdi, "<Duration>PT0H30M0S</Duration>", "<Duration>P1D</Duration>", 1)
+	for _, test := range []struct {
+		s    string
+		want time.Duration
+	}{
+		{days, 8 * time.Hour},
+		{strings.Replace(days, "<Tasks>", "<MinutesPerDay>420</MinutesPerDay>\n\t<Tasks>", 1), 7 * time.Hour},
+	} {
+		got, err := MSPDIToActivities(strings.NewReader(test.s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got[0].Duration != test.want {
+			t.Errorf("got %v, want %v", got[0].Duration, test.want)
+		}
+	}
+}
+
+func TestActivitiesToMSPDI(t *testing.T) {
+	var buf bytes.Buffer
+	if err := ActivitiesToMSPDI(activities, &buf); err != nil {
+		t.Fatal(err)
+	}
+	s := buf.String()
+	for _, want := range []string{
+		`<Project xmlns="http://schemas.microsoft.com/project">`,
+		"<StartDate>2024-01-05T08:00:00</StartDate>",
+		"<Duration>PT0H30M0S</Duration>",
+		"<Cost>10000</Cost>",
+		"<LinkLag>50</LinkLag>",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("got %s, want it to contain %q", s, want)
+		}
+	}
+
+	got, err := MSPDIToActivities(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, activities) {
+		t.Errorf("got %+v, want %+v", got, activities)
+	}
+}
+
+func TestMSPDIRoundTrip(t *testing.T) {
+	imported, err := MSPDIToActivities(strings.NewReader(smspdi))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = ActivitiesToMSPDI(imported, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var want, got xmlProject
+	if err = xml.Unmarshal([]byte(smspdi), &want); err != nil {
+		t.Fatal(err)
+	}
+	if err = xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.StartDate != want.StartDate {
+		t.Errorf("got start date %q, want %q", got.StartDate, want.StartDate)
+	}
+	// the summary tasks and empty rows are not exported, nor the links to them
+	wantTasks := want.Tasks[1:4]
+	wantTasks[0].PredecessorLink = nil
+	if len(got.Tasks) != len(wantTasks) {
+		t.Fatalf("got %d tasks, want %d", len(got.Tasks), len(wantTasks))
+	}
+	for i, task := range got.Tasks {
+		w := wantTasks[i]
+		gotFields := []any{task.UID, task.Name, task.WBS, task.Start, task.Finish, task.Duration, task.PercentComplete, task.Cost, task.ActualStart, task.ActualFinish, task.ConstraintType, task.ConstraintDate}
+		wantFields := []any{w.UID, w.Name, w.WBS, w.Start, w.Finish, w.Duration, w.PercentComplete, w.Cost, w.ActualStart, w.ActualFinish, w.ConstraintType, w.ConstraintDate}
+		if !reflect.DeepEqual(gotFields, wantFields) {
+			t.Errorf("got task %v, want %v", gotFields, wantFields)
+		}
+		if len(task.PredecessorLink) != len(w.PredecessorLink) {
+			t.Fatalf("got links %+v, want %+v", task.PredecessorLink, w.PredecessorLink)
+		}
+		for j, link := range task.PredecessorLink {
+			wl := w.PredecessorLink[j]
+			if link.PredecessorUID != wl.PredecessorUID || link.Type != wl.Type || link.LinkLag != wl.LinkLag {
+				t.Errorf("got link %+v, want %+v", link, wl)
+			}
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		s    string
+		want time.Duration
+	}{
+		{"", 0},
+		{"PT8H0M0S", 8 * time.Hour},
+		{"PT0H30M0S", 30 * time.Minute},
+		{"PT1.5H", 90 * time.Minute},
+		{"P1DT2H", 10 * time.Hour},
+		{"P1W", 40 * time.Hour},
+	}
+	for _, test := range tests {
+		got, err := parseDuration(test.s, 8*time.Hour, 40*time.Hour)
+		if err != nil {
+			t.Errorf("%q: %v", test.s, err)
+		}
+		if got != test.want {
+			t.Errorf("got %v, want %v", got, test.want)
+		}
+	}
+
+	for _, s := range []string{"P", "PT", "8h", "P1Y", "PT1H2H"} {
+		if _, err := parseDuration(s, 8*time.Hour, 40*time.Hour); err == nil {
+			t.Errorf("got no error for %q, want an error", s)
+		}
+	}
+}
+
+func TestExportToDb(t *testing.T) {
+	sqldb, err := db.New("test.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("test.db")
+
+	if err = ExportToDb(sqldb, strings.NewReader(smspdi), db.None); err != nil {
+		t.Error(err)
+	}
+	got, err := sqldb.GetActivitiesAll()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(got) != 3 {
+		t.Errorf("got %d activities, want 3", len(got))
+	}
+}
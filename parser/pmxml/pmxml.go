@@ -0,0 +1,672 @@
+package pmxml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/vanillaiice/verano/activity"
+	"github.com/vanillaiice/verano/db"
+	"github.com/vanillaiice/verano/project"
+	"github.com/vanillaiice/verano/project/calendar"
+	"github.com/vanillaiice/verano/util"
+)
+
+// Namespace of the PMXML files written by this package.
+const namespace = "http://xmlns.oracle.com/Primavera/P6/V19.12/API/BusinessObjects"
+
+// Layout of the dates in PMXML files, which are in local time.
+const dateFormat = "2006-01-02T15:04:05"
+
+// Layout of the times of day in PMXML files.
+const clockFormat = "15:04:05"
+
+// Object id of the project in the PMXML files written by this package.
+const exportProjectId = 1
+
+// Types of the relationships, by relationship type.
+var relationshipTypes = map[activity.RelationshipType]string{
+	activity.FinishToStart:  "Finish to Start",
+	activity.StartToStart:   "Start to Start",
+	activity.FinishToFinish: "Finish to Finish",
+	activity.StartToFinish:  "Start to Finish",
+}
+
+// Primary constraints of the activities, by constraint type.
+var constraintTypes = map[activity.ConstraintType]string{
+	activity.StartNoEarlierThan:  "Start On or After",
+	activity.StartNoLaterThan:    "Start On or Before",
+	activity.FinishNoEarlierThan: "Finish On or After",
+	activity.FinishNoLaterThan:   "Finish On or Before",
+	activity.MustStartOn:         "Start On",
+	activity.MustFinishOn:        "Finish On",
+	activity.AsLateAsPossible:    "As Late As Possible",
+}
+
+// Mandatory constraints, which are read as the closest constraint of Verano.
+var mandatoryConstraintTypes = map[string]activity.ConstraintType{
+	"Mandatory Start":  activity.MustStartOn,
+	"Mandatory Finish": activity.MustFinishOn,
+}
+
+// xmlDocument is the root element of a PMXML file.
+type xmlDocument struct {
+	XMLName   xml.Name      `xml:"APIBusinessObjects"`
+	Xmlns     string        `xml:"xmlns,attr"`
+	Calendars []xmlCalendar `xml:"Calendar"`
+	Projects  []xmlProject  `xml:"Project"`
+}
+
+// xmlCalendar is a calendar of a PMXML file.
+type xmlCalendar struct {
+	HolidayExceptions []xmlException `xml:"HolidayExceptions>HolidayException"`
+	HoursPerDay       string         `xml:"HoursPerDay,omitempty"`
+	IsDefault         string         `xml:"IsDefault"`
+	Name              string         `xml:"Name"`
+	ObjectId          int            `xml:"ObjectId"`
+	WorkWeek          []xmlWorkHours `xml:"StandardWorkWeek>StandardWorkHours"`
+	Type              string         `xml:"Type,omitempty"`
+}
+
+// xmlWorkHours is the working time of a day of the week of a calendar of a PMXML file.
+type xmlWorkHours struct {
+	DayOfWeek string        `xml:"DayOfWeek"`
+	WorkTime  []xmlWorkTime `xml:"WorkTime"`
+}
+
+// xmlWorkTime is a period of working time of a PMXML file.
+type xmlWorkTime struct {
+	Start  string `xml:"Start"`
+	Finish string `xml:"Finish"`
+}
+
+// xmlException is an exception of a calendar of a PMXML file, which is a holiday if it has no working time.
+type xmlException struct {
+	Date     string        `xml:"Date"`
+	WorkTime []xmlWorkTime `xml:"WorkTime"`
+}
+
+// xmlProject is a project of a PMXML file.
+type xmlProject struct {
+	DataDate         string            `xml:"DataDate,omitempty"`
+	Id               string            `xml:"Id"`
+	Name             string            `xml:"Name"`
+	ObjectId         int               `xml:"ObjectId"`
+	PlannedStartDate string            `xml:"PlannedStartDate,omitempty"`
+	Calendars        []xmlCalendar     `xml:"Calendar"`
+	WBS              []xmlWbs          `xml:"WBS"`
+	Activities       []xmlActivity     `xml:"Activity"`
+	Relationships    []xmlRelationship `xml:"Relationship"`
+}
+
+// xmlWbs is an element of the work breakdown structure of a project of a PMXML file.
+type xmlWbs struct {
+	Code            string `xml:"Code"`
+	Name            string `xml:"Name"`
+	ObjectId        int    `xml:"ObjectId"`
+	ParentObjectId  int    `xml:"ParentObjectId,omitempty"`
+	ProjectObjectId int    `xml:"ProjectObjectId"`
+	SequenceNumber  int    `xml:"SequenceNumber"`
+}
+
+// xmlActivity is an activity of a project of a PMXML file.
+type xmlActivity struct {
+	ActualFinishDate      string `xml:"ActualFinishDate,omitempty"`
+	ActualStartDate       string `xml:"ActualStartDate,omitempty"`
+	CalendarObjectId      int    `xml:"CalendarObjectId,omitempty"`
+	FinishDate            string `xml:"FinishDate,omitempty"`
+	FreeFloat             string `xml:"FreeFloat,omitempty"`
+	Id                    string `xml:"Id"`
+	LateFinishDate        string `xml:"LateFinishDate,omitempty"`
+	LateStartDate         string `xml:"LateStartDate,omitempty"`
+	Name                  string `xml:"Name"`
+	ObjectId              int    `xml:"ObjectId"`
+	PercentComplete       string `xml:"PercentComplete,omitempty"` // Fraction of the activity that is complete, from 0 to 1
+	PlannedDuration       string `xml:"PlannedDuration"`           // Planned duration in hours
+	PlannedTotalCost      string `xml:"PlannedTotalCost,omitempty"`
+	PrimaryConstraintDate string `xml:"PrimaryConstraintDate,omitempty"`
+	PrimaryConstraintType string `xml:"PrimaryConstraintType,omitempty"`
+	ProjectObjectId       int    `xml:"ProjectObjectId"`
+	RemainingDuration     string `xml:"RemainingDuration,omitempty"`
+	StartDate             string `xml:"StartDate,omitempty"`
+	Status                string `xml:"Status,omitempty"`
+	TotalFloat            string `xml:"TotalFloat,omitempty"`
+	Type                  string `xml:"Type,omitempty"`
+	WBSObjectId           int    `xml:"WBSObjectId,omitempty"`
+}
+
+// xmlRelationship is a relationship between two activities of a PMXML file.
+type xmlRelationship struct {
+	Lag                         string `xml:"Lag"` // Lag in hours
+	ObjectId                    int    `xml:"ObjectId"`
+	PredecessorActivityObjectId int    `xml:"PredecessorActivityObjectId"`
+	SuccessorActivityObjectId   int    `xml:"SuccessorActivityObjectId"`
+	Type                        string `xml:"Type"`
+}
+
+// ExportToDb populates the database with the activities and calendars of a PMXML file.
+func ExportToDb(sqldb *db.DB, reader io.Reader, duplicateInsertPolicy db.DuplicateInsertPolicy) (err error) {
+	p, err := PMXMLToProject(reader)
+	if err != nil {
+		return
+	}
+	if err = sqldb.InsertActivities(p.Activities(), duplicateInsertPolicy); err != nil {
+		return
+	}
+	return sqldb.InsertCalendars(p.Calendars, duplicateInsertPolicy)
+}
+
+// PMXMLToProject converts the first project of a PMXML file to a project, with its calendars,
+// work breakdown structure, activities and relationships. The activities are identified by their object ids.
+// The mandatory constraints are read as must start or finish on constraints, the secondary constraints,
+// the exceptions with working time and the relationships with activities of other projects are dropped.
+// Calendars use the working time of their first work day for every work day.
+func PMXMLToProject(reader io.Reader) (p *project.Project, err error) {
+	var doc xmlDocument
+	if err = xml.NewDecoder(reader).Decode(&doc); err != nil {
+		return
+	}
+	if len(doc.Projects) == 0 {
+		return nil, errors.New("no project in the PMXML file")
+	}
+	xp := doc.Projects[0]
+
+	var calendars []*calendar.Calendar
+	for _, xc := range append(slices.Clone(doc.Calendars), xp.Calendars...) {
+		c, err := readCalendar(xc)
+		if err != nil {
+			return nil, fmt.Errorf("calendar %d: %w", xc.ObjectId, err)
+		}
+		calendars = append(calendars, c)
+	}
+
+	wbs := readWbs(xp.WBS)
+	activities := make([]*activity.Activity, 0, len(xp.Activities))
+	activitiesMap := make(map[int]*activity.Activity)
+	for _, xa := range xp.Activities {
+		a, err := readActivity(xa, wbs)
+		if err != nil {
+			return nil, fmt.Errorf("activity %d: %w", xa.ObjectId, err)
+		}
+		activities = append(activities, a)
+		activitiesMap[a.Id] = a
+	}
+
+	for _, xr := range xp.Relationships {
+		s, ok := activitiesMap[xr.SuccessorActivityObjectId]
+		if !ok {
+			continue
+		}
+		if _, ok := activitiesMap[xr.PredecessorActivityObjectId]; !ok || slices.Contains(s.PredecessorsId, xr.PredecessorActivityObjectId) {
+			continue
+		}
+		rel := activity.Relationship{}
+		found := false
+		for relType, name := range relationshipTypes {
+			if name == xr.Type {
+				rel.Type, found = relType, true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("relationship %d: unknown type %q", xr.ObjectId, xr.Type)
+		}
+		if rel.Lag, err = parseHours(xr.Lag); err != nil {
+			return nil, fmt.Errorf("relationship %d: invalid lag: %w", xr.ObjectId, err)
+		}
+		s.PredecessorsId = append(s.PredecessorsId, xr.PredecessorActivityObjectId)
+		if rel != (activity.Relationship{}) {
+			if err = s.SetRelationship(xr.PredecessorActivityObjectId, rel); err != nil {
+				return
+			}
+		}
+	}
+
+	startDate, err := parseDate(xp.PlannedStartDate)
+	if err != nil {
+		return nil, fmt.Errorf("project %d: invalid planned start date: %w", xp.ObjectId, err)
+	}
+	dataDate, err := parseDate(xp.DataDate)
+	if err != nil {
+		return nil, fmt.Errorf("project %d: invalid data date: %w", xp.ObjectId, err)
+	}
+	name := xp.Name
+	if name == "" {
+		name = xp.Id
+	}
+
+	project.Normalize(activities, project.FromPredecessors)
+	if p, err = project.New(name, startDate, activities); err != nil {
+		return
+	}
+	p.DataDate = dataDate
+	p.Calendars = calendars
+	return
+}
+
+// readCalendar converts a calendar of a PMXML file to a calendar.
+func readCalendar(xc xmlCalendar) (c *calendar.Calendar, err error) {
+	c = &calendar.Calendar{Id: xc.ObjectId, Name: xc.Name, Default: parseFlag(xc.IsDefault)}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		for _, day := range xc.WorkWeek {
+			if day.DayOfWeek != d.String() {
+				continue
+			}
+			shifts, err := parseWorkTime(day.WorkTime)
+			if err != nil {
+				return nil, err
+			}
+			if len(shifts) == 0 {
+				continue
+			}
+			c.WorkDays = append(c.WorkDays, d)
+			if c.Shifts == nil {
+				c.Shifts = shifts
+			}
+		}
+	}
+	for _, exception := range xc.HolidayExceptions {
+		if shifts, err := parseWorkTime(exception.WorkTime); err != nil || len(shifts) > 0 {
+			continue
+		}
+		day, err := parseDate(exception.Date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid holiday: %w", err)
+		}
+		y, m, d := day.Date()
+		c.Holidays = append(c.Holidays, time.Date(y, m, d, 0, 0, 0, 0, time.UTC))
+	}
+	return
+}
+
+// parseWorkTime parses periods of working time as shifts.
+// P6 writes the finish of the periods to the previous minute (e.g. "11:59:00" for noon), which is rounded up.
+// Empty periods, which P6 writes for days without working time, are skipped.
+func parseWorkTime(workTime []xmlWorkTime) (shifts []calendar.Shift, err error) {
+	for _, wt := range workTime {
+		if wt.Start == "" && wt.Finish == "" {
+			continue
+		}
+		start, err := parseClock(wt.Start)
+		if err != nil {
+			return nil, err
+		}
+		finish, err := parseClock(wt.Finish)
+		if err != nil {
+			return nil, err
+		}
+		if finish%time.Hour == 59*time.Minute {
+			finish += time.Minute
+		}
+		// a period finishing at midnight is written as finishing at 00:00
+		if finish <= start {
+			finish += 24 * time.Hour
+		}
+		shifts = append(shifts, calendar.Shift{Start: start, Finish: finish})
+	}
+	return
+}
+
+// readWbs computes the paths of the elements of the work breakdown structure from the codes of the elements,
+// the elements without parent being at the top of the structure.
+func readWbs(elements []xmlWbs) (paths map[int]string) {
+	byId := make(map[int]xmlWbs)
+	for _, w := range elements {
+		byId[w.ObjectId] = w
+	}
+	paths = make(map[int]string)
+	for _, w := range elements {
+		codes := []string{w.Code}
+		for parent, depth := w.ParentObjectId, 0; depth < len(elements); depth++ {
+			p, ok := byId[parent]
+			if !ok {
+				break
+			}
+			codes = append([]string{p.Code}, codes...)
+			parent = p.ParentObjectId
+		}
+		paths[w.ObjectId] = util.FlatWbs(codes)
+	}
+	return
+}
+
+// readActivity converts an activity of a PMXML file to an activity, using the paths of the work breakdown structure 'wbs'.
+func readActivity(xa xmlActivity, wbs map[int]string) (a *activity.Activity, err error) {
+	a = &activity.Activity{
+		Id:             xa.ObjectId,
+		Description:    xa.Name,
+		Wbs:            wbs[xa.WBSObjectId],
+		Code:           xa.Id,
+		CalendarId:     xa.CalendarObjectId,
+		PredecessorsId: []int{},
+		SuccessorsId:   []int{},
+	}
+
+	durations := []struct {
+		name  string
+		value string
+		d     *time.Duration
+	}{
+		{"planned duration", xa.PlannedDuration, &a.Duration},
+		{"total float", xa.TotalFloat, &a.TotalFloat},
+		{"free float", xa.FreeFloat, &a.FreeFloat},
+	}
+	for _, d := range durations {
+		if *d.d, err = parseHours(d.value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", d.name, err)
+		}
+	}
+
+	dates := []struct {
+		name  string
+		value string
+		date  *time.Time
+	}{
+		{"start date", xa.StartDate, &a.Start},
+		{"finish date", xa.FinishDate, &a.Finish},
+		{"late start date", xa.LateStartDate, &a.LateStart},
+		{"late finish date", xa.LateFinishDate, &a.LateFinish},
+		{"actual start date", xa.ActualStartDate, &a.ActualStart},
+		{"actual finish date", xa.ActualFinishDate, &a.ActualFinish},
+	}
+	for _, d := range dates {
+		if *d.date, err = parseDate(d.value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", d.name, err)
+		}
+	}
+
+	if xa.Status == "Completed" {
+		a.Progress = 1
+	} else if xa.PercentComplete != "" {
+		progress, err := strconv.ParseFloat(xa.PercentComplete, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid percent complete: %w", err)
+		}
+		a.Progress = float32(progress)
+	}
+
+	if xa.PlannedTotalCost != "" {
+		if a.Cost, err = strconv.ParseFloat(xa.PlannedTotalCost, 64); err != nil {
+			return nil, fmt.Errorf("invalid planned total cost: %w", err)
+		}
+	}
+
+	if xa.PrimaryConstraintType != "" {
+		constraintType, ok := mandatoryConstraintTypes[xa.PrimaryConstraintType]
+		for ct, name := range constraintTypes {
+			if name == xa.PrimaryConstraintType {
+				constraintType, ok = ct, true
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown primary constraint type %q", xa.PrimaryConstraintType)
+		}
+		a.ConstraintType = constraintType
+		if constraintType != activity.AsLateAsPossible {
+			if a.ConstraintDate, err = parseDate(xa.PrimaryConstraintDate); err != nil {
+				return nil, fmt.Errorf("invalid primary constraint date: %w", err)
+			}
+		}
+	}
+	return
+}
+
+// ProjectToPMXML writes the project 'p' to a PMXML file, with its calendars, work breakdown structure,
+// activities and relationships. The costs of the activities are written as their planned total cost,
+// which P6 computes from the resource assignments and expenses instead of reading it.
+// The activities without calendar are assigned the default calendar, since P6 requires a calendar for every activity.
+// If the project has no calendars, a continuous calendar (every day, all day) is written as the default calendar.
+func ProjectToPMXML(p *project.Project, writer io.Writer) (err error) {
+	return writePMXML(writer, p.Name, p.StartDate, p.DataDate, p.Activities(), p.Calendars)
+}
+
+// ActivitiesToPMXML writes the 'activities' and 'calendars' to a PMXML file as a project named "Verano",
+// starting at the earliest start of the activities. See ProjectToPMXML for the details of the conversion.
+func ActivitiesToPMXML(activities []*activity.Activity, calendars []*calendar.Calendar, writer io.Writer) (err error) {
+	var startDate time.Time
+	for _, a := range activities {
+		if !a.Start.IsZero() && (startDate.IsZero() || a.Start.Before(startDate)) {
+			startDate = a.Start
+		}
+	}
+	return writePMXML(writer, "Verano", startDate, time.Time{}, activities, calendars)
+}
+
+// writePMXML writes a PMXML file with a project named 'name', starting at 'startDate',
+// with progress recorded up to 'dataDate', and with the given 'activities' and 'calendars'.
+func writePMXML(writer io.Writer, name string, startDate, dataDate time.Time, activities []*activity.Activity, calendars []*calendar.Calendar) (err error) {
+	if name == "" {
+		name = "Verano"
+	}
+	if len(calendars) == 0 {
+		calendars = []*calendar.Calendar{{
+			Id:       1,
+			Name:     "Continuous",
+			Default:  true,
+			WorkDays: []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday},
+			Shifts:   []calendar.Shift{{Start: 0, Finish: 24 * time.Hour}},
+		}}
+	}
+	defaultCalendar := calendar.Default(calendars)
+	if defaultCalendar == nil {
+		defaultCalendar = calendars[0]
+	}
+
+	doc := xmlDocument{Xmlns: namespace}
+	for _, c := range calendars {
+		doc.Calendars = append(doc.Calendars, writeCalendar(c, c == defaultCalendar))
+	}
+
+	xp := xmlProject{
+		DataDate:         formatDate(dataDate),
+		Id:               name,
+		Name:             name,
+		ObjectId:         exportProjectId,
+		PlannedStartDate: formatDate(startDate),
+	}
+	// the elements are numbered in the order in which they appear in the activities
+	wbsIds := make(map[string]int)
+	wbsIdOf := func(path string) (parentId int) {
+		if path == "" {
+			return
+		}
+		codes := util.UnflatWbs(path)
+		for n := range codes {
+			prefix := util.FlatWbs(codes[:n+1])
+			id, ok := wbsIds[prefix]
+			if !ok {
+				id = len(xp.WBS) + 1
+				wbsIds[prefix] = id
+				xp.WBS = append(xp.WBS, xmlWbs{
+					Code:            codes[n],
+					Name:            codes[n],
+					ObjectId:        id,
+					ParentObjectId:  parentId,
+					ProjectObjectId: exportProjectId,
+					SequenceNumber:  id,
+				})
+			}
+			parentId = id
+		}
+		return
+	}
+
+	for _, a := range activities {
+		xp.Activities = append(xp.Activities, writeActivity(a, wbsIdOf(a.Wbs), defaultCalendar.Id))
+		for _, predecessorId := range a.PredecessorsId {
+			rel := a.Relationship(predecessorId)
+			xp.Relationships = append(xp.Relationships, xmlRelationship{
+				Lag:                         formatHours(rel.Lag),
+				ObjectId:                    len(xp.Relationships) + 1,
+				PredecessorActivityObjectId: predecessorId,
+				SuccessorActivityObjectId:   a.Id,
+				Type:                        relationshipTypes[rel.Type],
+			})
+		}
+	}
+	doc.Projects = []xmlProject{xp}
+
+	if _, err = io.WriteString(writer, xml.Header); err != nil {
+		return
+	}
+	encoder := xml.NewEncoder(writer)
+	encoder.Indent("", "\t")
+	if err = encoder.Encode(doc); err != nil {
+		return
+	}
+	_, err = io.WriteString(writer, "\n")
+	return
+}
+
+// writeCalendar converts the calendar 'c' to a calendar of a PMXML file, its holidays being written as exceptions.
+func writeCalendar(c *calendar.Calendar, isDefault bool) (xc xmlCalendar) {
+	var day time.Duration
+	var workTime []xmlWorkTime
+	for _, shift := range c.Shifts {
+		day += shift.Finish - shift.Start
+		workTime = append(workTime, xmlWorkTime{Start: formatClock(shift.Start), Finish: formatClock(shift.Finish)})
+	}
+	xc = xmlCalendar{
+		HoursPerDay: formatHours(day),
+		IsDefault:   formatFlag(isDefault),
+		Name:        c.Name,
+		ObjectId:    c.Id,
+		Type:        "Global",
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		hours := xmlWorkHours{DayOfWeek: d.String()}
+		if slices.Contains(c.WorkDays, d) {
+			hours.WorkTime = workTime
+		}
+		xc.WorkWeek = append(xc.WorkWeek, hours)
+	}
+	for _, h := range c.Holidays {
+		y, m, d := h.Date()
+		xc.HolidayExceptions = append(xc.HolidayExceptions, xmlException{Date: time.Date(y, m, d, 0, 0, 0, 0, time.Local).Format(dateFormat)})
+	}
+	return
+}
+
+// writeActivity converts the activity 'a' to an activity of a PMXML file, in the element 'wbsId'
+// of the work breakdown structure, and with the calendar 'defaultCalendarId' if it has no calendar.
+func writeActivity(a *activity.Activity, wbsId, defaultCalendarId int) xmlActivity {
+	calendarId := a.CalendarId
+	if calendarId == 0 {
+		calendarId = defaultCalendarId
+	}
+	activityType := "Task Dependent"
+	if a.Duration == 0 {
+		activityType = "Start Milestone"
+		if len(a.PredecessorsId) > 0 {
+			activityType = "Finish Milestone"
+		}
+	}
+	status := "Not Started"
+	if a.IsCompleted() || a.Progress >= 1 {
+		status = "Completed"
+	} else if a.IsStarted() || a.Progress > 0 {
+		status = "In Progress"
+	}
+
+	xa := xmlActivity{
+		ActualFinishDate:  formatDate(a.ActualFinish),
+		ActualStartDate:   formatDate(a.ActualStart),
+		CalendarObjectId:  calendarId,
+		FinishDate:        formatDate(a.Finish),
+		FreeFloat:         formatHours(a.FreeFloat),
+		Id:                activityCode(a),
+		LateFinishDate:    formatDate(a.LateFinish),
+		LateStartDate:     formatDate(a.LateStart),
+		Name:              a.Description,
+		ObjectId:          a.Id,
+		PercentComplete:   strconv.FormatFloat(float64(a.Progress), 'f', -1, 32),
+		PlannedDuration:   formatHours(a.Duration),
+		PlannedTotalCost:  strconv.FormatFloat(a.Cost, 'f', -1, 64),
+		ProjectObjectId:   exportProjectId,
+		RemainingDuration: formatHours(a.RemainingDuration()),
+		StartDate:         formatDate(a.Start),
+		Status:            status,
+		TotalFloat:        formatHours(a.TotalFloat),
+		Type:              activityType,
+		WBSObjectId:       wbsId,
+	}
+	if a.ConstraintType != activity.NoConstraint {
+		xa.PrimaryConstraintType = constraintTypes[a.ConstraintType]
+		if a.ConstraintType != activity.AsLateAsPossible {
+			xa.PrimaryConstraintDate = formatDate(a.ConstraintDate)
+		}
+	}
+	return xa
+}
+
+// parseDate parses a date of a PMXML file, an empty string being parsed as the zero time.
+func parseDate(s string) (t time.Time, err error) {
+	if s == "" {
+		return
+	}
+	return time.ParseInLocation(dateFormat, s, time.Local)
+}
+
+// formatDate formats a date in the layout of PMXML files, the zero time being formatted as an empty string.
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(time.Local).Format(dateFormat)
+}
+
+// parseClock parses a time of day (e.g. "08:00:00") as an offset from midnight.
+func parseClock(s string) (d time.Duration, err error) {
+	t, err := time.Parse(clockFormat, s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second, nil
+}
+
+// formatClock formats an offset from midnight as a time of day (e.g. "08:00:00"), midnight of the next day being written as "00:00:00".
+func formatClock(d time.Duration) string {
+	return time.Time{}.Add(d % (24 * time.Hour)).Format(clockFormat)
+}
+
+// parseHours parses a number of hours as a duration, an empty string being parsed as zero.
+func parseHours(s string) (d time.Duration, err error) {
+	if s == "" {
+		return
+	}
+	hours, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return
+	}
+	return time.Duration(math.Round(hours*float64(time.Hour)/float64(time.Second))) * time.Second, nil
+}
+
+// formatHours formats a duration as a number of hours.
+func formatHours(d time.Duration) string {
+	return strconv.FormatFloat(d.Hours(), 'f', -1, 64)
+}
+
+// parseFlag parses a boolean of a PMXML file, written as "1" or "true".
+func parseFlag(s string) bool {
+	return s == "1" || s == "true"
+}
+
+// formatFlag formats a boolean as a boolean of a PMXML file.
+func formatFlag(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// activityCode returns the code of the activity 'a', or its id if it has no code.
+func activityCode(a *activity.Activity) string {
+	if a.Code == "" {
+		return strconv.Itoa(a.Id)
+	}
+	return a.Code
+}
@@ -0,0 +1,323 @@
+package pmxml
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vanillaiice/verano/activity"
+	"github.com/vanillaiice/verano/db"
+	"github.com/vanillaiice/verano/project/calendar"
+)
+
+var spmxml = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<APIBusinessObjects xmlns="http://xmlns.oracle.com/Primavera/P6/V8.4/API/BusinessObjects">
+	<Calendar>
+		<HolidayExceptions>
+			<HolidayException>
+				<Date>2024-12-25T00:00:00</Date>
+			</HolidayException>
+			<HolidayException>
+				<Date>2024-12-28T00:00:00</Date>
+				<WorkTime>
+					<Start>08:00:00</Start>
+					<Finish>11:59:00</Finish>
+				</WorkTime>
+			</HolidayException>
+		</HolidayExceptions>
+		<HoursPerDay>8</HoursPerDay>
+		<IsDefault>1</IsDefault>
+		<Name>Standard</Name>
+		<ObjectId>7</ObjectId>
+		<StandardWorkWeek>
+			<StandardWorkHours>
+				<DayOfWeek>Sunday</DayOfWeek>
+				<WorkTime/>
+			</StandardWorkHours>
+			<StandardWorkHours>
+				<DayOfWeek>Monday</DayOfWeek>
+				<WorkTime>
+					<Start>08:00:00</Start>
+					<Finish>11:59:00</Finish>
+				</WorkTime>
+				<WorkTime>
+					<Start>13:00:00</Start>
+					<Finish>16:59:00</Finish>
+				</WorkTime>
+			</StandardWorkHours>
+			<StandardWorkHours>
+				<DayOfWeek>Tuesday</DayOfWeek>
+				<WorkTime>
+					<Start>08:00:00</Start>
+					<Finish>11:59:00</Finish>
+				</WorkTime>
+				<WorkTime>
+					<Start>13:00:00</Start>
+					<Finish>16:59:00</Finish>
+				</WorkTime>
+			</StandardWorkHours>
+		</StandardWorkWeek>
+		<Type>Global</Type>
+	</Calendar>
+	<Project>
+		<DataDate>2024-01-09T08:00:00</DataDate>
+		<Id>EGGS</Id>
+		<Name>Eggs</Name>
+		<ObjectId>4500</ObjectId>
+		<PlannedStartDate>2024-01-08T08:00:00</PlannedStartDate>
+		<WBS>
+			<Code>1</Code>
+			<Name>Kitchen</Name>
+			<ObjectId>10</ObjectId>
+			<ProjectObjectId>4500</ProjectObjectId>
+			<SequenceNumber>1</SequenceNumber>
+		</WBS>
+		<WBS>
+			<Code>2.1</Code>
+			<Name>Stove</Name>
+			<ObjectId>11</ObjectId>
+			<ParentObjectId>10</ParentObjectId>
+			<ProjectObjectId>4500</ProjectObjectId>
+			<SequenceNumber>2</SequenceNumber>
+		</WBS>
+		<Activity>
+			<ActualFinishDate>2024-01-08T12:00:00</ActualFinishDate>
+			<ActualStartDate>2024-01-08T08:00:00</ActualStartDate>
+			<CalendarObjectId>7</CalendarObjectId>
+			<Id>A1000</Id>
+			<Name>Buy eggs</Name>
+			<ObjectId>100</ObjectId>
+			<PercentComplete>0.9</PercentComplete>
+			<PlannedDuration>4</PlannedDuration>
+			<PlannedTotalCost>100.5</PlannedTotalCost>
+			<ProjectObjectId>4500</ProjectObjectId>
+			<StartDate>2024-01-08T08:00:00</StartDate>
+			<FinishDate>2024-01-08T12:00:00</FinishDate>
+			<Status>Completed</Status>
+			<Type>Task Dependent</Type>
+			<WBSObjectId>10</WBSObjectId>
+		</Activity>
+		<Activity>
+			<ActualStartDate>2024-01-08T13:00:00</ActualStartDate>
+			<CalendarObjectId>7</CalendarObjectId>
+			<FinishDate>2024-01-09T10:00:00</FinishDate>
+			<FreeFloat>0</FreeFloat>
+			<Id>A1010</Id>
+			<LateFinishDate>2024-01-09T10:00:00</LateFinishDate>
+			<LateStartDate>2024-01-09T08:00:00</LateStartDate>
+			<Name>Cook eggs</Name>
+			<ObjectId>101</ObjectId>
+			<PercentComplete>0.75</PercentComplete>
+			<PlannedDuration>8</PlannedDuration>
+			<PrimaryConstraintDate>2024-01-08T13:00:00</PrimaryConstraintDate>
+			<PrimaryConstraintType>Start On or After</PrimaryConstraintType>
+			<ProjectObjectId>4500</ProjectObjectId>
+			<StartDate>2024-01-09T08:00:00</StartDate>
+			<Status>In Progress</Status>
+			<TotalFloat>0</TotalFloat>
+			<WBSObjectId>11</WBSObjectId>
+		</Activity>
+		<Activity>
+			<FinishDate>2024-01-09T10:30:00</FinishDate>
+			<FreeFloat>1.5</FreeFloat>
+			<Id>A1020</Id>
+			<Name>Eat eggs</Name>
+			<ObjectId>102</ObjectId>
+			<PlannedDuration>0.5</PlannedDuration>
+			<PrimaryConstraintDate>2024-01-09T12:00:00</PrimaryConstraintDate>
+			<PrimaryConstraintType>Mandatory Finish</PrimaryConstraintType>
+			<ProjectObjectId>4500</ProjectObjectId>
+			<StartDate>2024-01-09T10:00:00</StartDate>
+			<TotalFloat>1.5</TotalFloat>
+			<WBSObjectId>11</WBSObjectId>
+		</Activity>
+		<Relationship>
+			<Lag>0</Lag>
+			<ObjectId>1</ObjectId>
+			<PredecessorActivityObjectId>100</PredecessorActivityObjectId>
+			<SuccessorActivityObjectId>101</SuccessorActivityObjectId>
+			<Type>Finish to Start</Type>
+		</Relationship>
+		<Relationship>
+			<Lag>0.25</Lag>
+			<ObjectId>2</ObjectId>
+			<PredecessorActivityObjectId>101</PredecessorActivityObjectId>
+			<SuccessorActivityObjectId>102</SuccessorActivityObjectId>
+			<Type>Start to Start</Type>
+		</Relationship>
+		<Relationship>
+			<Lag>0</Lag>
+			<ObjectId>3</ObjectId>
+			<PredecessorActivityObjectId>999</PredecessorActivityObjectId>
+			<SuccessorActivityObjectId>102</SuccessorActivityObjectId>
+			<Type>Finish to Start</Type>
+		</Relationship>
+	</Project>
+</APIBusinessObjects>
+`
+
+// date returns the local time of a date written in the layout of PMXML files.
+func date(s string) time.Time {
+	t, err := time.ParseInLocation(dateFormat, s, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestPMXMLToProject(t *testing.T) {
+	p, err := PMXMLToProject(strings.NewReader(spmxml))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Name != "Eggs" || !p.StartDate.Equal(date("2024-01-08T08:00:00")) || !p.DataDate.Equal(date("2024-01-09T08:00:00")) {
+		t.Errorf("got %q %v %v, want \"Eggs\" 2024-01-08 08:00 2024-01-09 08:00", p.Name, p.StartDate, p.DataDate)
+	}
+
+	want := []*activity.Activity{
+		{Id: 100, Description: "Buy eggs", Wbs: "1", CalendarId: 7, Duration: 4 * time.Hour, Start: date("2024-01-08T08:00:00"), Finish: date("2024-01-08T12:00:00"), PredecessorsId: []int{}, SuccessorsId: []int{101}, Progress: 1, ActualStart: date("2024-01-08T08:00:00"), ActualFinish: date("2024-01-08T12:00:00"), Cost: 100.5, Code: "A1000"},
+		{Id: 101, Description: "Cook eggs", Wbs: `1.2\.1`, CalendarId: 7, Duration: 8 * time.Hour, Start: date("2024-01-09T08:00:00"), Finish: date("2024-01-09T10:00:00"), LateStart: date("2024-01-09T08:00:00"), LateFinish: date("2024-01-09T10:00:00"), PredecessorsId: []int{100}, SuccessorsId: []int{102}, ConstraintType: activity.StartNoEarlierThan, ConstraintDate: date("2024-01-08T13:00:00"), Progress: 0.75, ActualStart: date("2024-01-08T13:00:00"), Code: "A1010"},
+		{Id: 102, Description: "Eat eggs", Wbs: `1.2\.1`, Duration: 30 * time.Minute, Start: date("2024-01-09T10:00:00"), Finish: date("2024-01-09T10:30:00"), TotalFloat: 90 * time.Minute, FreeFloat: 90 * time.Minute, PredecessorsId: []int{101}, SuccessorsId: []int{}, Relationships: map[int]activity.Relationship{101: {Type: activity.StartToStart, Lag: 15 * time.Minute}}, ConstraintType: activity.MustFinishOn, ConstraintDate: date("2024-01-09T12:00:00"), Code: "A1020"},
+	}
+	for i, a := range p.Activities() {
+		if !reflect.DeepEqual(a, want[i]) {
+			t.Errorf("got %+v, want %+v", a, want[i])
+		}
+	}
+
+	if len(p.Calendars) != 1 {
+		t.Fatalf("got %d calendars, want 1", len(p.Calendars))
+	}
+	c := p.Calendars[0]
+	if c.Id != 7 || c.Name != "Standard" || !c.Default {
+		t.Errorf("got %d %q %v, want 7 \"Standard\" true", c.Id, c.Name, c.Default)
+	}
+	if !slices.Equal(c.WorkDays, []time.Weekday{time.Monday, time.Tuesday}) {
+		t.Errorf("got work days %v, want [Monday Tuesday]", c.WorkDays)
+	}
+	shifts := []calendar.Shift{{Start: 8 * time.Hour, Finish: 12 * time.Hour}, {Start: 13 * time.Hour, Finish: 17 * time.Hour}}
+	if !slices.Equal(c.Shifts, shifts) {
+		t.Errorf("got shifts %v, want %v", c.Shifts, shifts)
+	}
+	if len(c.Holidays) != 1 || !c.Holidays[0].Equal(time.Date(2024, time.December, 25, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("got holidays %v, want [2024-12-25]", c.Holidays)
+	}
+}
+
+func TestPMXMLToProjectErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"<APIBusinessObjects></APIBusinessObjects>",
+		"<APIBusinessObjects><Project><PlannedStartDate>tomorrow</PlannedStartDate></Project></APIBusinessObjects>",
+		"<APIBusinessObjects><Project><Activity><ObjectId>1</ObjectId><PlannedDuration>long</PlannedDuration></Activity></Project></APIBusinessObjects>",
+		"<APIBusinessObjects><Project><Activity><ObjectId>1</ObjectId><PrimaryConstraintType>Soon</PrimaryConstraintType></Activity></Project></APIBusinessObjects>",
+		"<APIBusinessObjects><Calendar><StandardWorkWeek><StandardWorkHours><DayOfWeek>Monday</DayOfWeek><WorkTime><Start>8h</Start></WorkTime></StandardWorkHours></StandardWorkWeek></Calendar><Project/></APIBusinessObjects>",
+	}
+	for i, test := range tests {
+		if _, err := PMXMLToProject(strings.NewReader(test)); err == nil {
+			t.Errorf("test %d: got no error, want an error", i)
+		}
+	}
+}
+
+func TestProjectToPMXMLRoundTrip(t *testing.T) {
+	p, err := PMXMLToProject(strings.NewReader(spmxml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = ProjectToPMXML(p, &buf); err != nil {
+		t.Fatal(err)
+	}
+	// the codes of the elements of the work breakdown structure are not split at their dots
+	if n := strings.Count(buf.String(), "<WBS>"); n != 2 || !strings.Contains(buf.String(), "<Code>2.1</Code>") {
+		t.Errorf("got %d elements of the work breakdown structure in %s, want 1 and 2.1", n, buf.String())
+	}
+
+	got, err := PMXMLToProject(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != p.Name || !got.StartDate.Equal(p.StartDate) || !got.DataDate.Equal(p.DataDate) {
+		t.Errorf("got %q %v %v, want %q %v %v", got.Name, got.StartDate, got.DataDate, p.Name, p.StartDate, p.DataDate)
+	}
+	want := p.Activities()
+	// activities without calendar are exported with the default calendar
+	want[2].CalendarId = 7
+	if !reflect.DeepEqual(got.Activities(), want) {
+		for i, a := range got.Activities() {
+			t.Errorf("got %+v, want %+v", a, want[i])
+		}
+	}
+	if !reflect.DeepEqual(got.Calendars, p.Calendars) {
+		t.Errorf("got calendars %+v, want %+v", got.Calendars, p.Calendars)
+	}
+}
+
+func TestActivitiesToPMXML(t *testing.T) {
+	t1 := date("2024-01-08T08:00:00")
+	activities := []*activity.Activity{
+		{Id: 1, Description: "Buy eggs", Wbs: "A.1", Duration: 30 * time.Minute, Start: t1.Add(time.Hour), PredecessorsId: []int{}, SuccessorsId: []int{2}},
+		{Id: 2, Description: "Cook eggs", Wbs: "A.2", Start: t1, PredecessorsId: []int{1}, SuccessorsId: []int{}},
+	}
+	var buf bytes.Buffer
+	if err := ActivitiesToPMXML(activities, nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	s := buf.String()
+	for _, want := range []string{
+		`<APIBusinessObjects xmlns="http://xmlns.oracle.com/Primavera/P6/V19.12/API/BusinessObjects">`,
+		"<Name>Continuous</Name>",
+		"<PlannedStartDate>2024-01-08T08:00:00</PlannedStartDate>",
+		"<Code>A</Code>",
+		"<Type>Finish Milestone</Type>",
+		"<Type>Finish to Start</Type>",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("got %s, want it to contain %q", s, want)
+		}
+	}
+
+	p, err := PMXMLToProject(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := p.Calendars[0]
+	if len(c.WorkDays) != 7 || !slices.Equal(c.Shifts, []calendar.Shift{{Start: 0, Finish: 24 * time.Hour}}) {
+		t.Errorf("got %v %v, want a continuous calendar", c.WorkDays, c.Shifts)
+	}
+	if a, _ := p.Activity(2); a.Wbs != "A.2" {
+		t.Errorf("got wbs %q, want %q", a.Wbs, "A.2")
+	}
+}
+
+func TestExportToDb(t *testing.T) {
+	sqldb, err := db.New("test.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("test.db")
+
+	if err = ExportToDb(sqldb, strings.NewReader(spmxml), db.None); err != nil {
+		t.Error(err)
+	}
+	activities, err := sqldb.GetActivitiesAll()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(activities) != 3 {
+		t.Errorf("got %d activities, want 3", len(activities))
+	}
+	calendars, err := sqldb.GetCalendarsAll()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(calendars) != 1 {
+		t.Errorf("got %d calendars, want 1", len(calendars))
+	}
+}
@@ -0,0 +1,196 @@
+package pxer
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vanillaiice/verano/project/calendar"
+)
+
+// Origin of the dates of calendar exceptions, which are numbers of days since this date.
+var exceptionEpoch = time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+
+// node is an element of the calendar data of a XER calendar, written as '(0||name(params)(children))'.
+type node struct {
+	name     string
+	params   map[string]string
+	children []*node
+}
+
+// child returns the child of the node named 'name', or nil if there is none.
+func (n *node) child(name string) *node {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// calendarData is the working time described by the calendar data of a XER calendar.
+type calendarData struct {
+	shifts            map[time.Weekday][]calendar.Shift // Shifts of each work day
+	holidays          []time.Time                       // Days without working time
+	workingExceptions []time.Time                       // Days with working time different from the work week
+}
+
+// parseCalendarData parses the calendar data 'data' (the 'clndr_data' field of the CALENDAR table).
+func parseCalendarData(data string) (cd *calendarData, err error) {
+	data = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\r', '\n', 0x7f:
+			return -1
+		}
+		return r
+	}, data)
+	root, pos, err := parseNode(data, 0)
+	if err != nil {
+		return
+	}
+	if pos != len(data) {
+		return nil, fmt.Errorf("unexpected data at offset %d of calendar data", pos)
+	}
+
+	cd = &calendarData{shifts: make(map[time.Weekday][]calendar.Shift)}
+	if daysOfWeek := root.child("DaysOfWeek"); daysOfWeek != nil {
+		for _, day := range daysOfWeek.children {
+			n, err := strconv.Atoi(day.name)
+			if err != nil || n < 1 || n > 7 {
+				return nil, fmt.Errorf("invalid day of week %q in calendar data", day.name)
+			}
+			shifts, err := parseShifts(day)
+			if err != nil {
+				return nil, err
+			}
+			if len(shifts) > 0 {
+				// days are numbered from 1 for Sunday to 7 for Saturday
+				cd.shifts[time.Weekday(n-1)] = shifts
+			}
+		}
+	}
+	if exceptions := root.child("Exceptions"); exceptions != nil {
+		for _, exception := range exceptions.children {
+			days, err := strconv.Atoi(exception.params["d"])
+			if err != nil {
+				return nil, fmt.Errorf("invalid exception date %q in calendar data", exception.params["d"])
+			}
+			day := exceptionEpoch.AddDate(0, 0, days)
+			if len(exception.children) == 0 {
+				cd.holidays = append(cd.holidays, day)
+			} else {
+				cd.workingExceptions = append(cd.workingExceptions, day)
+			}
+		}
+	}
+	return
+}
+
+// formatCalendarData formats the working time of the calendar 'c' as calendar data
+// (the 'clndr_data' field of the CALENDAR table), its holidays being written as exceptions.
+func formatCalendarData(c *calendar.Calendar) string {
+	var sb strings.Builder
+	sb.WriteString("(0||CalendarData()((0||DaysOfWeek()(")
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		fmt.Fprintf(&sb, "(0||%d()(", d+1)
+		if slices.Contains(c.WorkDays, d) {
+			for i, shift := range c.Shifts {
+				fmt.Fprintf(&sb, "(0||%d(s|%s|f|%s)())", i, formatClock(shift.Start), formatClock(shift.Finish))
+			}
+		}
+		sb.WriteString("))")
+	}
+	sb.WriteString("))(0||VIEW(ShowTotal|Y)())(0||Exceptions()(")
+	for i, h := range c.Holidays {
+		y, m, d := h.Date()
+		days := int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Sub(exceptionEpoch) / (24 * time.Hour))
+		fmt.Fprintf(&sb, "(0||%d(d|%d)())", i, days)
+	}
+	sb.WriteString("))))")
+	return sb.String()
+}
+
+// formatClock formats an offset from midnight as a time of day (e.g. "08:00"), midnight of the next day being written as "00:00".
+func formatClock(d time.Duration) string {
+	d %= 24 * time.Hour
+	return fmt.Sprintf("%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute))
+}
+
+// parseShifts parses the shifts listed as the children of the 'day' node, written with 's' and 'f' parameters.
+func parseShifts(day *node) (shifts []calendar.Shift, err error) {
+	for _, c := range day.children {
+		start, err := parseClock(c.params["s"])
+		if err != nil {
+			return nil, err
+		}
+		finish, err := parseClock(c.params["f"])
+		if err != nil {
+			return nil, err
+		}
+		// a shift finishing at midnight is written as finishing at 00:00
+		if finish <= start {
+			finish += 24 * time.Hour
+		}
+		shifts = append(shifts, calendar.Shift{Start: start, Finish: finish})
+	}
+	return
+}
+
+// parseClock parses a time of day written as hours and minutes (e.g. "08:00") as an offset from midnight.
+func parseClock(s string) (d time.Duration, err error) {
+	var h, m int
+	if _, err = fmt.Sscanf(s, "%d:%d", &h, &m); err != nil {
+		return 0, fmt.Errorf("invalid time of day %q in calendar data", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// parseNode parses the node starting at 'pos' in 'data', and returns it with the position following it.
+func parseNode(data string, pos int) (n *node, next int, err error) {
+	errMalformed := fmt.Errorf("malformed calendar data at offset %d", pos)
+	if pos >= len(data) || data[pos] != '(' {
+		return nil, pos, errMalformed
+	}
+	sep := strings.Index(data[pos:], "||")
+	if sep == -1 {
+		return nil, pos, errMalformed
+	}
+	pos += sep + 2
+
+	open := strings.IndexByte(data[pos:], '(')
+	if open == -1 {
+		return nil, pos, errMalformed
+	}
+	n = &node{name: data[pos : pos+open], params: make(map[string]string)}
+	pos += open + 1
+
+	closing := strings.IndexByte(data[pos:], ')')
+	if closing == -1 {
+		return nil, pos, errMalformed
+	}
+	if params := data[pos : pos+closing]; params != "" {
+		values := strings.Split(params, "|")
+		for i := 0; i+1 < len(values); i += 2 {
+			n.params[values[i]] = values[i+1]
+		}
+	}
+	pos += closing + 1
+
+	if pos >= len(data) || data[pos] != '(' {
+		return nil, pos, errMalformed
+	}
+	pos++
+	for pos < len(data) && data[pos] == '(' {
+		var c *node
+		if c, pos, err = parseNode(data, pos); err != nil {
+			return nil, pos, err
+		}
+		n.children = append(n.children, c)
+	}
+	if pos+1 >= len(data) || data[pos] != ')' || data[pos+1] != ')' {
+		return nil, pos, fmt.Errorf("malformed calendar data at offset %d", pos)
+	}
+	return n, pos + 2, nil
+}
@@ -0,0 +1,61 @@
+package pxer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vanillaiice/verano/project/calendar"
+)
+
+func TestParseCalendarData(t *testing.T) {
+	cd, err := parseCalendarData(clndrData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cd.shifts) != 5 {
+		t.Errorf("got %d work days, want 5", len(cd.shifts))
+	}
+	if _, ok := cd.shifts[time.Sunday]; ok {
+		t.Error("got shifts on Sunday, want none")
+	}
+	want := []calendar.Shift{{Start: 8 * time.Hour, Finish: 12 * time.Hour}, {Start: 13 * time.Hour, Finish: 17 * time.Hour}}
+	if got := cd.shifts[time.Monday]; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if len(cd.holidays) != 1 || !cd.holidays[0].Equal(time.Date(2024, time.December, 25, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("got holidays %v, want [2024-12-25]", cd.holidays)
+	}
+	if len(cd.workingExceptions) != 1 || !cd.workingExceptions[0].Equal(time.Date(2024, time.December, 28, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("got working exceptions %v, want [2024-12-28]", cd.workingExceptions)
+	}
+}
+
+func TestParseCalendarDataNightShift(t *testing.T) {
+	cd, err := parseCalendarData("(0||CalendarData()((0||DaysOfWeek()((0||2()((0||0(s|22:00|f|00:00)())))))))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := calendar.Shift{Start: 22 * time.Hour, Finish: 24 * time.Hour}
+	if got := cd.shifts[time.Monday]; len(got) != 1 || got[0] != want {
+		t.Errorf("got %v, want [%v]", got, want)
+	}
+}
+
+func TestParseCalendarDataErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"(0||CalendarData(",
+		"(0||CalendarData()()",
+		"(0||CalendarData()())x",
+		"(0||CalendarData()((0||DaysOfWeek()((0||8()())))))",
+		"(0||CalendarData()((0||DaysOfWeek()((0||2()((0||0(s|8h|f|12:00)())))))))",
+		"(0||CalendarData()((0||Exceptions()((0||0(d|christmas)())))))",
+	}
+	for _, test := range tests {
+		if _, err := parseCalendarData(test); err == nil {
+			t.Errorf("got no error for %q, want an error", test)
+		}
+	}
+}
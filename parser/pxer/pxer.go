@@ -0,0 +1,689 @@
+package pxer
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/vanillaiice/verano/activity"
+	"github.com/vanillaiice/verano/db"
+	"github.com/vanillaiice/verano/project"
+	"github.com/vanillaiice/verano/project/calendar"
+	"github.com/vanillaiice/verano/util"
+)
+
+// Layout of the dates in XER files.
+const dateFormat = "2006-01-02 15:04"
+
+// Tables of XER files mapped to Verano, the other tables are reported as unsupported.
+var supportedTables = []string{"PROJECT", "CALENDAR", "PROJWBS", "TASK", "TASKPRED", "TASKRSRC", "PROJCOST"}
+
+// Relationship types of the TASKPRED table.
+var relationshipTypes = map[string]activity.RelationshipType{
+	"PR_FS": activity.FinishToStart,
+	"PR_SS": activity.StartToStart,
+	"PR_FF": activity.FinishToFinish,
+	"PR_SF": activity.StartToFinish,
+}
+
+// Constraint types of the TASK table.
+var constraintTypes = map[string]activity.ConstraintType{
+	"CS_MSOA":      activity.StartNoEarlierThan,
+	"CS_MSOB":      activity.StartNoLaterThan,
+	"CS_MEOA":      activity.FinishNoEarlierThan,
+	"CS_MEOB":      activity.FinishNoLaterThan,
+	"CS_MSO":       activity.MustStartOn,
+	"CS_MEO":       activity.MustFinishOn,
+	"CS_ALAP":      activity.AsLateAsPossible,
+	"CS_MANDSTART": activity.MustStartOn,
+	"CS_MANDFIN":   activity.MustFinishOn,
+}
+
+// Warning describes a construct of a XER file that is not supported by Verano,
+// and that was dropped or approximated during the import.
+type Warning struct {
+	Table   string // Name of the table of the construct
+	Id      string // ID of the row of the construct in the table, empty if the warning is about the whole table
+	Message string // Description of what was dropped or approximated
+}
+
+// String returns the warning in a human readable form (e.g. "TASK 1021: level of effort activity imported as a task").
+func (w Warning) String() string {
+	if w.Id == "" {
+		return fmt.Sprintf("%s: %s", w.Table, w.Message)
+	}
+	return fmt.Sprintf("%s %s: %s", w.Table, w.Id, w.Message)
+}
+
+// ExportToDb populates the database with the activities and calendars of a XER file,
+// and returns the constructs of the file that are not supported.
+func ExportToDb(sqldb *db.DB, reader io.Reader, duplicateInsertPolicy db.DuplicateInsertPolicy) (warnings []Warning, err error) {
+	p, warnings, err := XERToProject(reader)
+	if err != nil {
+		return
+	}
+	if err = sqldb.InsertActivities(p.Activities(), duplicateInsertPolicy); err != nil {
+		return
+	}
+	return warnings, sqldb.InsertCalendars(p.Calendars, duplicateInsertPolicy)
+}
+
+// XERToProject converts the first project of a XER file to a project, mapping the PROJECT, CALENDAR, PROJWBS,
+// TASK, TASKPRED, TASKRSRC and PROJCOST tables. The activities are identified by the 'task_id' of the tasks, and are
+// described by their 'task_name', their 'task_code' being kept as their code. The constructs that are not supported are returned as warnings
+// instead of being silently dropped.
+func XERToProject(reader io.Reader) (p *project.Project, warnings []Warning, err error) {
+	_, tables, err := readTables(reader)
+	if err != nil {
+		return
+	}
+	tablesMap := make(map[string]*table)
+	for _, t := range tables {
+		tablesMap[t.name] = t
+		if !slices.Contains(supportedTables, t.name) {
+			warnings = append(warnings, Warning{Table: t.name, Message: fmt.Sprintf("table not supported, %d row(s) dropped", len(t.rows))})
+		}
+	}
+
+	i := &importer{tables: tablesMap}
+	if err = i.readProject(); err != nil {
+		return
+	}
+	if err = i.readCalendars(); err != nil {
+		return
+	}
+	i.readWbs()
+	if err = i.readTasks(); err != nil {
+		return
+	}
+	if err = i.readRelationships(); err != nil {
+		return
+	}
+	if err = i.readCosts(); err != nil {
+		return
+	}
+
+	project.Normalize(i.activities, project.FromPredecessors)
+	if p, err = project.New(i.name, i.startDate, i.activities); err != nil {
+		return
+	}
+	p.DataDate = i.dataDate
+	p.Calendars = i.calendars
+	p.Metadata["proj_id"] = i.projectId
+	return p, append(warnings, i.warnings...), nil
+}
+
+// importer holds the state of the conversion of the tables of a XER file.
+type importer struct {
+	tables     map[string]*table
+	warnings   []Warning
+	projectId  string
+	name       string
+	startDate  time.Time
+	dataDate   time.Time
+	calendars  []*calendar.Calendar
+	wbs        map[string]string
+	activities []*activity.Activity
+	tasks      map[string]*activity.Activity
+}
+
+// warn records a warning about the row 'id' of the table 'table'.
+func (i *importer) warn(table, id, format string, a ...any) {
+	i.warnings = append(i.warnings, Warning{Table: table, Id: id, Message: fmt.Sprintf(format, a...)})
+}
+
+// rows returns the table named 'name' and its rows belonging to the imported project.
+func (i *importer) rows(name string) (t *table, rows [][]string) {
+	t, ok := i.tables[name]
+	if !ok {
+		return &table{name: name}, nil
+	}
+	for _, row := range t.rows {
+		if projId := t.value(row, "proj_id"); projId == "" || projId == i.projectId {
+			rows = append(rows, row)
+		}
+	}
+	return
+}
+
+// readProject reads the name, start date and data date of the first project of the PROJECT table.
+func (i *importer) readProject() (err error) {
+	t, ok := i.tables["PROJECT"]
+	if !ok || len(t.rows) == 0 {
+		return fmt.Errorf("no project in the PROJECT table")
+	}
+	row := t.rows[0]
+	for _, other := range t.rows[1:] {
+		i.warn("PROJECT", t.value(other, "proj_id"), "only the first project is imported, project %q dropped", t.value(other, "proj_short_name"))
+	}
+	i.projectId = t.value(row, "proj_id")
+	i.name = t.value(row, "proj_short_name")
+	if i.startDate, err = parseDate(t.value(row, "plan_start_date")); err != nil {
+		return
+	}
+	i.dataDate, err = parseDate(t.value(row, "last_recalc_date"))
+	return
+}
+
+// readCalendars reads the calendars of the CALENDAR table, shared by all projects or belonging to the imported project.
+func (i *importer) readCalendars() (err error) {
+	t, rows := i.rows("CALENDAR")
+	for _, row := range rows {
+		id := t.value(row, "clndr_id")
+		c := &calendar.Calendar{Name: t.value(row, "clndr_name"), Default: t.value(row, "default_flag") == "Y"}
+		if c.Id, err = strconv.Atoi(id); err != nil {
+			return fmt.Errorf("CALENDAR %s: invalid clndr_id: %w", id, err)
+		}
+
+		cd, parseErr := parseCalendarData(t.value(row, "clndr_data"))
+		if parseErr != nil || len(cd.shifts) == 0 {
+			i.warn("CALENDAR", id, "calendar data missing or not understood, replaced by a standard calendar")
+			standard := calendar.New(c.Id, c.Name)
+			c.WorkDays, c.Shifts = standard.WorkDays, standard.Shifts
+			i.calendars = append(i.calendars, c)
+			continue
+		}
+
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			shifts, ok := cd.shifts[d]
+			if !ok {
+				continue
+			}
+			c.WorkDays = append(c.WorkDays, d)
+			if c.Shifts == nil {
+				c.Shifts = shifts
+			} else if !slices.Equal(c.Shifts, shifts) {
+				i.warn("CALENDAR", id, "work hours of %s differ from the other work days, the hours of the first work day are used", d)
+			}
+		}
+		c.Holidays = cd.holidays
+		if len(cd.workingExceptions) > 0 {
+			i.warn("CALENDAR", id, "%d exception(s) with working time dropped", len(cd.workingExceptions))
+		}
+		if t.value(row, "base_clndr_id") != "" {
+			i.warn("CALENDAR", id, "inheritance from calendar %s not supported", t.value(row, "base_clndr_id"))
+		}
+		i.calendars = append(i.calendars, c)
+	}
+	return
+}
+
+// readWbs reads the elements of the PROJWBS table, and computes their paths from the root of the project.
+func (i *importer) readWbs() {
+	t, rows := i.rows("PROJWBS")
+	parents := make(map[string]string)
+	names := make(map[string]string)
+	for _, row := range rows {
+		id := t.value(row, "wbs_id")
+		// the project node is the root of the structure, it is not part of the paths
+		if t.value(row, "proj_node_flag") == "Y" {
+			continue
+		}
+		parents[id] = t.value(row, "parent_wbs_id")
+		names[id] = t.value(row, "wbs_short_name")
+	}
+
+	i.wbs = make(map[string]string)
+	for id := range names {
+		var path []string
+		for current, depth := id, 0; current != "" && depth <= len(names); depth++ {
+			name, ok := names[current]
+			if !ok {
+				break
+			}
+			path = append([]string{name}, path...)
+			current = parents[current]
+		}
+		i.wbs[id] = util.FlatWbs(path)
+	}
+}
+
+// readTasks reads the activities of the TASK table.
+func (i *importer) readTasks() (err error) {
+	t, rows := i.rows("TASK")
+	i.tasks = make(map[string]*activity.Activity)
+	for _, row := range rows {
+		id := t.value(row, "task_id")
+		a := &activity.Activity{
+			Description:    t.value(row, "task_name"),
+			Wbs:            i.wbs[t.value(row, "wbs_id")],
+			Code:           t.value(row, "task_code"),
+			PredecessorsId: []int{},
+			SuccessorsId:   []int{},
+		}
+		if a.Id, err = strconv.Atoi(id); err != nil {
+			return fmt.Errorf("TASK %s: invalid task_id: %w", id, err)
+		}
+		if calendarId := t.value(row, "clndr_id"); calendarId != "" {
+			if a.CalendarId, err = strconv.Atoi(calendarId); err != nil {
+				return fmt.Errorf("TASK %s: invalid clndr_id: %w", id, err)
+			}
+		}
+
+		switch taskType := t.value(row, "task_type"); taskType {
+		case "TT_Task", "TT_Rsrc", "TT_Mile", "TT_FinMile", "":
+		default:
+			i.warn("TASK", id, "activity type %s imported as a task", taskType)
+		}
+
+		if a.Duration, err = parseHours(t.value(row, "target_drtn_hr_cnt")); err != nil {
+			return fmt.Errorf("TASK %s: invalid target_drtn_hr_cnt: %w", id, err)
+		}
+		if err = i.readTaskDates(t, row, a); err != nil {
+			return fmt.Errorf("TASK %s: %w", id, err)
+		}
+		if err = i.readTaskProgress(t, row, a); err != nil {
+			return fmt.Errorf("TASK %s: %w", id, err)
+		}
+
+		if constraint := t.value(row, "cstr_type"); constraint != "" {
+			constraintType, ok := constraintTypes[constraint]
+			if !ok {
+				i.warn("TASK", id, "constraint %s dropped", constraint)
+			} else {
+				if constraint == "CS_MANDSTART" || constraint == "CS_MANDFIN" {
+					i.warn("TASK", id, "mandatory constraint %s imported as a must start or finish on constraint", constraint)
+				}
+				a.ConstraintType = constraintType
+				if a.ConstraintDate, err = parseDate(t.value(row, "cstr_date")); err != nil {
+					return fmt.Errorf("TASK %s: invalid cstr_date: %w", id, err)
+				}
+			}
+		}
+		if constraint := t.value(row, "cstr_type2"); constraint != "" {
+			i.warn("TASK", id, "secondary constraint %s dropped", constraint)
+		}
+
+		i.tasks[id] = a
+		i.activities = append(i.activities, a)
+	}
+	return
+}
+
+// readTaskDates reads the early, late and actual dates, and the float of the task 'row' into activity 'a'.
+func (i *importer) readTaskDates(t *table, row []string, a *activity.Activity) (err error) {
+	dates := []struct {
+		field string
+		date  *time.Time
+	}{
+		{"early_start_date", &a.Start},
+		{"early_end_date", &a.Finish},
+		{"late_start_date", &a.LateStart},
+		{"late_end_date", &a.LateFinish},
+		{"act_start_date", &a.ActualStart},
+		{"act_end_date", &a.ActualFinish},
+	}
+	for _, d := range dates {
+		if *d.date, err = parseDate(t.value(row, d.field)); err != nil {
+			return fmt.Errorf("invalid %s: %w", d.field, err)
+		}
+	}
+	// completed and started tasks have no early dates
+	if a.Start.IsZero() {
+		a.Start = a.ActualStart
+	}
+	if a.Finish.IsZero() {
+		a.Finish = a.ActualFinish
+	}
+	if a.TotalFloat, err = parseHours(t.value(row, "total_float_hr_cnt")); err != nil {
+		return fmt.Errorf("invalid total_float_hr_cnt: %w", err)
+	}
+	if a.FreeFloat, err = parseHours(t.value(row, "free_float_hr_cnt")); err != nil {
+		return fmt.Errorf("invalid free_float_hr_cnt: %w", err)
+	}
+	return
+}
+
+// readTaskProgress reads the progress of the task 'row' into activity 'a'.
+// The progress is computed from the remaining duration, which is what drives the schedule in Verano.
+func (i *importer) readTaskProgress(t *table, row []string, a *activity.Activity) (err error) {
+	switch t.value(row, "status_code") {
+	case "TK_Complete":
+		a.Progress = 1
+	case "TK_Active":
+		remaining, err := parseHours(t.value(row, "remain_drtn_hr_cnt"))
+		if err != nil {
+			return fmt.Errorf("invalid remain_drtn_hr_cnt: %w", err)
+		}
+		if a.Duration > 0 && remaining < a.Duration {
+			a.Progress = float32(1 - float64(remaining)/float64(a.Duration))
+		}
+	}
+	return
+}
+
+// readRelationships reads the links between the activities of the TASKPRED table.
+func (i *importer) readRelationships() (err error) {
+	t, rows := i.rows("TASKPRED")
+	for _, row := range rows {
+		id := t.value(row, "task_pred_id")
+		s, ok := i.tasks[t.value(row, "task_id")]
+		if !ok {
+			i.warn("TASKPRED", id, "successor %s is not an activity of the project, relationship dropped", t.value(row, "task_id"))
+			continue
+		}
+		p, ok := i.tasks[t.value(row, "pred_task_id")]
+		if !ok {
+			i.warn("TASKPRED", id, "predecessor %s is not an activity of the project, relationship dropped", t.value(row, "pred_task_id"))
+			continue
+		}
+		relType, ok := relationshipTypes[t.value(row, "pred_type")]
+		if !ok {
+			return fmt.Errorf("TASKPRED %s: unknown pred_type %q", id, t.value(row, "pred_type"))
+		}
+		lag, lagErr := parseHours(t.value(row, "lag_hr_cnt"))
+		if lagErr != nil {
+			return fmt.Errorf("TASKPRED %s: invalid lag_hr_cnt: %w", id, lagErr)
+		}
+		if slices.Contains(s.PredecessorsId, p.Id) {
+			i.warn("TASKPRED", id, "duplicate relationship between %d and %d dropped", p.Id, s.Id)
+			continue
+		}
+		s.PredecessorsId = append(s.PredecessorsId, p.Id)
+		if rel := (activity.Relationship{Type: relType, Lag: lag}); rel != (activity.Relationship{}) {
+			if err = s.SetRelationship(p.Id, rel); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// readCosts reads the planned costs of the resource assignments of the TASKRSRC table and of the expenses
+// of the PROJCOST table, the cost of an activity being the sum of the costs of its assignments and expenses.
+func (i *importer) readCosts() (err error) {
+	for _, name := range []string{"TASKRSRC", "PROJCOST"} {
+		t, rows := i.rows(name)
+		for _, row := range rows {
+			a, ok := i.tasks[t.value(row, "task_id")]
+			if !ok {
+				continue
+			}
+			cost := t.value(row, "target_cost")
+			if cost == "" {
+				continue
+			}
+			value, err := strconv.ParseFloat(cost, 64)
+			if err != nil {
+				return fmt.Errorf("%s %s: invalid target_cost: %w", name, t.value(row, t.fields[0]), err)
+			}
+			a.Cost += value
+		}
+	}
+	if _, rows := i.rows("TASKRSRC"); len(rows) > 0 {
+		i.warn("TASKRSRC", "", "only the planned costs of the resource assignments are imported")
+	}
+	return
+}
+
+// parseDate parses a date of a XER file, an empty string being parsed as the zero time.
+func parseDate(s string) (t time.Time, err error) {
+	if s == "" {
+		return
+	}
+	return time.ParseInLocation(dateFormat, s, time.Local)
+}
+
+// parseHours parses a number of hours of a XER file as a duration, an empty string being parsed as zero.
+func parseHours(s string) (d time.Duration, err error) {
+	if s == "" {
+		return
+	}
+	hours, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return
+	}
+	return time.Duration(math.Round(hours*float64(time.Hour)/float64(time.Second))) * time.Second, nil
+}
+
+// Version of P6 written in the header of the exported XER files.
+const exportVersion = "19.12"
+
+// Id of the project, which is also the id of the root of its work breakdown structure, in the exported XER files.
+const exportProjectId = "1"
+
+// Types of the TASKPRED table, by relationship type.
+var predTypes = map[activity.RelationshipType]string{
+	activity.FinishToStart:  "PR_FS",
+	activity.StartToStart:   "PR_SS",
+	activity.FinishToFinish: "PR_FF",
+	activity.StartToFinish:  "PR_SF",
+}
+
+// Constraints of the TASK table, by constraint type.
+var cstrTypes = map[activity.ConstraintType]string{
+	activity.StartNoEarlierThan:  "CS_MSOA",
+	activity.StartNoLaterThan:    "CS_MSOB",
+	activity.FinishNoEarlierThan: "CS_MEOA",
+	activity.FinishNoLaterThan:   "CS_MEOB",
+	activity.MustStartOn:         "CS_MSO",
+	activity.MustFinishOn:        "CS_MEO",
+	activity.AsLateAsPossible:    "CS_ALAP",
+}
+
+// ProjectToXER writes the project 'p' to a XER file that can be imported in P6,
+// with its calendars, work breakdown structure, activities, relationships and costs.
+// Dates are written to the minute, and costs are written as expenses of the activities.
+// The activities without calendar are assigned the default calendar, since P6 requires a calendar for every activity.
+// If the project has no calendars, a continuous calendar (every day, all day) is written as the default calendar.
+func ProjectToXER(p *project.Project, writer io.Writer) (err error) {
+	return writeXER(writer, p.Name, p.StartDate, p.DataDate, p.Activities(), p.Calendars)
+}
+
+// ActivitiesToXER writes the 'activities' and 'calendars' to a XER file as a project named "Verano",
+// starting at the earliest start of the activities. See ProjectToXER for the details of the conversion.
+func ActivitiesToXER(activities []*activity.Activity, calendars []*calendar.Calendar, writer io.Writer) (err error) {
+	var startDate time.Time
+	for _, a := range activities {
+		if !a.Start.IsZero() && (startDate.IsZero() || a.Start.Before(startDate)) {
+			startDate = a.Start
+		}
+	}
+	return writeXER(writer, "Verano", startDate, time.Time{}, activities, calendars)
+}
+
+// writeXER writes a XER file with a project named 'name', starting at 'startDate',
+// with progress recorded up to 'dataDate', and with the given 'activities' and 'calendars'.
+func writeXER(writer io.Writer, name string, startDate, dataDate time.Time, activities []*activity.Activity, calendars []*calendar.Calendar) (err error) {
+	if name == "" {
+		name = "Verano"
+	}
+	if len(calendars) == 0 {
+		calendars = []*calendar.Calendar{{
+			Id:       1,
+			Name:     "Continuous",
+			Default:  true,
+			WorkDays: []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday},
+			Shifts:   []calendar.Shift{{Start: 0, Finish: 24 * time.Hour}},
+		}}
+	}
+	defaultCalendar := calendar.Default(calendars)
+	if defaultCalendar == nil {
+		defaultCalendar = calendars[0]
+	}
+
+	calendarTable := &table{
+		name:   "CALENDAR",
+		fields: []string{"clndr_id", "default_flag", "clndr_name", "proj_id", "base_clndr_id", "clndr_type", "day_hr_cnt", "week_hr_cnt", "clndr_data"},
+	}
+	for _, c := range calendars {
+		var day time.Duration
+		for _, shift := range c.Shifts {
+			day += shift.Finish - shift.Start
+		}
+		calendarTable.rows = append(calendarTable.rows, []string{
+			strconv.Itoa(c.Id),
+			formatFlag(c == defaultCalendar),
+			c.Name,
+			"",
+			"",
+			"CA_Base",
+			formatHours(day),
+			formatHours(day * time.Duration(len(c.WorkDays))),
+			formatCalendarData(c),
+		})
+	}
+
+	projectTable := &table{
+		name:   "PROJECT",
+		fields: []string{"proj_id", "proj_short_name", "clndr_id", "plan_start_date", "last_recalc_date"},
+		rows:   [][]string{{exportProjectId, name, strconv.Itoa(defaultCalendar.Id), formatDate(startDate), formatDate(dataDate)}},
+	}
+
+	wbsTable := &table{
+		name:   "PROJWBS",
+		fields: []string{"wbs_id", "proj_id", "seq_num", "proj_node_flag", "wbs_short_name", "wbs_name", "parent_wbs_id"},
+		rows:   [][]string{{exportProjectId, exportProjectId, "0", "Y", name, name, ""}},
+	}
+	// the elements are numbered after the root, in the order in which they appear in the activities
+	wbsIds := make(map[string]string)
+	wbsIdOf := func(path string) string {
+		parentId := exportProjectId
+		if path == "" {
+			return parentId
+		}
+		names := util.UnflatWbs(path)
+		for n := range names {
+			prefix := util.FlatWbs(names[:n+1])
+			id, ok := wbsIds[prefix]
+			if !ok {
+				id = strconv.Itoa(len(wbsTable.rows) + 1)
+				wbsIds[prefix] = id
+				wbsTable.rows = append(wbsTable.rows, []string{id, exportProjectId, strconv.Itoa(len(wbsTable.rows)), "N", names[n], names[n], parentId})
+			}
+			parentId = id
+		}
+		return parentId
+	}
+
+	taskTable := &table{
+		name: "TASK",
+		fields: []string{
+			"task_id", "proj_id", "wbs_id", "clndr_id", "task_code", "task_name", "task_type", "status_code",
+			"complete_pct_type", "phys_complete_pct", "target_drtn_hr_cnt", "remain_drtn_hr_cnt",
+			"total_float_hr_cnt", "free_float_hr_cnt", "act_start_date", "act_end_date",
+			"early_start_date", "early_end_date", "late_start_date", "late_end_date",
+			"target_start_date", "target_end_date", "cstr_type", "cstr_date",
+		},
+	}
+	predTable := &table{
+		name:   "TASKPRED",
+		fields: []string{"task_pred_id", "task_id", "pred_task_id", "proj_id", "pred_proj_id", "pred_type", "lag_hr_cnt"},
+	}
+	costTable := &table{
+		name:   "PROJCOST",
+		fields: []string{"cost_item_id", "proj_id", "task_id", "cost_name", "target_cost", "cost_load_type"},
+	}
+
+	for _, a := range activities {
+		id := strconv.Itoa(a.Id)
+		calendarId := a.CalendarId
+		if calendarId == 0 {
+			calendarId = defaultCalendar.Id
+		}
+
+		taskType := "TT_Task"
+		if a.Duration == 0 {
+			taskType = "TT_Mile"
+			if len(a.PredecessorsId) > 0 {
+				taskType = "TT_FinMile"
+			}
+		}
+		status := "TK_NotStart"
+		if a.IsCompleted() || a.Progress >= 1 {
+			status = "TK_Complete"
+		} else if a.IsStarted() || a.Progress > 0 {
+			status = "TK_Active"
+		}
+		var cstrType, cstrDate string
+		if a.ConstraintType != activity.NoConstraint {
+			cstrType, cstrDate = cstrTypes[a.ConstraintType], formatDate(a.ConstraintDate)
+		}
+
+		taskTable.rows = append(taskTable.rows, []string{
+			id,
+			exportProjectId,
+			wbsIdOf(a.Wbs),
+			strconv.Itoa(calendarId),
+			activityCode(a),
+			a.Description,
+			taskType,
+			status,
+			"CP_Phys",
+			strconv.FormatFloat(float64(a.Progress)*100, 'f', -1, 32),
+			formatHours(a.Duration),
+			formatHours(a.RemainingDuration()),
+			formatHours(a.TotalFloat),
+			formatHours(a.FreeFloat),
+			formatDate(a.ActualStart),
+			formatDate(a.ActualFinish),
+			formatDate(a.Start),
+			formatDate(a.Finish),
+			formatDate(a.LateStart),
+			formatDate(a.LateFinish),
+			formatDate(a.Start),
+			formatDate(a.Finish),
+			cstrType,
+			cstrDate,
+		})
+
+		for _, predecessorId := range a.PredecessorsId {
+			rel := a.Relationship(predecessorId)
+			predTable.rows = append(predTable.rows, []string{
+				strconv.Itoa(len(predTable.rows) + 1),
+				id,
+				strconv.Itoa(predecessorId),
+				exportProjectId,
+				exportProjectId,
+				predTypes[rel.Type],
+				formatHours(rel.Lag),
+			})
+		}
+
+		if a.Cost != 0 {
+			costTable.rows = append(costTable.rows, []string{
+				strconv.Itoa(len(costTable.rows) + 1),
+				exportProjectId,
+				id,
+				a.Description,
+				strconv.FormatFloat(a.Cost, 'f', -1, 64),
+				"CL_Uniform",
+			})
+		}
+	}
+
+	header := []string{exportVersion, time.Now().Format("2006-01-02"), "Project", "admin", "admin", "dbxDatabaseNoName", "Project Management", "USD"}
+	return writeTables(writer, header, []*table{projectTable, calendarTable, wbsTable, taskTable, predTable, costTable})
+}
+
+// activityCode returns the code of the activity 'a', or its id if it has no code.
+func activityCode(a *activity.Activity) string {
+	if a.Code == "" {
+		return strconv.Itoa(a.Id)
+	}
+	return a.Code
+}
+
+// formatDate formats a date in the layout of XER files, the zero time being formatted as an empty string.
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(time.Local).Format(dateFormat)
+}
+
+// formatHours formats a duration as a number of hours.
+func formatHours(d time.Duration) string {
+	return strconv.FormatFloat(d.Hours(), 'f', -1, 64)
+}
+
+// formatFlag formats a boolean as a flag of a XER file.
+func formatFlag(b bool) string {
+	if b {
+		return "Y"
+	}
+	return "N"
+}
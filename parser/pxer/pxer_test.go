@@ -0,0 +1,358 @@
+package pxer
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vanillaiice/verano/activity"
+	"github.com/vanillaiice/verano/db"
+)
+
+// Calendar data of a XER file, whose line breaks are written as DEL characters.
+var clndrData = "(0||CalendarData()(\x7f" +
+	"  (0||DaysOfWeek()(\x7f" +
+	"    (0||1()())\x7f" +
+	"    (0||2()((0||0(s|08:00|f|12:00)())(0||1(s|13:00|f|17:00)())))\x7f" +
+	"    (0||3()((0||0(s|08:00|f|12:00)())(0||1(s|13:00|f|17:00)())))\x7f" +
+	"    (0||4()((0||0(s|08:00|f|12:00)())(0||1(s|13:00|f|17:00)())))\x7f" +
+	"    (0||5()((0||0(s|08:00|f|12:00)())(0||1(s|13:00|f|17:00)())))\x7f" +
+	"    (0||6()((0||0(s|08:00|f|12:00)())(0||1(s|13:00|f|16:00)())))\x7f" +
+	"    (0||7()())))\x7f" +
+	"  (0||VIEW(ShowTotal|Y)())\x7f" +
+	"  (0||Exceptions()(\x7f" +
+	"    (0||0(d|45651)())\x7f" +
+	"    (0||1(d|45654)((0||0(s|08:00|f|12:00)())))))))"
+
+// xerLines joins lines of tab separated values into the content of a XER file.
+func xerLines(lines ...[]string) string {
+	var sb strings.Builder
+	for _, line := range lines {
+		sb.WriteString(strings.Join(line, "\t"))
+		sb.WriteString("\r\n")
+	}
+	return sb.String()
+}
+
+var sxer = xerLines(
+	[]string{"ERMHDR", "19.12", "2025-01-06", "Project", "admin", "Admin", "dbxDatabaseNoName", "Project Management", "USD"},
+	[]string{"%T", "PROJECT"},
+	[]string{"%F", "proj_id", "proj_short_name", "plan_start_date", "last_recalc_date"},
+	[]string{"%R", "100", "Eggs", "2025-01-06 08:00", "2025-01-07 08:00"},
+	[]string{"%R", "101", "Other", "2025-02-03 08:00", ""},
+	[]string{"%T", "CALENDAR"},
+	[]string{"%F", "clndr_id", "default_flag", "clndr_name", "proj_id", "base_clndr_id", "clndr_data"},
+	[]string{"%R", "1", "Y", "Standard", "", "", clndrData},
+	[]string{"%R", "2", "N", "Broken", "100", "", "(0||CalendarData("},
+	[]string{"%R", "3", "N", "Other", "101", "", ""},
+	[]string{"%T", "PROJWBS"},
+	[]string{"%F", "wbs_id", "proj_id", "proj_node_flag", "wbs_short_name", "parent_wbs_id"},
+	[]string{"%R", "10", "100", "Y", "EGGS", ""},
+	[]string{"%R", "11", "100", "N", "1", "10"},
+	[]string{"%R", "12", "100", "N", "2.1", "11"},
+	[]string{"%T", "TASK"},
+	[]string{"%F", "task_id", "proj_id", "wbs_id", "clndr_id", "task_code", "task_name", "task_type", "status_code", "target_drtn_hr_cnt", "remain_drtn_hr_cnt", "total_float_hr_cnt", "free_float_hr_cnt", "act_start_date", "act_end_date", "early_start_date", "early_end_date", "late_start_date", "late_end_date", "cstr_type", "cstr_date", "cstr_type2"},
+	[]string{"%R", "1000", "100", "11", "1", "A1000", "Buy eggs", "TT_Task", "TK_Complete", "4", "0", "", "", "2025-01-06 08:00", "2025-01-06 13:00", "", "", "", "", "", "", ""},
+	[]string{"%R", "1001", "100", "12", "1", "A1010", "Cook eggs", "TT_Task", "TK_Active", "8", "2", "0", "0", "2025-01-06 13:00", "", "2025-01-07 08:00", "2025-01-07 10:00", "2025-01-07 08:00", "2025-01-07 10:00", "CS_MSOA", "2025-01-06 13:00", "CS_MEOB"},
+	[]string{"%R", "1002", "100", "12", "", "A1020", "Eat eggs", "TT_LOE", "TK_NotStart", "0.5", "0.5", "1.5", "1.5", "", "", "2025-01-07 10:00", "2025-01-07 10:30", "2025-01-07 11:30", "2025-01-07 12:00", "CS_MANDFIN", "2025-01-07 12:00", ""},
+	[]string{"%R", "2000", "101", "", "3", "B1000", "Other task", "TT_Task", "TK_NotStart", "8", "8", "", "", "", "", "", "", "", "", "", "", ""},
+	[]string{"%T", "TASKPRED"},
+	[]string{"%F", "task_pred_id", "task_id", "pred_task_id", "proj_id", "pred_proj_id", "pred_type", "lag_hr_cnt"},
+	[]string{"%R", "1", "1001", "1000", "100", "100", "PR_FS", "0"},
+	[]string{"%R", "2", "1002", "1001", "100", "100", "PR_SS", "0.25"},
+	[]string{"%R", "3", "1002", "2000", "100", "101", "PR_FS", "0"},
+	[]string{"%T", "TASKRSRC"},
+	[]string{"%F", "taskrsrc_id", "task_id", "proj_id", "rsrc_id", "target_cost"},
+	[]string{"%R", "1", "1000", "100", "1", "60"},
+	[]string{"%R", "2", "1000", "100", "2", "40.5"},
+	[]string{"%T", "RSRC"},
+	[]string{"%F", "rsrc_id", "rsrc_name"},
+	[]string{"%R", "1", "Cook"},
+	[]string{"%R", "2", "Shop"},
+	[]string{"%E"},
+)
+
+// date returns the local time of a date written in the layout of XER files.
+func date(s string) time.Time {
+	t, err := time.ParseInLocation(dateFormat, s, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestXERToProject(t *testing.T) {
+	p, warnings, err := XERToProject(strings.NewReader(sxer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Name != "Eggs" {
+		t.Errorf("got name %q, want %q", p.Name, "Eggs")
+	}
+	if !p.StartDate.Equal(date("2025-01-06 08:00")) {
+		t.Errorf("got start date %v, want %v", p.StartDate, date("2025-01-06 08:00"))
+	}
+	if !p.DataDate.Equal(date("2025-01-07 08:00")) {
+		t.Errorf("got data date %v, want %v", p.DataDate, date("2025-01-07 08:00"))
+	}
+
+	activities := p.Activities()
+	if len(activities) != 3 {
+		t.Fatalf("got %d activities, want 3", len(activities))
+	}
+	buy, cook, eat := activities[0], activities[1], activities[2]
+
+	if buy.Id != 1000 || buy.Code != "A1000" || buy.Description != "Buy eggs" || buy.Wbs != "1" || buy.CalendarId != 1 {
+		t.Errorf("got %d %q %q %q %d, want 1000 \"A1000\" \"Buy eggs\" \"1\" 1", buy.Id, buy.Code, buy.Description, buy.Wbs, buy.CalendarId)
+	}
+	if buy.Duration != 4*time.Hour || buy.Progress != 1 || buy.Cost != 100.5 {
+		t.Errorf("got %v %v %v, want 4h0m0s 1 100.5", buy.Duration, buy.Progress, buy.Cost)
+	}
+	if !buy.ActualStart.Equal(date("2025-01-06 08:00")) || !buy.ActualFinish.Equal(date("2025-01-06 13:00")) {
+		t.Errorf("got actual dates %v %v, want 2025-01-06 08:00 2025-01-06 13:00", buy.ActualStart, buy.ActualFinish)
+	}
+	if !buy.Start.Equal(buy.ActualStart) || !buy.Finish.Equal(buy.ActualFinish) {
+		t.Errorf("got dates %v %v, want the actual dates", buy.Start, buy.Finish)
+	}
+	if !slices.Equal(buy.SuccessorsId, []int{1001}) {
+		t.Errorf("got successors %v, want [1001]", buy.SuccessorsId)
+	}
+
+	// the dot of the code of the element is escaped in the path
+	if cook.Wbs != `1.2\.1` || cook.Progress != 0.75 {
+		t.Errorf("got %q %v, want %q 0.75", cook.Wbs, cook.Progress, `1.2\.1`)
+	}
+	if !cook.Start.Equal(date("2025-01-07 08:00")) || !cook.LateFinish.Equal(date("2025-01-07 10:00")) {
+		t.Errorf("got dates %v %v, want 2025-01-07 08:00 2025-01-07 10:00", cook.Start, cook.LateFinish)
+	}
+	if cook.ConstraintType != activity.StartNoEarlierThan || !cook.ConstraintDate.Equal(date("2025-01-06 13:00")) {
+		t.Errorf("got constraint %v %v, want %v 2025-01-06 13:00", cook.ConstraintType, cook.ConstraintDate, activity.StartNoEarlierThan)
+	}
+	if !slices.Equal(cook.PredecessorsId, []int{1000}) || !slices.Equal(cook.SuccessorsId, []int{1002}) {
+		t.Errorf("got links %v %v, want [1000] [1002]", cook.PredecessorsId, cook.SuccessorsId)
+	}
+
+	if eat.CalendarId != 0 || eat.Duration != 30*time.Minute || eat.TotalFloat != 90*time.Minute {
+		t.Errorf("got %d %v %v, want 0 30m0s 1h30m0s", eat.CalendarId, eat.Duration, eat.TotalFloat)
+	}
+	if eat.ConstraintType != activity.MustFinishOn {
+		t.Errorf("got constraint %v, want %v", eat.ConstraintType, activity.MustFinishOn)
+	}
+	if !slices.Equal(eat.PredecessorsId, []int{1001}) {
+		t.Errorf("got predecessors %v, want [1001]", eat.PredecessorsId)
+	}
+	if rel := eat.Relationship(1001); rel != (activity.Relationship{Type: activity.StartToStart, Lag: 15 * time.Minute}) {
+		t.Errorf("got relationship %v, want SS with 15m0s lag", rel)
+	}
+
+	if len(p.Calendars) != 2 {
+		t.Fatalf("got %d calendars, want 2", len(p.Calendars))
+	}
+	standard, broken := p.Calendars[0], p.Calendars[1]
+	if standard.Id != 1 || standard.Name != "Standard" || !standard.Default {
+		t.Errorf("got %d %q %v, want 1 \"Standard\" true", standard.Id, standard.Name, standard.Default)
+	}
+	workDays := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	if !slices.Equal(standard.WorkDays, workDays) {
+		t.Errorf("got work days %v, want %v", standard.WorkDays, workDays)
+	}
+	if len(standard.Shifts) != 2 || standard.Shifts[1].Finish != 17*time.Hour {
+		t.Errorf("got shifts %v, want 08:00-12:00 and 13:00-17:00", standard.Shifts)
+	}
+	if len(standard.Holidays) != 1 || !standard.Holidays[0].Equal(time.Date(2024, time.December, 25, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("got holidays %v, want [2024-12-25]", standard.Holidays)
+	}
+	if broken.Id != 2 || len(broken.WorkDays) != 5 {
+		t.Errorf("got %d with %d work days, want 2 with 5 work days", broken.Id, len(broken.WorkDays))
+	}
+
+	wantWarnings := []string{
+		"RSRC: table not supported, 2 row(s) dropped",
+		"PROJECT 101: only the first project is imported, project \"Other\" dropped",
+		"CALENDAR 1: work hours of Friday differ from the other work days, the hours of the first work day are used",
+		"CALENDAR 1: 1 exception(s) with working time dropped",
+		"CALENDAR 2: calendar data missing or not understood, replaced by a standard calendar",
+		"TASK 1001: secondary constraint CS_MEOB dropped",
+		"TASK 1002: activity type TT_LOE imported as a task",
+		"TASK 1002: mandatory constraint CS_MANDFIN imported as a must start or finish on constraint",
+		"TASKPRED 3: predecessor 2000 is not an activity of the project, relationship dropped",
+		"TASKRSRC: only the planned costs of the resource assignments are imported",
+	}
+	if len(warnings) != len(wantWarnings) {
+		t.Fatalf("got %d warnings %v, want %d", len(warnings), warnings, len(wantWarnings))
+	}
+	for i, w := range warnings {
+		if w.String() != wantWarnings[i] {
+			t.Errorf("got warning %q, want %q", w.String(), wantWarnings[i])
+		}
+	}
+}
+
+func TestXERToProjectErrors(t *testing.T) {
+	tests := []string{
+		"",
+		xerLines([]string{"%T", "PROJECT"}),
+		xerLines([]string{"ERMHDR", "19.12"}, []string{"%X"}),
+		xerLines([]string{"ERMHDR", "19.12"}, []string{"%T", "TASK"}),
+		xerLines([]string{"ERMHDR", "19.12"}, []string{"%T", "PROJECT"}, []string{"%F", "proj_id", "plan_start_date"}, []string{"%R", "1", "tomorrow"}),
+	}
+	for i, test := range tests {
+		if _, _, err := XERToProject(strings.NewReader(test)); err == nil {
+			t.Errorf("test %d: got no error, want an error", i)
+		}
+	}
+}
+
+func TestExportToDb(t *testing.T) {
+	sqldb, err := db.New("test.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("test.db")
+
+	warnings, err := ExportToDb(sqldb, strings.NewReader(sxer), db.None)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(warnings) == 0 {
+		t.Error("got no warnings, want warnings")
+	}
+
+	activities, err := sqldb.GetActivitiesAll()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(activities) != 3 {
+		t.Errorf("got %d activities, want 3", len(activities))
+	}
+	calendars, err := sqldb.GetCalendarsAll()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(calendars) != 2 {
+		t.Errorf("got %d calendars, want 2", len(calendars))
+	}
+}
+
+func TestProjectToXERRoundTrip(t *testing.T) {
+	p, _, err := XERToProject(strings.NewReader(sxer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = ProjectToXER(p, &buf); err != nil {
+		t.Fatal(err)
+	}
+	exported := buf.String()
+
+	// the codes of the tasks are kept, rather than replaced by their ids,
+	// and the codes of the elements of the work breakdown structure are not split at their dots
+	_, tables, err := readTables(strings.NewReader(exported))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tbl := range tables {
+		switch tbl.name {
+		case "TASK":
+			var codes []string
+			for _, row := range tbl.rows {
+				codes = append(codes, tbl.value(row, "task_code"))
+			}
+			if want := []string{"A1000", "A1010", "A1020"}; !slices.Equal(codes, want) {
+				t.Errorf("got task codes %v, want %v", codes, want)
+			}
+		case "PROJWBS":
+			var codes []string
+			for _, row := range tbl.rows[1:] {
+				codes = append(codes, tbl.value(row, "wbs_short_name"))
+			}
+			if want := []string{"1", "2.1"}; !slices.Equal(codes, want) {
+				t.Errorf("got work breakdown structure codes %v, want %v", codes, want)
+			}
+		}
+	}
+
+	got, warnings, err := XERToProject(strings.NewReader(exported))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("got warnings %v, want none", warnings)
+	}
+	if got.Name != p.Name || !got.StartDate.Equal(p.StartDate) || !got.DataDate.Equal(p.DataDate) {
+		t.Errorf("got %q %v %v, want %q %v %v", got.Name, got.StartDate, got.DataDate, p.Name, p.StartDate, p.DataDate)
+	}
+
+	want := p.Activities()
+	// activities without calendar are exported with the default calendar
+	want[2].CalendarId = 1
+	if !reflect.DeepEqual(got.Activities(), want) {
+		for i, a := range got.Activities() {
+			t.Errorf("got %+v, want %+v", a, want[i])
+		}
+	}
+	if !reflect.DeepEqual(got.Calendars, p.Calendars) {
+		t.Errorf("got calendars %+v, want %+v", got.Calendars, p.Calendars)
+	}
+
+	// exporting the imported project again gives the same tables
+	buf.Reset()
+	if err = ProjectToXER(got, &buf); err != nil {
+		t.Fatal(err)
+	}
+	_, gotTables, _ := strings.Cut(buf.String(), "\r\n")
+	_, wantTables, _ := strings.Cut(exported, "\r\n")
+	if gotTables != wantTables {
+		t.Errorf("got %s, want %s", gotTables, wantTables)
+	}
+}
+
+func TestActivitiesToXER(t *testing.T) {
+	t1 := date("2025-01-06 08:00")
+	activities := []*activity.Activity{
+		{Id: 1, Description: "Buy eggs", Wbs: "A.1", Duration: 30 * time.Minute, Start: t1.Add(time.Hour), Finish: t1.Add(90 * time.Minute), PredecessorsId: []int{}, SuccessorsId: []int{2}, Cost: 12.5},
+		{Id: 2, Description: "Cook\teggs", Wbs: "A.2", Duration: 0, Start: t1, Finish: t1, PredecessorsId: []int{1}, SuccessorsId: []int{}, ConstraintType: activity.MustFinishOn, ConstraintDate: t1},
+	}
+	var buf bytes.Buffer
+	if err := ActivitiesToXER(activities, nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	_, tables, err := readTables(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tablesMap := make(map[string]*table)
+	for _, table := range tables {
+		tablesMap[table.name] = table
+	}
+
+	project := tablesMap["PROJECT"]
+	if got := project.value(project.rows[0], "plan_start_date"); got != "2025-01-06 08:00" {
+		t.Errorf("got start date %q, want %q", got, "2025-01-06 08:00")
+	}
+	calendars := tablesMap["CALENDAR"]
+	if len(calendars.rows) != 1 || calendars.value(calendars.rows[0], "week_hr_cnt") != "168" {
+		t.Errorf("got calendars %v, want a continuous calendar", calendars.rows)
+	}
+	wbs := tablesMap["PROJWBS"]
+	if len(wbs.rows) != 4 || wbs.value(wbs.rows[3], "wbs_short_name") != "2" || wbs.value(wbs.rows[3], "parent_wbs_id") != "2" {
+		t.Errorf("got work breakdown structure %v, want the root, A, A.1 and A.2", wbs.rows)
+	}
+	tasks := tablesMap["TASK"]
+	for i, want := range [][]string{{"Buy eggs", "TT_Task", ""}, {"Cook eggs", "TT_FinMile", "CS_MEO"}} {
+		got := []string{tasks.value(tasks.rows[i], "task_name"), tasks.value(tasks.rows[i], "task_type"), tasks.value(tasks.rows[i], "cstr_type")}
+		if !slices.Equal(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	costs := tablesMap["PROJCOST"]
+	if len(costs.rows) != 1 || costs.value(costs.rows[0], "target_cost") != "12.5" {
+		t.Errorf("got costs %v, want 12.5 for activity 1", costs.rows)
+	}
+}
@@ -0,0 +1,131 @@
+package pxer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Markers at the start of the lines of a XER file.
+const (
+	headerMarker = "ERMHDR"
+	tableMarker  = "%T"
+	fieldsMarker = "%F"
+	rowMarker    = "%R"
+	endMarker    = "%E"
+)
+
+// table is a table of a XER file, with its field names and its rows of values.
+type table struct {
+	name   string
+	fields []string
+	rows   [][]string
+}
+
+// index returns the index of the field named 'field', or -1 if the table has no such field.
+func (t *table) index(field string) int {
+	for i, f := range t.fields {
+		if f == field {
+			return i
+		}
+	}
+	return -1
+}
+
+// value returns the value of the field named 'field' in 'row', or an empty string if there is none.
+func (t *table) value(row []string, field string) string {
+	i := t.index(field)
+	if i == -1 || i >= len(row) {
+		return ""
+	}
+	return row[i]
+}
+
+// readTables reads the header and the tables of a XER file.
+// Lines that are not valid UTF-8 are decoded as Latin-1, the usual encoding of XER files.
+func readTables(r io.Reader) (header []string, tables []*table, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var current *table
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(toUTF8(scanner.Text()), "\r")
+		if line == "" {
+			continue
+		}
+		values := strings.Split(line, "\t")
+		switch values[0] {
+		case headerMarker:
+			header = values[1:]
+		case tableMarker:
+			if len(values) < 2 {
+				return nil, nil, fmt.Errorf("line %d: table without name", lineNumber)
+			}
+			current = &table{name: values[1]}
+			tables = append(tables, current)
+		case fieldsMarker:
+			if current == nil {
+				return nil, nil, fmt.Errorf("line %d: fields outside of a table", lineNumber)
+			}
+			current.fields = values[1:]
+		case rowMarker:
+			if current == nil {
+				return nil, nil, fmt.Errorf("line %d: row outside of a table", lineNumber)
+			}
+			current.rows = append(current.rows, values[1:])
+		case endMarker:
+			return header, tables, nil
+		default:
+			return nil, nil, fmt.Errorf("line %d: unknown marker %q", lineNumber, values[0])
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if header == nil {
+		return nil, nil, fmt.Errorf("missing %s header, not a XER file", headerMarker)
+	}
+	return
+}
+
+// writeTables writes the 'header' and the 'tables' of a XER file.
+// Tabs and line breaks in the values are replaced by spaces, since they separate the values and the rows.
+func writeTables(w io.Writer, header []string, tables []*table) (err error) {
+	bw := bufio.NewWriter(w)
+	writeLine := func(marker string, values []string) {
+		bw.WriteString(marker)
+		for _, v := range values {
+			bw.WriteByte('\t')
+			bw.WriteString(valueReplacer.Replace(v))
+		}
+		bw.WriteString("\r\n")
+	}
+	writeLine(headerMarker, header)
+	for _, t := range tables {
+		writeLine(tableMarker, []string{t.name})
+		writeLine(fieldsMarker, t.fields)
+		for _, row := range t.rows {
+			writeLine(rowMarker, row)
+		}
+	}
+	bw.WriteString(endMarker + "\r\n")
+	return bw.Flush()
+}
+
+// valueReplacer replaces the separators of a XER file in values.
+var valueReplacer = strings.NewReplacer("\t", " ", "\r\n", " ", "\r", " ", "\n", " ")
+
+// toUTF8 returns 's' if it is valid UTF-8, or 's' decoded as Latin-1 otherwise.
+func toUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	runes := make([]rune, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		runes = append(runes, rune(s[i]))
+	}
+	return string(runes)
+}
@@ -6,10 +6,14 @@ import (
 	"github.com/tealeg/xlsx/v3"
 	"github.com/vanillaiice/verano/activity"
 	"github.com/vanillaiice/verano/db"
+	"github.com/vanillaiice/verano/parser/tabular"
+	"github.com/vanillaiice/verano/project/calendar"
 	"github.com/vanillaiice/verano/util"
 )
 
-var tableHeader = []string{"Id", "Description", "Duration", "Start", "Finish", "PredecessorsId", "SuccessorsId", "Cost"}
+var tableHeader = tabular.Header()
+
+var calendarTableHeader = []string{"Id", "Name", "Default", "WorkDays", "Shifts", "Holidays"}
 
 // ExportToDb populates the database with activities in xlsx format.
 func ExportToDb(sqldb *db.DB, sheet *xlsx.Sheet, duplicateInsertPolicy db.DuplicateInsertPolicy) (err error) {
@@ -65,87 +69,265 @@ func ActivitiesToXLSX(activities []*activity.Activity, sheet *xlsx.Sheet) {
 		cost.SetFloat(activity.Cost)
 		cells = append(cells, cost)
 
+		relationships := row.AddCell()
+		relationships.SetString(util.FlatRelationships(activity.Relationships))
+		cells = append(cells, relationships)
+
+		calendarId := row.AddCell()
+		calendarId.SetInt(activity.CalendarId)
+		cells = append(cells, calendarId)
+
+		progress := row.AddCell()
+		progress.SetFloat(float64(activity.Progress))
+		cells = append(cells, progress)
+
+		actualStart := row.AddCell()
+		actualStart.SetDateTime(activity.ActualStart)
+		cells = append(cells, actualStart)
+
+		actualFinish := row.AddCell()
+		actualFinish.SetDateTime(activity.ActualFinish)
+		cells = append(cells, actualFinish)
+
+		wbs := row.AddCell()
+		wbs.SetString(activity.Wbs)
+		cells = append(cells, wbs)
+
+		lateStart := row.AddCell()
+		lateStart.SetDateTime(activity.LateStart)
+		cells = append(cells, lateStart)
+
+		lateFinish := row.AddCell()
+		lateFinish.SetDateTime(activity.LateFinish)
+		cells = append(cells, lateFinish)
+
+		totalFloat := row.AddCell()
+		totalFloat.SetString(activity.TotalFloat.String())
+		cells = append(cells, totalFloat)
+
+		freeFloat := row.AddCell()
+		freeFloat.SetString(activity.FreeFloat.String())
+		cells = append(cells, freeFloat)
+
+		constraintType := row.AddCell()
+		constraintType.SetString(activity.ConstraintType.String())
+		cells = append(cells, constraintType)
+
+		constraintDate := row.AddCell()
+		constraintDate.SetDateTime(activity.ConstraintDate)
+		cells = append(cells, constraintDate)
+
+		code := row.AddCell()
+		code.SetString(activity.Code)
+		cells = append(cells, code)
+
 		for _, c := range cells {
 			row.PushCell(c)
 		}
 	}
 }
 
-// XLSXToActivities converts activities in xlsx format to a slice of activities.
+// XLSXToActivities converts activities in xlsx format to a slice of activities, with the default column mapping.
 func XLSXToActivities(sheet *xlsx.Sheet) (activities []*activity.Activity, err error) {
+	activities, _, _, err = XLSXToActivitiesWithMapping(sheet, tabular.DefaultMapping())
+	return
+}
+
+// XLSXToActivitiesWithMapping converts activities in xlsx format to a slice of activities, mapping the columns
+// to the fields of the activities from the header with the 'mapping'. The values of the unknown columns captured
+// by the mapping are returned in 'unknown', at the index of their activity. The invalid values of all the rows
+// are listed in 'report', and the invalid rows are either skipped or make the import fail, depending on the mode
+// of the mapping.
+func XLSXToActivitiesWithMapping(sheet *xlsx.Sheet, mapping *tabular.Mapping) (activities []*activity.Activity, unknown []map[string]string, report *tabular.Report, err error) {
+	report = &tabular.Report{}
+	var layout *tabular.Layout
 	for i := 0; i < sheet.MaxRow; i++ {
 		row, err := sheet.Row(i)
 		if err != nil {
-			return activities, err
+			return activities, unknown, report, err
 		}
 
-		if row.GetCell(0).String() == "Id" {
+		record := make([]string, sheet.MaxCol)
+		for j := range record {
+			record[j] = row.GetCell(j).String()
+		}
+		if layout == nil {
+			if layout, err = mapping.Layout(record); err != nil {
+				return activities, unknown, report, err
+			}
+			if layout.HasHeader() {
+				continue
+			}
+		}
+
+		report.Rows++
+		act, errs := rowToActivity(row, record, i+1, layout, mapping)
+		if len(errs) > 0 {
+			report.Errors = append(report.Errors, errs...)
 			continue
 		}
-		act := &activity.Activity{}
+		activities = append(activities, act)
+		unknown = append(unknown, layout.Unknown(record))
+	}
 
-		id, err := row.GetCell(0).Int()
+	if err = report.Err(); err != nil && mapping.Mode == tabular.Strict {
+		return nil, nil, report, err
+	}
+	return activities, unknown, report, nil
+}
+
+// rowToActivity converts a row, the row number 'number' of the sheet whose cells are formatted in 'record',
+// to an Activity pointer, with the columns of 'layout' and the default values of 'mapping'.
+// It returns the errors of all the invalid values of the row.
+func rowToActivity(row *xlsx.Row, record []string, number int, layout *tabular.Layout, mapping *tabular.Mapping) (act *activity.Activity, errs []tabular.RowError) {
+	act = mapping.NewActivity()
+	for _, f := range tabular.Fields() {
+		value, ok, err := layout.Value(record, f)
+		if err == nil && ok {
+			column, _ := layout.Column(f)
+			err = setField(act, f, row.GetCell(column), value)
+		}
 		if err != nil {
-			return activities, err
+			errs = append(errs, layout.RowError(number, record, f, err))
 		}
+	}
+	if act.PredecessorsId == nil {
+		act.PredecessorsId = []int{}
+	}
+	if act.SuccessorsId == nil {
+		act.SuccessorsId = []int{}
+	}
+	return
+}
 
-		description := row.GetCell(1).String()
+// setField sets the field 'f' of the activity 'act' from the 'cell', whose formatted value is 'value'.
+func setField(act *activity.Activity, f tabular.Field, cell *xlsx.Cell, value string) (err error) {
+	switch f {
+	case tabular.Id:
+		act.Id, err = cell.Int()
+	case tabular.Description:
+		act.Description = value
+	case tabular.Duration:
+		act.Duration, err = time.ParseDuration(value)
+	case tabular.Start:
+		act.Start, err = cellTime(cell, value)
+	case tabular.Finish:
+		act.Finish, err = cellTime(cell, value)
+	case tabular.PredecessorsId:
+		act.PredecessorsId, err = util.Unflat(value)
+	case tabular.SuccessorsId:
+		act.SuccessorsId, err = util.Unflat(value)
+	case tabular.Cost:
+		act.Cost, err = cell.Float()
+	case tabular.Relationships:
+		act.Relationships, err = util.UnflatRelationships(value)
+	case tabular.CalendarId:
+		act.CalendarId, err = cell.Int()
+	case tabular.Progress:
+		var progress float64
+		progress, err = cell.Float()
+		act.Progress = float32(progress)
+	case tabular.ActualStart:
+		act.ActualStart, err = cellTime(cell, value)
+	case tabular.ActualFinish:
+		act.ActualFinish, err = cellTime(cell, value)
+	case tabular.Wbs:
+		act.Wbs = value
+	case tabular.LateStart:
+		act.LateStart, err = cellTime(cell, value)
+	case tabular.LateFinish:
+		act.LateFinish, err = cellTime(cell, value)
+	case tabular.TotalFloat:
+		act.TotalFloat, err = time.ParseDuration(value)
+	case tabular.FreeFloat:
+		act.FreeFloat, err = time.ParseDuration(value)
+	case tabular.ConstraintType:
+		act.ConstraintType, err = activity.ParseConstraintType(value)
+	case tabular.ConstraintDate:
+		act.ConstraintDate, err = cellTime(cell, value)
+	case tabular.Code:
+		act.Code = value
+	}
+	return
+}
+
+// cellTime returns the date of the 'cell', whose formatted value is 'value', a value of "0" being the zero time.
+// The date is rounded to the second, as excel stores dates as floating point days.
+func cellTime(cell *xlsx.Cell, value string) (t time.Time, err error) {
+	if value == "0" {
+		return
+	}
+	if t, err = cell.GetTime(false); err != nil {
+		return
+	}
+	return t.Round(time.Second), nil
+}
+
+// ExportCalendarsToDb populates the database with calendars in xlsx format.
+func ExportCalendarsToDb(sqldb *db.DB, sheet *xlsx.Sheet, duplicateInsertPolicy db.DuplicateInsertPolicy) (err error) {
+	calendars, err := XLSXToCalendars(sheet)
+	if err != nil {
+		return
+	}
+	return sqldb.InsertCalendars(calendars, duplicateInsertPolicy)
+}
+
+// CalendarsToXLSX converts a slice of calendars to xlsx format.
+func CalendarsToXLSX(calendars []*calendar.Calendar, sheet *xlsx.Sheet) {
+	row := sheet.AddRow()
+	for _, h := range calendarTableHeader {
+		c := row.AddCell()
+		c.SetString(h)
+	}
+
+	for _, c := range calendars {
+		row = sheet.AddRow()
+		row.AddCell().SetInt(c.Id)
+		row.AddCell().SetString(c.Name)
+		row.AddCell().SetBool(c.Default)
+		row.AddCell().SetString(calendar.FormatWorkDays(c.WorkDays))
+		row.AddCell().SetString(calendar.FormatShifts(c.Shifts))
+		row.AddCell().SetString(calendar.FormatHolidays(c.Holidays))
+	}
+}
 
-		duration, err := time.ParseDuration(row.GetCell(2).String())
+// XLSXToCalendars converts calendars in xlsx format to a slice of calendars.
+func XLSXToCalendars(sheet *xlsx.Sheet) (calendars []*calendar.Calendar, err error) {
+	for i := 0; i < sheet.MaxRow; i++ {
+		row, err := sheet.Row(i)
 		if err != nil {
-			return activities, err
+			return calendars, err
 		}
 
-		start := row.GetCell(3)
-		if start.String() == "" || start.String() == "0" {
-			act.Start = time.Time{}
-		} else {
-			startTime, err := start.GetTime(false)
-			if err != nil {
-				return activities, err
-			}
-			act.Start = startTime
+		if row.GetCell(0).String() == "Id" {
+			continue
 		}
+		c := &calendar.Calendar{}
 
-		finish := row.GetCell(4)
-		if finish.String() == "" || finish.String() == "0" {
-			act.Finish = time.Time{}
-		} else {
-			finishTime, err := finish.GetTime(false)
-			if err != nil {
-				return activities, err
-			}
-			act.Finish = finishTime
+		c.Id, err = row.GetCell(0).Int()
+		if err != nil {
+			return calendars, err
 		}
 
-		predecessorsId, err := util.Unflat(row.GetCell(5).String())
+		c.Name = row.GetCell(1).String()
+		c.Default = row.GetCell(2).Bool()
+
+		c.WorkDays, err = calendar.ParseWorkDays(row.GetCell(3).String())
 		if err != nil {
-			return activities, err
+			return calendars, err
 		}
 
-		successorsId, err := util.Unflat(row.GetCell(6).String())
+		c.Shifts, err = calendar.ParseShifts(row.GetCell(4).String())
 		if err != nil {
-			return activities, err
+			return calendars, err
 		}
 
-		cost := row.GetCell(7)
-		if cost.String() == "" {
-			act.Cost = 0
-		} else {
-			costFloat, err := cost.Float()
-			if err != nil {
-				return activities, err
-			}
-			act.Cost = costFloat
+		c.Holidays, err = calendar.ParseHolidays(row.GetCell(5).String())
+		if err != nil {
+			return calendars, err
 		}
 
-		act.Id = id
-		act.Description = description
-		act.Duration = duration
-		act.PredecessorsId = predecessorsId
-		act.SuccessorsId = successorsId
-
-		activities = append(activities, act)
+		calendars = append(calendars, c)
 	}
 
 	return
@@ -2,21 +2,27 @@ package pxlsx
 
 import (
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/tealeg/xlsx/v3"
 	"github.com/vanillaiice/verano/activity"
 	"github.com/vanillaiice/verano/db"
+	"github.com/vanillaiice/verano/internal/activitytest"
+	"github.com/vanillaiice/verano/parser/tabular"
+	"github.com/vanillaiice/verano/project/calendar"
 )
 
 var d1 = time.Minute * 10
 var d2 = time.Minute * 30
 var d3 = time.Minute * 20
 var tt = time.Time{}
+var t1 = time.Date(2024, time.January, 5, 8, 0, 0, 0, time.UTC)
+var t2 = time.Date(2024, time.January, 5, 8, 30, 0, 0, time.UTC)
 var activities = []*activity.Activity{
-	{Id: 3, Description: "Cook eggs", Duration: d1, PredecessorsId: []int{2}, SuccessorsId: []int{1}, Start: tt, Finish: tt, Cost: 0},
-	{Id: 2, Description: "Buy eggs", Duration: d2, PredecessorsId: []int{}, SuccessorsId: []int{3}, Start: tt, Finish: tt, Cost: 100},
+	{Id: 3, Description: "Cook eggs", Wbs: "1.2", Duration: d1, PredecessorsId: []int{2}, SuccessorsId: []int{1}, Relationships: map[int]activity.Relationship{2: {Type: activity.FinishToFinish, Lag: -5 * time.Minute}}, CalendarId: 1, Start: tt, Finish: tt, Progress: 0.5, ActualStart: t2, Cost: 0},
+	{Id: 2, Description: "Buy eggs", Duration: d2, PredecessorsId: []int{}, SuccessorsId: []int{3}, Start: tt, Finish: tt, Progress: 1, ActualStart: t1, ActualFinish: t2, Cost: 100},
 	{Id: 1, Description: "Eat eggs", Duration: d3, PredecessorsId: []int{3}, SuccessorsId: []int{}, Start: tt, Finish: tt, Cost: 0},
 }
 
@@ -47,6 +53,21 @@ func TestXLSXtoActivities(t *testing.T) {
 		if acts[i].Id != activities[i].Id {
 			t.Errorf("got %+v, want %+v", acts[i], activities[i])
 		}
+		if acts[i].Relationship(2) != activities[i].Relationship(2) {
+			t.Errorf("relationship: got %v, want %v", acts[i].Relationship(2), activities[i].Relationship(2))
+		}
+		if acts[i].CalendarId != activities[i].CalendarId {
+			t.Errorf("calendar: got %d, want %d", acts[i].CalendarId, activities[i].CalendarId)
+		}
+		if acts[i].Wbs != activities[i].Wbs {
+			t.Errorf("wbs: got %q, want %q", acts[i].Wbs, activities[i].Wbs)
+		}
+		if acts[i].Progress != activities[i].Progress {
+			t.Errorf("progress: got %v, want %v", acts[i].Progress, activities[i].Progress)
+		}
+		if !acts[i].ActualStart.Equal(activities[i].ActualStart) || !acts[i].ActualFinish.Equal(activities[i].ActualFinish) {
+			t.Errorf("actual dates: got %v and %v, want %v and %v", acts[i].ActualStart, acts[i].ActualFinish, activities[i].ActualStart, activities[i].ActualFinish)
+		}
 	}
 }
 
@@ -77,3 +98,132 @@ func TestExportToDb(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestXLSXToActivitiesWithMapping(t *testing.T) {
+	wb := xlsx.NewFile()
+	sheet, err := wb.AddSheet("reordered")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sheet.Close()
+	for _, record := range [][]string{
+		{"Notes", "Duration", "Id", "Description", "Progress"},
+		{"urgent", "1h0m0s", "1", "Buy eggs", "0.5"},
+		{"", "", "2", "Cook eggs", ""},
+	} {
+		row := sheet.AddRow()
+		for _, value := range record {
+			row.AddCell().SetString(value)
+		}
+	}
+
+	mapping := &tabular.Mapping{
+		Required: []tabular.Field{tabular.Id},
+		Defaults: &activity.Activity{Duration: 30 * time.Minute},
+		Unknown:  tabular.CaptureUnknown,
+	}
+	acts, unknown, _, err := XLSXToActivitiesWithMapping(sheet, mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*activity.Activity{
+		{Id: 1, Description: "Buy eggs", Duration: time.Hour, Progress: 0.5, PredecessorsId: []int{}, SuccessorsId: []int{}},
+		{Id: 2, Description: "Cook eggs", Duration: 30 * time.Minute, PredecessorsId: []int{}, SuccessorsId: []int{}},
+	}
+	if !reflect.DeepEqual(acts, want) {
+		t.Errorf("got %+v, want %+v", acts, want)
+	}
+	wantUnknown := []map[string]string{{"Notes": "urgent"}, {"Notes": ""}}
+	if !reflect.DeepEqual(unknown, wantUnknown) {
+		t.Errorf("got %v, want %v", unknown, wantUnknown)
+	}
+}
+
+func TestXLSXToActivitiesReport(t *testing.T) {
+	wb := xlsx.NewFile()
+	sheet, err := wb.AddSheet("invalid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sheet.Close()
+	for _, record := range [][]string{
+		{"Id", "Duration", "Progress"},
+		{"1", "30m", "0.5"},
+		{"2", "10 minutes", "half"},
+		{"3", "20m", "0"},
+	} {
+		row := sheet.AddRow()
+		for _, value := range record {
+			row.AddCell().SetString(value)
+		}
+	}
+
+	mapping := &tabular.Mapping{Required: []tabular.Field{tabular.Id, tabular.Duration}, Mode: tabular.BestEffort}
+	acts, _, report, err := XLSXToActivitiesWithMapping(sheet, mapping)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(acts) != 2 || acts[0].Id != 1 || acts[1].Id != 3 {
+		t.Errorf("got %+v, want activities 1 and 3", acts)
+	}
+	want := []tabular.RowError{{Row: 3, Column: "Duration", Value: "10 minutes"}, {Row: 3, Column: "Progress", Value: "half"}}
+	if len(report.Errors) != len(want) {
+		t.Fatalf("got %d errors, want %d: %v", len(report.Errors), len(want), report.Errors)
+	}
+	for i, e := range report.Errors {
+		if e.Row != want[i].Row || e.Column != want[i].Column || e.Value != want[i].Value || e.Err == nil {
+			t.Errorf("got %+v, want %+v", e, want[i])
+		}
+	}
+
+	mapping.Mode = tabular.Strict
+	if acts, _, _, err = XLSXToActivitiesWithMapping(sheet, mapping); err == nil || acts != nil {
+		t.Errorf("got %d activities and error %v in strict mode, want none and an error", len(acts), err)
+	}
+}
+
+func TestRoundTripAllFields(t *testing.T) {
+	wb := xlsx.NewFile()
+	sheet, err := wb.AddSheet("full")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sheet.Close()
+
+	want := activitytest.Full()
+	ActivitiesToXLSX([]*activity.Activity{want}, sheet)
+	got, err := XLSXToActivities(sheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d activities, want 1", len(got))
+	}
+	activitytest.Check(t, got[0], want)
+}
+
+func TestCalendarsXLSX(t *testing.T) {
+	standard := calendar.New(1, "standard")
+	standard.Default = true
+	standard.Holidays = []time.Time{time.Date(2024, time.December, 25, 0, 0, 0, 0, time.UTC)}
+
+	wb := xlsx.NewFile()
+	sheet, err := wb.AddSheet("calendars")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sheet.Close()
+	CalendarsToXLSX([]*calendar.Calendar{standard}, sheet)
+
+	calendars, err := XLSXToCalendars(sheet)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(calendars) != 1 {
+		t.Fatalf("got %d calendars, want %d", len(calendars), 1)
+	}
+	c := calendars[0]
+	if c.Id != standard.Id || c.Name != standard.Name || !c.Default || len(c.WorkDays) != 5 || len(c.Shifts) != 2 || !c.Holidays[0].Equal(standard.Holidays[0]) {
+		t.Errorf("got %+v, want %+v", c, standard)
+	}
+}
@@ -0,0 +1,62 @@
+package tabular
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorMode defines what happens to the rows of a table holding invalid values.
+type ErrorMode int
+
+const (
+	Strict     ErrorMode = 0 // The import fails if a row is invalid, after every row has been checked
+	BestEffort ErrorMode = 1 // The invalid rows are skipped, and the valid rows are imported
+)
+
+// RowError is an invalid value in a row of a table.
+type RowError struct {
+	Row    int    // Number of the row in the file, starting at 1 with the header
+	Column string // Header of the column, or name of the field if the table has no header
+	Value  string // Raw value of the cell
+	Err    error  // Reason why the value is invalid
+}
+
+// Error returns the error in a human readable form (e.g. `row 12, column Duration: invalid value "1x": ...`).
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %d, column %s: invalid value %q: %v", e.Row, e.Column, e.Value, e.Err)
+}
+
+// Unwrap returns the reason why the value is invalid.
+func (e RowError) Unwrap() error {
+	return e.Err
+}
+
+// Report lists the invalid values found while importing a table.
+type Report struct {
+	Rows   int        // Number of rows read, the header excluded
+	Errors []RowError // Invalid values, by row and column
+}
+
+// Invalid returns the number of invalid rows.
+func (r *Report) Invalid() (n int) {
+	row := 0
+	for _, e := range r.Errors {
+		if e.Row != row {
+			row = e.Row
+			n++
+		}
+	}
+	return
+}
+
+// Err returns an error listing all the invalid values, or nil if there is none.
+func (r *Report) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	errs := make([]error, len(r.Errors))
+	for i, e := range r.Errors {
+		errs[i] = e
+	}
+	return fmt.Errorf("%d of %d rows are invalid:\n%w", r.Invalid(), r.Rows, errors.Join(errs...))
+}
@@ -0,0 +1,40 @@
+package tabular
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	r := &Report{Rows: 4}
+	if err := r.Err(); err != nil {
+		t.Errorf("got %v, want <nil>", err)
+	}
+
+	r.Errors = []RowError{
+		{Row: 2, Column: "Id", Value: "x", Err: strconv.ErrSyntax},
+		{Row: 2, Column: "Duration", Value: "", Err: ErrMissingValue},
+		{Row: 4, Column: "Cost", Value: "1e999", Err: strconv.ErrRange},
+	}
+	if got := r.Invalid(); got != 2 {
+		t.Errorf("got %d, want %d", got, 2)
+	}
+	err := r.Err()
+	if err == nil {
+		t.Fatal("got no error, want an error")
+	}
+	if !errors.Is(err, ErrMissingValue) || !errors.Is(err, strconv.ErrRange) {
+		t.Errorf("got %v, want it to wrap the reasons of the row errors", err)
+	}
+	for _, want := range []string{"2 of 4 rows are invalid", `row 2, column Id: invalid value "x": invalid syntax`, "row 4, column Cost"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("got %q, want it to contain %q", err, want)
+		}
+	}
+	var rowErr RowError
+	if !errors.As(err, &rowErr) || rowErr.Row != 2 {
+		t.Errorf("got %+v, want the first row error", rowErr)
+	}
+}
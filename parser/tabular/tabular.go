@@ -0,0 +1,250 @@
+// Package tabular maps the columns of tables of activities (CSV files, XLSX sheets) to the fields of activities,
+// from the header of the tables rather than from the position of the columns.
+package tabular
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/vanillaiice/verano/activity"
+)
+
+// Field is a field of an activity that can be read from a column.
+type Field int
+
+// Fields of activities, in the order of the columns written by the pcsv and pxlsx parsers.
+const (
+	Id             Field = 0
+	Description    Field = 1
+	Duration       Field = 2
+	Start          Field = 3
+	Finish         Field = 4
+	PredecessorsId Field = 5
+	SuccessorsId   Field = 6
+	Cost           Field = 7
+	Relationships  Field = 8
+	CalendarId     Field = 9
+	Progress       Field = 10
+	ActualStart    Field = 11
+	ActualFinish   Field = 12
+	Wbs            Field = 13
+	LateStart      Field = 14
+	LateFinish     Field = 15
+	TotalFloat     Field = 16
+	FreeFloat      Field = 17
+	ConstraintType Field = 18
+	ConstraintDate Field = 19
+	Code           Field = 20
+)
+
+var fieldNames = []string{"Id", "Description", "Duration", "Start", "Finish", "PredecessorsId", "SuccessorsId", "Cost", "Relationships", "CalendarId", "Progress", "ActualStart", "ActualFinish", "Wbs", "LateStart", "LateFinish", "TotalFloat", "FreeFloat", "ConstraintType", "ConstraintDate", "Code"}
+
+// String returns the name of the field, which is also its header in the files written by the pcsv and pxlsx parsers.
+func (f Field) String() string {
+	if f < 0 || int(f) >= len(fieldNames) {
+		return fmt.Sprintf("Field(%d)", int(f))
+	}
+	return fieldNames[f]
+}
+
+// Fields returns all the fields, in the order of the columns written by the pcsv and pxlsx parsers.
+func Fields() (fields []Field) {
+	for i := range fieldNames {
+		fields = append(fields, Field(i))
+	}
+	return
+}
+
+// Header returns the names of all the fields, in the order of the columns written by the pcsv and pxlsx parsers.
+func Header() []string {
+	return slices.Clone(fieldNames)
+}
+
+// UnknownPolicy defines what happens to the columns whose header is not mapped to a field.
+type UnknownPolicy int
+
+const (
+	IgnoreUnknown  UnknownPolicy = 0 // The columns are ignored
+	CaptureUnknown UnknownPolicy = 1 // The values of the columns are returned along with the activities, by header
+)
+
+// FrenchHeaders maps the French headers of the fields to the fields, to be used as the 'Headers' of a Mapping.
+var FrenchHeaders = map[string]Field{
+	"Identifiant":        Id,
+	"Libellé":            Description,
+	"Durée":              Duration,
+	"Début":              Start,
+	"Fin":                Finish,
+	"Prédécesseurs":      PredecessorsId,
+	"Successeurs":        SuccessorsId,
+	"Coût":               Cost,
+	"Relations":          Relationships,
+	"Calendrier":         CalendarId,
+	"Avancement":         Progress,
+	"Début réel":         ActualStart,
+	"Fin réelle":         ActualFinish,
+	"WBS":                Wbs,
+	"Début au plus tard": LateStart,
+	"Fin au plus tard":   LateFinish,
+	"Marge totale":       TotalFloat,
+	"Marge libre":        FreeFloat,
+	"Type de contrainte": ConstraintType,
+	"Date de contrainte": ConstraintDate,
+	"Code":               Code,
+}
+
+// ErrMissingValue is the error of an empty cell in a required column.
+var ErrMissingValue = errors.New("missing value")
+
+// Mapping configures how the columns of a table are mapped to the fields of activities.
+// The headers are matched regardless of case and surrounding spaces, and the name of a field
+// (e.g. "Duration") is always recognized as its header.
+type Mapping struct {
+	Headers  map[string]Field   // Other headers of the fields (e.g. "Durée" for Duration)
+	Required []Field            // Fields whose column must be present, and whose cells must not be empty
+	Defaults *activity.Activity // Values of the fields whose column is missing or whose cell is empty, zero values if nil
+	Unknown  UnknownPolicy      // What happens to the columns that are not mapped to a field
+	Mode     ErrorMode          // What happens to the rows holding invalid values
+}
+
+// DefaultMapping returns the mapping used when none is provided: the columns are identified by the names
+// of the fields, the id and duration columns are required, the unknown columns are ignored, and the import
+// fails if a row is invalid.
+func DefaultMapping() *Mapping {
+	return &Mapping{Required: []Field{Id, Duration}}
+}
+
+// NewActivity returns a new activity holding the default values of the mapping.
+func (m *Mapping) NewActivity() *activity.Activity {
+	if m.Defaults == nil {
+		return &activity.Activity{}
+	}
+	a := *m.Defaults
+	a.PredecessorsId = slices.Clone(a.PredecessorsId)
+	a.SuccessorsId = slices.Clone(a.SuccessorsId)
+	if a.Relationships != nil {
+		a.Relationships = make(map[int]activity.Relationship)
+		for id, rel := range m.Defaults.Relationships {
+			a.Relationships[id] = rel
+		}
+	}
+	return &a
+}
+
+// Layout is the position of the columns of the fields in a table.
+type Layout struct {
+	mapping   *Mapping
+	header    []string
+	hasHeader bool
+	columns   map[Field]int
+	unknown   map[int]string
+}
+
+// Layout resolves the columns of a table from its first row 'header'. If none of the cells of 'header'
+// is a header of the Id field, the table is considered to have no header, and its columns are expected
+// in the order of the columns written by the pcsv and pxlsx parsers.
+// It returns an error if two columns are mapped to the same field, or if a required column is missing.
+func (m *Mapping) Layout(header []string) (l *Layout, err error) {
+	names := make(map[string]Field)
+	for i, name := range fieldNames {
+		names[normalize(name)] = Field(i)
+	}
+	for name, f := range m.Headers {
+		names[normalize(name)] = f
+	}
+
+	l = &Layout{mapping: m, columns: make(map[Field]int), unknown: make(map[int]string)}
+	for i, h := range header {
+		f, ok := names[normalize(h)]
+		if !ok {
+			if strings.TrimSpace(h) != "" {
+				l.unknown[i] = strings.TrimSpace(h)
+			}
+			continue
+		}
+		if j, ok := l.columns[f]; ok {
+			return nil, fmt.Errorf("columns %d and %d are both mapped to %s", j+1, i+1, f)
+		}
+		l.columns[f] = i
+	}
+
+	if _, ok := l.columns[Id]; !ok {
+		l.columns = make(map[Field]int)
+		for _, f := range Fields() {
+			l.columns[f] = int(f)
+		}
+		l.unknown = make(map[int]string)
+		return l, nil
+	}
+
+	l.header = header
+	l.hasHeader = true
+	for _, f := range m.Required {
+		if _, ok := l.columns[f]; !ok {
+			return nil, fmt.Errorf("missing required column %s", f)
+		}
+	}
+	return
+}
+
+// HasHeader reports whether the first row of the table is a header.
+func (l *Layout) HasHeader() bool {
+	return l.hasHeader
+}
+
+// Column returns the index of the column of the field 'f', and whether the table has such a column.
+func (l *Layout) Column(f Field) (index int, ok bool) {
+	index, ok = l.columns[f]
+	return
+}
+
+// Value returns the value of the field 'f' in 'record', and whether the value is present,
+// that is whether the table has a column for the field and the cell of the record is not empty.
+// It returns ErrMissingValue if the value of a required field is missing.
+func (l *Layout) Value(record []string, f Field) (value string, ok bool, err error) {
+	if i, found := l.columns[f]; found && i < len(record) {
+		value = strings.TrimSpace(record[i])
+	}
+	if value == "" && slices.Contains(l.mapping.Required, f) {
+		return "", false, ErrMissingValue
+	}
+	return value, value != "", nil
+}
+
+// RowError returns the error 'err' of the value of the field 'f' in 'record', the row number 'row' of the table.
+func (l *Layout) RowError(row int, record []string, f Field, err error) RowError {
+	e := RowError{Row: row, Column: f.String(), Err: err}
+	if i, ok := l.columns[f]; ok {
+		if l.hasHeader {
+			e.Column = strings.TrimSpace(l.header[i])
+		}
+		if i < len(record) {
+			e.Value = record[i]
+		}
+	}
+	return e
+}
+
+// Unknown returns the values of the columns of 'record' that are not mapped to a field, by header,
+// or nil if the mapping ignores them.
+func (l *Layout) Unknown(record []string) (values map[string]string) {
+	if l.mapping.Unknown != CaptureUnknown || len(l.unknown) == 0 {
+		return
+	}
+	values = make(map[string]string)
+	for i, h := range l.unknown {
+		if i < len(record) {
+			values[h] = record[i]
+		} else {
+			values[h] = ""
+		}
+	}
+	return
+}
+
+// normalize returns the header 'h' in lower case and without surrounding spaces.
+func normalize(h string) string {
+	return strings.ToLower(strings.TrimSpace(h))
+}
@@ -0,0 +1,119 @@
+package tabular
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vanillaiice/verano/activity"
+)
+
+func TestLayout(t *testing.T) {
+	m := DefaultMapping()
+	l, err := m.Layout([]string{"Duration", " id ", "Notes", "Description"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.HasHeader() {
+		t.Error("got no header, want a header")
+	}
+	for f, want := range map[Field]int{Id: 1, Duration: 0, Description: 3} {
+		if got, ok := l.Column(f); !ok || got != want {
+			t.Errorf("%s: got column %d, want %d", f, got, want)
+		}
+	}
+	if _, ok := l.Column(Start); ok {
+		t.Error("got a start column, want none")
+	}
+	if got := l.Unknown([]string{"1h", "1", "note", ""}); got != nil {
+		t.Errorf("got %v, want no unknown values", got)
+	}
+}
+
+func TestLayoutWithoutHeader(t *testing.T) {
+	l, err := DefaultMapping().Layout([]string{"1", "Buy eggs", "30m0s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.HasHeader() {
+		t.Error("got a header, want none")
+	}
+	for _, f := range Fields() {
+		if got, ok := l.Column(f); !ok || got != int(f) {
+			t.Errorf("%s: got column %d, want %d", f, got, int(f))
+		}
+	}
+}
+
+func TestLayoutErrors(t *testing.T) {
+	m := &Mapping{Headers: FrenchHeaders, Required: []Field{Id, Duration}}
+	for _, header := range [][]string{
+		{"Id", "Identifiant", "Duration"},
+		{"Id", "Description"},
+	} {
+		if _, err := m.Layout(header); err == nil {
+			t.Errorf("got no error for %v, want an error", header)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	m := &Mapping{Headers: FrenchHeaders, Required: []Field{Id, Duration}, Unknown: CaptureUnknown}
+	l, err := m.Layout([]string{"Durée", "Identifiant", "Remarque", "Libellé"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, ok, err := l.Value([]string{"1h", "2", "urgent", " Cook eggs "}, Description)
+	if err != nil || !ok || value != "Cook eggs" {
+		t.Errorf("got %q, %v, %v, want %q, true, <nil>", value, ok, err, "Cook eggs")
+	}
+	if _, ok, err = l.Value([]string{"1h", "2", "urgent", ""}, Description); err != nil || ok {
+		t.Errorf("got %v, %v, want false, <nil>", ok, err)
+	}
+	if _, ok, err = l.Value([]string{"1h", "2"}, Wbs); err != nil || ok {
+		t.Errorf("got %v, %v, want false, <nil>", ok, err)
+	}
+	if _, _, err = l.Value([]string{"", "2"}, Duration); err != ErrMissingValue {
+		t.Errorf("got %v, want %v", err, ErrMissingValue)
+	}
+
+	e := l.RowError(3, []string{"1x", "2"}, Duration, ErrMissingValue)
+	if e.Row != 3 || e.Column != "Durée" || e.Value != "1x" {
+		t.Errorf("got %+v, want the row, header and value of the cell", e)
+	}
+
+	got := l.Unknown([]string{"1h", "2", "urgent", "Cook eggs"})
+	want := map[string]string{"Remarque": "urgent"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNewActivity(t *testing.T) {
+	m := &Mapping{Defaults: &activity.Activity{Duration: time.Hour, CalendarId: 2, PredecessorsId: []int{1}}}
+	a := m.NewActivity()
+	if a.Duration != time.Hour || a.CalendarId != 2 {
+		t.Errorf("got %+v, want %+v", a, m.Defaults)
+	}
+	a.PredecessorsId[0] = 3
+	if m.Defaults.PredecessorsId[0] != 1 {
+		t.Errorf("got %v, want the defaults to be left unchanged", m.Defaults.PredecessorsId)
+	}
+
+	if a = DefaultMapping().NewActivity(); !reflect.DeepEqual(a, &activity.Activity{}) {
+		t.Errorf("got %+v, want %+v", a, &activity.Activity{})
+	}
+}
+
+func TestFieldString(t *testing.T) {
+	if got := Header(); !reflect.DeepEqual(got, fieldNames) {
+		t.Errorf("got %v, want %v", got, fieldNames)
+	}
+	if got := Wbs.String(); got != "Wbs" {
+		t.Errorf("got %q, want %q", got, "Wbs")
+	}
+	if got := Field(21).String(); got != "Field(21)" {
+		t.Errorf("got %q, want %q", got, "Field(21)")
+	}
+}
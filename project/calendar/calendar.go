@@ -0,0 +1,387 @@
+package calendar
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Layout of the holidays when formatting and parsing them.
+const dateFormat = "2006-01-02"
+
+// Maximum number of days searched for working time before giving up.
+const maxSearchDays = 3660
+
+// Shift is a period of working time within a day, defined by offsets from midnight.
+type Shift struct {
+	Start  time.Duration `json:"start"`  // Offset from midnight at which the shift starts
+	Finish time.Duration `json:"finish"` // Offset from midnight at which the shift finishes
+}
+
+// Calendar defines the working time used to schedule activities,
+// with the days worked in a week, the shifts worked in a day, and holidays.
+// A nil calendar is continuous, that is every moment is working time.
+type Calendar struct {
+	Id       int            `json:"id"`       // Unique identifier of the calendar
+	Name     string         `json:"name"`     // Name of the calendar
+	Default  bool           `json:"default"`  // Whether the calendar is used by activities without calendar
+	WorkDays []time.Weekday `json:"workDays"` // Days of the week with working time
+	Shifts   []Shift        `json:"shifts"`   // Working time of a work day, sorted and not overlapping
+	Holidays []time.Time    `json:"holidays"` // Days without working time (only the date is considered)
+}
+
+// New creates a calendar with the given 'id' and 'name', with a five day work week
+// from Monday to Friday, and eight hours of work per day (8:00 to 12:00 and 13:00 to 17:00).
+func New(id int, name string) *Calendar {
+	return &Calendar{
+		Id:       id,
+		Name:     name,
+		WorkDays: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		Shifts: []Shift{
+			{Start: 8 * time.Hour, Finish: 12 * time.Hour},
+			{Start: 13 * time.Hour, Finish: 17 * time.Hour},
+		},
+	}
+}
+
+// Default returns the calendar marked as default in the provided 'calendars',
+// or nil if there is none.
+func Default(calendars []*Calendar) *Calendar {
+	for _, c := range calendars {
+		if c.Default {
+			return c
+		}
+	}
+	return nil
+}
+
+// ToMap converts a slice of 'calendars' into a map with calendar ids as keys
+// and pointers to calendars as values.
+func ToMap(calendars []*Calendar) (calendarsMap map[int]*Calendar) {
+	calendarsMap = make(map[int]*Calendar)
+	for _, c := range calendars {
+		calendarsMap[c.Id] = c
+	}
+	return
+}
+
+// Validate returns an error if the calendar has no working time,
+// or if its shifts are out of the day, unsorted or overlapping.
+func (c *Calendar) Validate() error {
+	if len(c.WorkDays) == 0 {
+		return errors.New("calendar has no work days")
+	}
+	if len(c.Shifts) == 0 {
+		return errors.New("calendar has no shifts")
+	}
+	var previousFinish time.Duration
+	for _, s := range c.Shifts {
+		if s.Start < previousFinish || s.Finish <= s.Start || s.Finish > 24*time.Hour {
+			return fmt.Errorf("invalid shift %s", FormatShifts([]Shift{s}))
+		}
+		previousFinish = s.Finish
+	}
+	return nil
+}
+
+// AddHoliday adds the day of 't' to the calendar's holidays.
+// It returns an error if the day is already a holiday.
+func (c *Calendar) AddHoliday(t time.Time) (err error) {
+	if c.isHoliday(t) {
+		return fmt.Errorf("%s is already a holiday", t.Format(dateFormat))
+	}
+	c.Holidays = append(c.Holidays, t)
+	return
+}
+
+// IsWorkDay reports whether the day of 't' has working time.
+func (c *Calendar) IsWorkDay(t time.Time) bool {
+	if c == nil {
+		return true
+	}
+	return slices.Index(c.WorkDays, t.Weekday()) != -1 && !c.isHoliday(t)
+}
+
+// NextWorkingTime returns 't' if it is working time,
+// or the start of the next period of working time otherwise.
+func (c *Calendar) NextWorkingTime(t time.Time) time.Time {
+	if !c.hasWorkingTime() {
+		return t
+	}
+	day := midnight(t)
+	for i := 0; i < maxSearchDays; i++ {
+		for _, s := range c.spans(day) {
+			if t.Before(s.finish) {
+				if t.Before(s.start) {
+					return s.start
+				}
+				return t
+			}
+		}
+		day = nextDay(day)
+	}
+	return t
+}
+
+// PreviousWorkingTime returns 't' if it is working time or the end of a period of working time,
+// or the end of the previous period of working time otherwise.
+func (c *Calendar) PreviousWorkingTime(t time.Time) time.Time {
+	if !c.hasWorkingTime() {
+		return t
+	}
+	day := midnight(t)
+	if day.Equal(t) {
+		day = previousDay(day)
+	}
+	for i := 0; i < maxSearchDays; i++ {
+		spans := c.spans(day)
+		for j := len(spans) - 1; j >= 0; j-- {
+			if t.After(spans[j].start) {
+				if t.After(spans[j].finish) {
+					return spans[j].finish
+				}
+				return t
+			}
+		}
+		day = previousDay(day)
+	}
+	return t
+}
+
+// Add returns the time after 'd' of working time from 't', or before it if 'd' is negative.
+// When moving forward, the result can be the end of a period of working time,
+// and when moving backward, it can be the start of a period of working time.
+func (c *Calendar) Add(t time.Time, d time.Duration) time.Time {
+	if !c.hasWorkingTime() || d == 0 {
+		return t.Add(d)
+	}
+	if d > 0 {
+		day := midnight(t)
+		for i := 0; i < maxSearchDays; i++ {
+			for _, s := range c.spans(day) {
+				if !t.Before(s.finish) {
+					continue
+				}
+				if t.Before(s.start) {
+					t = s.start
+				}
+				available := s.finish.Sub(t)
+				if d <= available {
+					return t.Add(d)
+				}
+				d -= available
+				t = s.finish
+			}
+			day = nextDay(day)
+		}
+		return t.Add(d)
+	}
+	day := midnight(t)
+	if day.Equal(t) {
+		day = previousDay(day)
+	}
+	for i := 0; i < maxSearchDays; i++ {
+		spans := c.spans(day)
+		for j := len(spans) - 1; j >= 0; j-- {
+			s := spans[j]
+			if !t.After(s.start) {
+				continue
+			}
+			if t.After(s.finish) {
+				t = s.finish
+			}
+			available := t.Sub(s.start)
+			if -d <= available {
+				return t.Add(d)
+			}
+			d += available
+			t = s.start
+		}
+		day = previousDay(day)
+	}
+	return t.Add(d)
+}
+
+// Sub returns the working time between 'u' and 't'.
+// The result is negative if 't' is before 'u'.
+func (c *Calendar) Sub(t, u time.Time) (d time.Duration) {
+	if !c.hasWorkingTime() {
+		return t.Sub(u)
+	}
+	if t.Before(u) {
+		return -c.Sub(u, t)
+	}
+	for day := midnight(u); day.Before(t); day = nextDay(day) {
+		for _, s := range c.spans(day) {
+			start, finish := s.start, s.finish
+			if start.Before(u) {
+				start = u
+			}
+			if finish.After(t) {
+				finish = t
+			}
+			if finish.After(start) {
+				d += finish.Sub(start)
+			}
+		}
+	}
+	return
+}
+
+// span is a period of working time at an absolute time.
+type span struct {
+	start, finish time.Time
+}
+
+// spans returns the periods of working time of the day starting at midnight 'day'.
+func (c *Calendar) spans(day time.Time) (spans []span) {
+	if !c.IsWorkDay(day) {
+		return
+	}
+	for _, s := range c.Shifts {
+		spans = append(spans, span{start: day.Add(s.Start), finish: day.Add(s.Finish)})
+	}
+	return
+}
+
+// hasWorkingTime reports whether the calendar defines working time,
+// a nil or empty calendar being treated as continuous.
+func (c *Calendar) hasWorkingTime() bool {
+	return c != nil && len(c.WorkDays) != 0 && len(c.Shifts) != 0
+}
+
+// isHoliday reports whether the day of 't' is a holiday.
+func (c *Calendar) isHoliday(t time.Time) bool {
+	y, m, d := t.Date()
+	for _, h := range c.Holidays {
+		hy, hm, hd := h.Date()
+		if y == hy && m == hm && d == hd {
+			return true
+		}
+	}
+	return false
+}
+
+// midnight returns the start of the day of 't'.
+func midnight(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// nextDay returns the start of the day following 'day'.
+func nextDay(day time.Time) time.Time {
+	y, m, d := day.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, day.Location())
+}
+
+// previousDay returns the start of the day preceding 'day'.
+func previousDay(day time.Time) time.Time {
+	y, m, d := day.Date()
+	return time.Date(y, m, d-1, 0, 0, 0, 0, day.Location())
+}
+
+// FormatWorkDays converts work days into a comma-separated string of abbreviated day names (e.g. "Mon,Tue").
+func FormatWorkDays(workDays []time.Weekday) string {
+	sDays := make([]string, 0, len(workDays))
+	for _, d := range workDays {
+		sDays = append(sDays, d.String()[:3])
+	}
+	return strings.Join(sDays, ",")
+}
+
+// ParseWorkDays converts a comma-separated string of abbreviated day names into work days.
+func ParseWorkDays(s string) (workDays []time.Weekday, err error) {
+	if s == "" {
+		return
+	}
+	for _, sDay := range strings.Split(s, ",") {
+		sDay = strings.TrimSpace(sDay)
+		found := false
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if strings.EqualFold(sDay, d.String()[:3]) {
+				workDays = append(workDays, d)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown day %q", sDay)
+		}
+	}
+	return
+}
+
+// FormatShifts converts shifts into a comma-separated string of hours (e.g. "08:00-12:00,13:00-17:00").
+func FormatShifts(shifts []Shift) string {
+	sShifts := make([]string, 0, len(shifts))
+	for _, s := range shifts {
+		sShifts = append(sShifts, fmt.Sprintf("%s-%s", formatClock(s.Start), formatClock(s.Finish)))
+	}
+	return strings.Join(sShifts, ",")
+}
+
+// ParseShifts converts a comma-separated string of hours (e.g. "08:00-12:00,13:00-17:00") into shifts.
+func ParseShifts(s string) (shifts []Shift, err error) {
+	if s == "" {
+		return
+	}
+	for _, sShift := range strings.Split(s, ",") {
+		bounds := strings.Split(strings.TrimSpace(sShift), "-")
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("malformed shift %q", sShift)
+		}
+		start, err := parseClock(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		finish, err := parseClock(bounds[1])
+		if err != nil {
+			return nil, err
+		}
+		shifts = append(shifts, Shift{Start: start, Finish: finish})
+	}
+	return
+}
+
+// FormatHolidays converts holidays into a comma-separated string of dates (e.g. "2024-12-25,2025-01-01").
+func FormatHolidays(holidays []time.Time) string {
+	sHolidays := make([]string, 0, len(holidays))
+	for _, h := range holidays {
+		sHolidays = append(sHolidays, h.Format(dateFormat))
+	}
+	return strings.Join(sHolidays, ",")
+}
+
+// ParseHolidays converts a comma-separated string of dates (e.g. "2024-12-25,2025-01-01") into holidays.
+func ParseHolidays(s string) (holidays []time.Time, err error) {
+	if s == "" {
+		return
+	}
+	for _, sHoliday := range strings.Split(s, ",") {
+		h, err := time.Parse(dateFormat, strings.TrimSpace(sHoliday))
+		if err != nil {
+			return nil, err
+		}
+		holidays = append(holidays, h)
+	}
+	return
+}
+
+// formatClock formats an offset from midnight as hours and minutes (e.g. "08:30").
+func formatClock(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// parseClock parses hours and minutes (e.g. "08:30") as an offset from midnight.
+func parseClock(s string) (d time.Duration, err error) {
+	var h, m int
+	if _, err = fmt.Sscanf(strings.TrimSpace(s), "%d:%d", &h, &m); err != nil {
+		return 0, fmt.Errorf("malformed time of day %q", s)
+	}
+	if h < 0 || h > 24 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
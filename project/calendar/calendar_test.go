@@ -0,0 +1,173 @@
+package calendar
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// Friday 5 January 2024
+var friday = time.Date(2024, time.January, 5, 0, 0, 0, 0, time.UTC)
+
+func TestNextWorkingTime(t *testing.T) {
+	c := New(1, "standard")
+	tests := []struct{ t, want time.Time }{
+		{friday.Add(9 * time.Hour), friday.Add(9 * time.Hour)},
+		{friday.Add(12 * time.Hour), friday.Add(13 * time.Hour)},
+		{friday.Add(17 * time.Hour), friday.Add(3*24*time.Hour + 8*time.Hour)},
+		{friday.Add(-7 * time.Hour), friday.Add(8 * time.Hour)},
+	}
+	for _, test := range tests {
+		if got := c.NextWorkingTime(test.t); !got.Equal(test.want) {
+			t.Errorf("NextWorkingTime(%v): got %v, want %v", test.t, got, test.want)
+		}
+	}
+}
+
+func TestPreviousWorkingTime(t *testing.T) {
+	c := New(1, "standard")
+	monday := friday.Add(3 * 24 * time.Hour)
+	tests := []struct{ t, want time.Time }{
+		{friday.Add(17 * time.Hour), friday.Add(17 * time.Hour)},
+		{friday.Add(12*time.Hour + 30*time.Minute), friday.Add(12 * time.Hour)},
+		{monday.Add(8 * time.Hour), friday.Add(17 * time.Hour)},
+		{monday, friday.Add(17 * time.Hour)},
+	}
+	for _, test := range tests {
+		if got := c.PreviousWorkingTime(test.t); !got.Equal(test.want) {
+			t.Errorf("PreviousWorkingTime(%v): got %v, want %v", test.t, got, test.want)
+		}
+	}
+}
+
+func TestAdd(t *testing.T) {
+	c := New(1, "standard")
+	monday := friday.Add(3 * 24 * time.Hour)
+	tests := []struct {
+		t    time.Time
+		d    time.Duration
+		want time.Time
+	}{
+		{friday.Add(8 * time.Hour), 8 * time.Hour, friday.Add(17 * time.Hour)},
+		{friday.Add(16 * time.Hour), 16 * time.Hour, monday.Add(24*time.Hour + 16*time.Hour)},
+		{friday.Add(18 * time.Hour), 2 * time.Hour, monday.Add(10 * time.Hour)},
+		{friday.Add(11 * time.Hour), 2 * time.Hour, friday.Add(14 * time.Hour)},
+		{monday.Add(10 * time.Hour), -2 * time.Hour, monday.Add(8 * time.Hour)},
+		{monday.Add(10 * time.Hour), -4 * time.Hour, friday.Add(15 * time.Hour)},
+		{friday.Add(17 * time.Hour), 0, friday.Add(17 * time.Hour)},
+	}
+	for _, test := range tests {
+		if got := c.Add(test.t, test.d); !got.Equal(test.want) {
+			t.Errorf("Add(%v, %v): got %v, want %v", test.t, test.d, got, test.want)
+		}
+	}
+}
+
+func TestAddHoliday(t *testing.T) {
+	c := New(1, "standard")
+	err := c.AddHoliday(friday)
+	if err != nil {
+		t.Error(err)
+	}
+	if c.IsWorkDay(friday.Add(10 * time.Hour)) {
+		t.Error("expected holiday not to be a work day")
+	}
+	if got, want := c.Add(friday.Add(-24*time.Hour+16*time.Hour), 2*time.Hour), friday.Add(3*24*time.Hour+9*time.Hour); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	err = c.AddHoliday(friday.Add(time.Hour))
+	if err == nil {
+		t.Error("expected AddHoliday to fail")
+	}
+}
+
+func TestSub(t *testing.T) {
+	c := New(1, "standard")
+	monday := friday.Add(3 * 24 * time.Hour)
+	if got, want := c.Sub(monday.Add(10*time.Hour), friday.Add(16*time.Hour)), 3*time.Hour; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := c.Sub(friday.Add(16*time.Hour), monday.Add(10*time.Hour)), -3*time.Hour; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := c.Sub(monday.Add(8*time.Hour), friday.Add(17*time.Hour)), time.Duration(0); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestContinuous(t *testing.T) {
+	var c *Calendar
+	start := friday.Add(18 * time.Hour)
+	if got := c.Add(start, 16*time.Hour); !got.Equal(start.Add(16 * time.Hour)) {
+		t.Errorf("got %v, want %v", got, start.Add(16*time.Hour))
+	}
+	if got := c.Sub(start.Add(time.Hour), start); got != time.Hour {
+		t.Errorf("got %v, want %v", got, time.Hour)
+	}
+	if got := c.NextWorkingTime(start); !got.Equal(start) {
+		t.Errorf("got %v, want %v", got, start)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c := New(1, "standard")
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+	c.Shifts = []Shift{{Start: 13 * time.Hour, Finish: 17 * time.Hour}, {Start: 8 * time.Hour, Finish: 12 * time.Hour}}
+	if err := c.Validate(); err == nil {
+		t.Error("expected Validate to fail")
+	}
+	c.Shifts = nil
+	if err := c.Validate(); err == nil {
+		t.Error("expected Validate to fail")
+	}
+}
+
+func TestFormatParse(t *testing.T) {
+	c := New(1, "standard")
+	c.Holidays = []time.Time{time.Date(2024, time.December, 25, 0, 0, 0, 0, time.UTC)}
+
+	sDays := FormatWorkDays(c.WorkDays)
+	if sDays != "Mon,Tue,Wed,Thu,Fri" {
+		t.Errorf("got %s, want %s", sDays, "Mon,Tue,Wed,Thu,Fri")
+	}
+	workDays, err := ParseWorkDays(sDays)
+	if err != nil {
+		t.Error(err)
+	}
+	if slices.Compare(workDays, c.WorkDays) != 0 {
+		t.Errorf("got %v, want %v", workDays, c.WorkDays)
+	}
+
+	sShifts := FormatShifts(c.Shifts)
+	if sShifts != "08:00-12:00,13:00-17:00" {
+		t.Errorf("got %s, want %s", sShifts, "08:00-12:00,13:00-17:00")
+	}
+	shifts, err := ParseShifts(sShifts)
+	if err != nil {
+		t.Error(err)
+	}
+	if !slices.Equal(shifts, c.Shifts) {
+		t.Errorf("got %v, want %v", shifts, c.Shifts)
+	}
+
+	sHolidays := FormatHolidays(c.Holidays)
+	if sHolidays != "2024-12-25" {
+		t.Errorf("got %s, want %s", sHolidays, "2024-12-25")
+	}
+	holidays, err := ParseHolidays(sHolidays)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(holidays) != 1 || !holidays[0].Equal(c.Holidays[0]) {
+		t.Errorf("got %v, want %v", holidays, c.Holidays)
+	}
+
+	if _, err = ParseWorkDays("Mon,Xyz"); err == nil {
+		t.Error("expected ParseWorkDays to fail")
+	}
+	if _, err = ParseShifts("08:00"); err == nil {
+		t.Error("expected ParseShifts to fail")
+	}
+}
@@ -0,0 +1,181 @@
+package project
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/vanillaiice/verano/activity"
+)
+
+// LinkSource defines which list of links is trusted when normalizing activities.
+type LinkSource int
+
+// Enumeration of available link sources.
+const (
+	FromPredecessors LinkSource = 0 // The successors lists are rebuilt from the predecessors lists
+	FromSuccessors   LinkSource = 1 // The predecessors lists are rebuilt from the successors lists
+)
+
+// Link links the activity with id 'predecessorId' to the activity with id 'successorId' in 'activitiesMap',
+// with the relationship 'rel'. The link is recorded in both the successors of the predecessor and the
+// predecessors of the successor. It returns an error if an activity is not found, if both ids are the same,
+// or if the activities are already linked.
+func Link(activitiesMap map[int]*activity.Activity, predecessorId, successorId int, rel activity.Relationship) (err error) {
+	p, s, err := linkEnds(activitiesMap, predecessorId, successorId)
+	if err != nil {
+		return
+	}
+	hasSuccessor := slices.Contains(p.SuccessorsId, successorId)
+	hasPredecessor := slices.Contains(s.PredecessorsId, predecessorId)
+	if hasSuccessor && hasPredecessor {
+		return fmt.Errorf("activity %d is already a predecessor of activity %d", predecessorId, successorId)
+	}
+	// a link recorded on one side only is completed
+	if !hasSuccessor {
+		p.SuccessorsId = append(p.SuccessorsId, successorId)
+	}
+	if !hasPredecessor {
+		s.PredecessorsId = append(s.PredecessorsId, predecessorId)
+	}
+	return s.SetRelationship(predecessorId, rel)
+}
+
+// Unlink removes the link between the activity with id 'predecessorId' and the activity with id 'successorId'
+// in 'activitiesMap', from both the successors of the predecessor and the predecessors of the successor.
+// It returns an error if an activity is not found, or if the activities are not linked.
+func Unlink(activitiesMap map[int]*activity.Activity, predecessorId, successorId int) (err error) {
+	p, s, err := linkEnds(activitiesMap, predecessorId, successorId)
+	if err != nil {
+		return
+	}
+	hasSuccessor := slices.Contains(p.SuccessorsId, successorId)
+	hasPredecessor := slices.Contains(s.PredecessorsId, predecessorId)
+	if !hasSuccessor && !hasPredecessor {
+		return fmt.Errorf("activity %d is not a predecessor of activity %d", predecessorId, successorId)
+	}
+	if hasSuccessor {
+		if err = p.RemoveSuccessor(successorId); err != nil {
+			return
+		}
+	}
+	if hasPredecessor {
+		if err = s.RemovePredecessor(predecessorId); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// UpdatePredecessorId replaces the predecessor 'oldId' of the activity with id 'successorId' in 'activitiesMap'
+// by the activity with id 'newId', keeping the relationship. The successors lists of both predecessors are updated.
+// It returns an error if an activity is not found, or if 'oldId' is not a predecessor of the activity.
+func UpdatePredecessorId(activitiesMap map[int]*activity.Activity, successorId, oldId, newId int) (err error) {
+	s, ok := activitiesMap[successorId]
+	if !ok {
+		return fmt.Errorf("no activity with id %d", successorId)
+	}
+	if !slices.Contains(s.PredecessorsId, oldId) {
+		return fmt.Errorf("activity %d is not a predecessor of activity %d", oldId, successorId)
+	}
+	// the new predecessor is checked first, so that the activities are left untouched on error
+	if _, _, err = linkEnds(activitiesMap, newId, successorId); err != nil {
+		return
+	}
+	if slices.Contains(s.PredecessorsId, newId) {
+		return fmt.Errorf("activity %d is already a predecessor of activity %d", newId, successorId)
+	}
+	rel := s.Relationship(oldId)
+	if err = Unlink(activitiesMap, oldId, successorId); err != nil {
+		return
+	}
+	return Link(activitiesMap, newId, successorId, rel)
+}
+
+// UpdateId changes the id of the activity with id 'oldId' in 'activitiesMap' to 'newId', and updates
+// the predecessors, successors and relationships of the activities referencing it.
+// It returns an error if the activity is not found, or if an activity with id 'newId' already exists.
+func UpdateId(activitiesMap map[int]*activity.Activity, oldId, newId int) (err error) {
+	a, ok := activitiesMap[oldId]
+	if !ok {
+		return fmt.Errorf("no activity with id %d", oldId)
+	}
+	if _, ok = activitiesMap[newId]; ok {
+		return fmt.Errorf("activity with id %d already exists", newId)
+	}
+	for _, other := range activitiesMap {
+		if slices.Contains(other.PredecessorsId, oldId) {
+			if err = other.UpdatePredecessorId(oldId, newId); err != nil {
+				return
+			}
+		}
+		if slices.Contains(other.SuccessorsId, oldId) {
+			if err = other.UpdateSuccessorId(oldId, newId); err != nil {
+				return
+			}
+		}
+	}
+	delete(activitiesMap, oldId)
+	a.Id = newId
+	activitiesMap[newId] = a
+	return
+}
+
+// Normalize rebuilds the links of the provided 'activities' from a single 'source', so that the predecessors
+// and successors lists agree. This is useful when importing files that only fill in one of the lists.
+// When rebuilding from the successors, the relationships of the links that are kept are preserved.
+// Links to activities that are not in 'activities' are left in the source lists, but are not mirrored.
+// The rebuilt lists are sorted by id. This function modifies the 'activities' in-place.
+func Normalize(activities []*activity.Activity, source LinkSource) {
+	activitiesMap := make(map[int]*activity.Activity)
+	for _, a := range activities {
+		activitiesMap[a.Id] = a
+	}
+
+	rebuilt := make(map[int][]int)
+	for _, a := range activities {
+		linked := a.PredecessorsId
+		if source == FromSuccessors {
+			linked = a.SuccessorsId
+		}
+		for _, id := range linked {
+			if _, ok := activitiesMap[id]; ok && !slices.Contains(rebuilt[id], a.Id) {
+				rebuilt[id] = append(rebuilt[id], a.Id)
+			}
+		}
+	}
+
+	for _, a := range activities {
+		ids := rebuilt[a.Id]
+		if ids == nil {
+			ids = []int{}
+		}
+		slices.Sort(ids)
+		if source == FromPredecessors {
+			a.SuccessorsId = ids
+			continue
+		}
+		a.PredecessorsId = ids
+		for id := range a.Relationships {
+			if !slices.Contains(ids, id) {
+				delete(a.Relationships, id)
+			}
+		}
+	}
+}
+
+// linkEnds returns the activities with ids 'predecessorId' and 'successorId' in 'activitiesMap'.
+// It returns an error if an activity is not found or if both ids are the same.
+func linkEnds(activitiesMap map[int]*activity.Activity, predecessorId, successorId int) (p, s *activity.Activity, err error) {
+	if predecessorId == successorId {
+		return nil, nil, fmt.Errorf("activity %d cannot be linked to itself", predecessorId)
+	}
+	p, ok := activitiesMap[predecessorId]
+	if !ok {
+		return nil, nil, fmt.Errorf("no activity with id %d", predecessorId)
+	}
+	s, ok = activitiesMap[successorId]
+	if !ok {
+		return nil, nil, fmt.Errorf("no activity with id %d", successorId)
+	}
+	return
+}
@@ -0,0 +1,130 @@
+package project
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/vanillaiice/verano/activity"
+	"github.com/vanillaiice/verano/util"
+)
+
+func newActivities() []*activity.Activity {
+	return []*activity.Activity{
+		{Id: 1, Description: "Dig trench", Duration: time.Hour},
+		{Id: 2, Description: "Lay pipe", Duration: time.Hour},
+		{Id: 3, Description: "Backfill trench", Duration: time.Hour},
+	}
+}
+
+func TestLinkUnlink(t *testing.T) {
+	activitiesMap := util.ActivitiesToMap(newActivities())
+	rel := activity.Relationship{Type: activity.StartToStart, Lag: time.Hour}
+
+	if err := Link(activitiesMap, 1, 2, rel); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(activitiesMap[1].SuccessorsId, []int{2}) || !slices.Equal(activitiesMap[2].PredecessorsId, []int{1}) {
+		t.Errorf("got successors %v and predecessors %v", activitiesMap[1].SuccessorsId, activitiesMap[2].PredecessorsId)
+	}
+	if activitiesMap[2].Relationship(1) != rel {
+		t.Errorf("got %v, want %v", activitiesMap[2].Relationship(1), rel)
+	}
+	if err := Link(activitiesMap, 1, 2, rel); err == nil {
+		t.Error("expected Link to fail")
+	}
+	if err := Link(activitiesMap, 1, 1, rel); err == nil {
+		t.Error("expected Link to fail")
+	}
+	if err := Link(activitiesMap, 1, 9, rel); err == nil {
+		t.Error("expected Link to fail")
+	}
+
+	// a link recorded on one side only is completed
+	activitiesMap[3].PredecessorsId = []int{2}
+	if err := Link(activitiesMap, 2, 3, activity.Relationship{}); err != nil {
+		t.Error(err)
+	}
+	if !slices.Equal(activitiesMap[2].SuccessorsId, []int{3}) || !slices.Equal(activitiesMap[3].PredecessorsId, []int{2}) {
+		t.Errorf("got successors %v and predecessors %v", activitiesMap[2].SuccessorsId, activitiesMap[3].PredecessorsId)
+	}
+
+	if err := Unlink(activitiesMap, 1, 2); err != nil {
+		t.Error(err)
+	}
+	if len(activitiesMap[1].SuccessorsId) != 0 || len(activitiesMap[2].PredecessorsId) != 0 || len(activitiesMap[2].Relationships) != 0 {
+		t.Errorf("got %+v and %+v, want no link", activitiesMap[1], activitiesMap[2])
+	}
+	if err := Unlink(activitiesMap, 1, 2); err == nil {
+		t.Error("expected Unlink to fail")
+	}
+}
+
+func TestUpdatePredecessorId(t *testing.T) {
+	activitiesMap := util.ActivitiesToMap(newActivities())
+	rel := activity.Relationship{Type: activity.FinishToFinish, Lag: -time.Hour}
+	if err := Link(activitiesMap, 1, 3, rel); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := UpdatePredecessorId(activitiesMap, 3, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if len(activitiesMap[1].SuccessorsId) != 0 || !slices.Equal(activitiesMap[2].SuccessorsId, []int{3}) || !slices.Equal(activitiesMap[3].PredecessorsId, []int{2}) {
+		t.Errorf("got %+v, %+v and %+v", activitiesMap[1], activitiesMap[2], activitiesMap[3])
+	}
+	if activitiesMap[3].Relationship(2) != rel {
+		t.Errorf("got %v, want %v", activitiesMap[3].Relationship(2), rel)
+	}
+
+	if err := UpdatePredecessorId(activitiesMap, 3, 2, 9); err == nil {
+		t.Error("expected UpdatePredecessorId to fail")
+	}
+	if !slices.Equal(activitiesMap[3].PredecessorsId, []int{2}) {
+		t.Errorf("got %v, want activities left untouched", activitiesMap[3].PredecessorsId)
+	}
+}
+
+func TestUpdateId(t *testing.T) {
+	activitiesMap := util.ActivitiesToMap(newActivities())
+	rel := activity.Relationship{Type: activity.StartToStart}
+	if err := Link(activitiesMap, 1, 2, rel); err != nil {
+		t.Fatal(err)
+	}
+	if err := Link(activitiesMap, 2, 3, activity.Relationship{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := UpdateId(activitiesMap, 1, 10); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := activitiesMap[1]; ok || activitiesMap[10].Id != 10 {
+		t.Errorf("got %v, want activity 1 renumbered to 10", activitiesMap)
+	}
+	if !slices.Equal(activitiesMap[2].PredecessorsId, []int{10}) || activitiesMap[2].Relationship(10) != rel {
+		t.Errorf("got %+v, want predecessor 10", activitiesMap[2])
+	}
+
+	if err := UpdateId(activitiesMap, 2, 3); err == nil {
+		t.Error("expected UpdateId to fail")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	activities := newActivities()
+	activities[1].PredecessorsId = []int{1}
+	activities[2].PredecessorsId = []int{1, 2, 9}
+	Normalize(activities, FromPredecessors)
+	if !slices.Equal(activities[0].SuccessorsId, []int{2, 3}) || !slices.Equal(activities[1].SuccessorsId, []int{3}) || len(activities[2].SuccessorsId) != 0 {
+		t.Errorf("got %v, %v and %v", activities[0].SuccessorsId, activities[1].SuccessorsId, activities[2].SuccessorsId)
+	}
+
+	activities = newActivities()
+	activities[0].SuccessorsId = []int{3}
+	activities[2].PredecessorsId = []int{2}
+	activities[2].Relationships = map[int]activity.Relationship{2: {Type: activity.StartToStart}}
+	Normalize(activities, FromSuccessors)
+	if !slices.Equal(activities[2].PredecessorsId, []int{1}) || len(activities[2].Relationships) != 0 {
+		t.Errorf("got %+v, want only predecessor 1", activities[2])
+	}
+}
@@ -0,0 +1,234 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/heimdalr/dag"
+	"github.com/vanillaiice/verano/activity"
+	"github.com/vanillaiice/verano/project/calendar"
+	"github.com/vanillaiice/verano/project/timeline"
+	"github.com/vanillaiice/verano/sorter"
+	"github.com/vanillaiice/verano/util"
+	"github.com/vanillaiice/verano/validate"
+)
+
+// Project holds the activities of a project along with its settings, and keeps the graph
+// and the map of the activities in sync when activities are added, removed or linked.
+type Project struct {
+	Name       string                          // Name of the project
+	StartDate  time.Time                       // Date from which the activities are scheduled
+	DataDate   time.Time                       // Date up to which progress is recorded, progress is ignored if zero
+	Calendars  []*calendar.Calendar            // Calendars referenced by the activities
+	Metadata   map[string]string               // Free-form information about the project (e.g. client, manager)
+	Violations []*timeline.ConstraintViolation // Constraint violations found by the last schedule
+
+	activitiesMap map[int]*activity.Activity
+	graph         *dag.DAG
+}
+
+// linkKinds are the kinds of findings that prevent a project from keeping its graph in sync with its activities.
+var linkKinds = []validate.Kind{
+	validate.DuplicateId,
+	validate.MissingPredecessor,
+	validate.MissingSuccessor,
+	validate.InconsistentLink,
+	validate.SelfLink,
+	validate.LogicLoop,
+}
+
+// New creates a project with the given 'name', 'startDate' and 'activities'.
+// It returns an error listing the problems found if the activities have duplicate ids,
+// links to activities that do not exist, links recorded on one side only, self links or logic loops.
+// Normalize can be used beforehand to rebuild the links recorded on one side only.
+func New(name string, startDate time.Time, activities []*activity.Activity) (p *Project, err error) {
+	var errs []error
+	for _, f := range validate.Check(activities) {
+		if slices.Contains(linkKinds, f.Kind) {
+			errs = append(errs, errors.New(f.String()))
+		}
+	}
+	if err = errors.Join(errs...); err != nil {
+		return
+	}
+
+	graph, err := util.ActivitiesToGraph(activities)
+	if err != nil {
+		return
+	}
+
+	p = &Project{
+		Name:          name,
+		StartDate:     startDate,
+		Metadata:      make(map[string]string),
+		activitiesMap: util.ActivitiesToMap(activities),
+		graph:         graph,
+	}
+	return
+}
+
+// Activity returns the activity with the given 'id'.
+// It returns an error if there is no activity with this id in the project.
+func (p *Project) Activity(id int) (a *activity.Activity, err error) {
+	a, ok := p.activitiesMap[id]
+	if !ok {
+		return nil, fmt.Errorf("no activity with id %d", id)
+	}
+	return
+}
+
+// Activities returns the activities of the project sorted by id.
+func (p *Project) Activities() (activities []*activity.Activity) {
+	activities = make([]*activity.Activity, 0, len(p.activitiesMap))
+	for _, a := range p.activitiesMap {
+		activities = append(activities, a)
+	}
+	sorter.SortActivitiesById(activities)
+	return
+}
+
+// ActivitiesMap returns a map with activity ids as keys and pointers to the activities of the project as values.
+// The map is a copy, changes to it are not reflected in the project.
+func (p *Project) ActivitiesMap() map[int]*activity.Activity {
+	return util.ActivitiesToMap(p.Activities())
+}
+
+// AddActivity adds the activity 'a' to the project, linking it to the predecessors and successors it lists.
+// It returns an error if an activity with the same id exists, if a predecessor or successor is not found,
+// or if a link would create a logic loop, in which case the project is left untouched.
+func (p *Project) AddActivity(a *activity.Activity) (err error) {
+	if _, ok := p.activitiesMap[a.Id]; ok {
+		return fmt.Errorf("activity with id %d already exists", a.Id)
+	}
+	for _, id := range append(slices.Clone(a.PredecessorsId), a.SuccessorsId...) {
+		if _, ok := p.activitiesMap[id]; !ok {
+			return fmt.Errorf("no activity with id %d", id)
+		}
+	}
+
+	if err = p.graph.AddVertexByID(fmt.Sprint(a.Id), a); err != nil {
+		return
+	}
+	for _, id := range a.PredecessorsId {
+		if err = p.graph.AddEdge(fmt.Sprint(id), fmt.Sprint(a.Id)); err != nil {
+			p.graph.DeleteVertex(fmt.Sprint(a.Id))
+			return
+		}
+	}
+	for _, id := range a.SuccessorsId {
+		if err = p.graph.AddEdge(fmt.Sprint(a.Id), fmt.Sprint(id)); err != nil {
+			p.graph.DeleteVertex(fmt.Sprint(a.Id))
+			return
+		}
+	}
+
+	p.activitiesMap[a.Id] = a
+	for _, id := range a.PredecessorsId {
+		if other := p.activitiesMap[id]; !slices.Contains(other.SuccessorsId, a.Id) {
+			other.SuccessorsId = append(other.SuccessorsId, a.Id)
+		}
+	}
+	for _, id := range a.SuccessorsId {
+		if other := p.activitiesMap[id]; !slices.Contains(other.PredecessorsId, a.Id) {
+			other.PredecessorsId = append(other.PredecessorsId, a.Id)
+		}
+	}
+	return
+}
+
+// RemoveActivity removes the activity with the given 'id' from the project,
+// along with its links to other activities.
+// It returns an error if there is no activity with this id in the project.
+func (p *Project) RemoveActivity(id int) (err error) {
+	a, err := p.Activity(id)
+	if err != nil {
+		return
+	}
+	for _, predecessorId := range slices.Clone(a.PredecessorsId) {
+		if err = Unlink(p.activitiesMap, predecessorId, id); err != nil {
+			return
+		}
+	}
+	for _, successorId := range slices.Clone(a.SuccessorsId) {
+		if err = Unlink(p.activitiesMap, id, successorId); err != nil {
+			return
+		}
+	}
+	delete(p.activitiesMap, id)
+	return p.graph.DeleteVertex(fmt.Sprint(id))
+}
+
+// Link links the activity with id 'predecessorId' to the activity with id 'successorId' with the relationship 'rel'.
+// It returns an error if an activity is not found, if the activities are already linked,
+// or if the link would create a logic loop.
+func (p *Project) Link(predecessorId, successorId int, rel activity.Relationship) (err error) {
+	if _, _, err = linkEnds(p.activitiesMap, predecessorId, successorId); err != nil {
+		return
+	}
+	if err = p.graph.AddEdge(fmt.Sprint(predecessorId), fmt.Sprint(successorId)); err != nil {
+		var loopErr dag.EdgeLoopError
+		if errors.As(err, &loopErr) {
+			return fmt.Errorf("linking activity %d to activity %d would create a logic loop", predecessorId, successorId)
+		}
+		return fmt.Errorf("activity %d is already a predecessor of activity %d", predecessorId, successorId)
+	}
+	return Link(p.activitiesMap, predecessorId, successorId, rel)
+}
+
+// Unlink removes the link between the activity with id 'predecessorId' and the activity with id 'successorId'.
+// It returns an error if an activity is not found, or if the activities are not linked.
+func (p *Project) Unlink(predecessorId, successorId int) (err error) {
+	if err = Unlink(p.activitiesMap, predecessorId, successorId); err != nil {
+		return
+	}
+	return p.graph.DeleteEdge(fmt.Sprint(predecessorId), fmt.Sprint(successorId))
+}
+
+// UpdateId changes the id of the activity with id 'oldId' to 'newId', and updates the links of the activities referencing it.
+// It returns an error if the activity is not found, or if an activity with id 'newId' already exists.
+func (p *Project) UpdateId(oldId, newId int) (err error) {
+	if err = UpdateId(p.activitiesMap, oldId, newId); err != nil {
+		return
+	}
+	// the vertices of the graph are identified by the activity ids, so the graph is rebuilt
+	p.graph, err = util.ActivitiesToGraph(p.Activities())
+	return
+}
+
+// Scheduler returns a scheduler using the calendars and the data date of the project.
+func (p *Project) Scheduler() *timeline.Scheduler {
+	s := timeline.NewScheduler(p.Calendars)
+	s.DataDate = p.DataDate
+	return s
+}
+
+// Order returns the ids of the activities sorted by their dependencies.
+func (p *Project) Order() []int {
+	return sorter.SortActivitiesByDeps(p.graph)
+}
+
+// Schedule computes the timeline of the activities from the start date of the project,
+// in working time of the calendars of the project and taking progress into account if a data date is set.
+// It returns the constraints that cannot be met or that cause negative float, which are also kept in 'Violations'.
+func (p *Project) Schedule() []*timeline.ConstraintViolation {
+	p.Violations = p.Scheduler().Schedule(p.activitiesMap, p.Order(), p.StartDate)
+	return p.Violations
+}
+
+// FinishDate returns the latest finish time of the activities of the project.
+func (p *Project) FinishDate() time.Time {
+	return timeline.ProjectFinishDate(p.activitiesMap)
+}
+
+// CriticalPath returns the critical paths of the project, at most timeline.MaxCriticalPaths, each path being
+// a slice of activity ids ordered from the start to the finish of the path. The project should be scheduled beforehand.
+func (p *Project) CriticalPath() [][]int {
+	return p.Scheduler().CriticalPath(p.activitiesMap, p.Order())
+}
+
+// Validate checks the integrity of the activities of the project, and returns the problems found.
+func (p *Project) Validate() []validate.Finding {
+	return validate.Check(p.Activities())
+}
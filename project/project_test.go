@@ -0,0 +1,116 @@
+package project
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/vanillaiice/verano/activity"
+	"github.com/vanillaiice/verano/project/calendar"
+)
+
+var startDate = time.Date(2024, time.January, 1, 8, 0, 0, 0, time.UTC)
+
+func TestNew(t *testing.T) {
+	activities := newActivities()
+	activities[0].SuccessorsId = []int{2}
+	if _, err := New("pipeline", startDate, activities); err == nil {
+		t.Error("expected New to fail")
+	}
+
+	Normalize(activities, FromSuccessors)
+	p, err := New("pipeline", startDate, activities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := p.Activity(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != activities[1] {
+		t.Errorf("got %+v, want %+v", a, activities[1])
+	}
+	if _, err = p.Activity(9); err == nil {
+		t.Error("expected Activity to fail")
+	}
+}
+
+func TestProjectSchedule(t *testing.T) {
+	p, err := New("pipeline", startDate, newActivities())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Link(1, 2, activity.Relationship{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Link(2, 3, activity.Relationship{Type: activity.StartToStart, Lag: 30 * time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Link(3, 1, activity.Relationship{}); err == nil {
+		t.Error("expected Link to fail on a logic loop")
+	}
+	if err = p.Link(1, 2, activity.Relationship{}); err == nil {
+		t.Error("expected Link to fail on an existing link")
+	}
+
+	if err = p.AddActivity(&activity.Activity{Id: 4, Description: "Order signs", Duration: 30 * time.Minute, SuccessorsId: []int{3}}); err != nil {
+		t.Fatal(err)
+	}
+	if a, _ := p.Activity(3); !slices.Contains(a.PredecessorsId, 4) {
+		t.Errorf("got %v, want predecessor 4", a.PredecessorsId)
+	}
+	if err = p.AddActivity(&activity.Activity{Id: 5, PredecessorsId: []int{3}, SuccessorsId: []int{1}}); err == nil {
+		t.Error("expected AddActivity to fail on a logic loop")
+	}
+	if _, err = p.Activity(5); err == nil {
+		t.Error("expected activity 5 not to be added")
+	}
+
+	if violations := p.Schedule(); len(violations) != 0 {
+		t.Errorf("got %v, want no violations", violations)
+	}
+	if got, want := p.FinishDate(), startDate.Add(2*time.Hour+30*time.Minute); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := p.CriticalPath(), [][]int{{1, 2, 3}}; len(got) != 1 || !slices.Equal(got[0], want[0]) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if err = p.Unlink(2, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.RemoveActivity(2); err != nil {
+		t.Fatal(err)
+	}
+	if a, _ := p.Activity(1); len(a.SuccessorsId) != 0 {
+		t.Errorf("got %v, want no successors", a.SuccessorsId)
+	}
+	if err = p.UpdateId(4, 2); err != nil {
+		t.Fatal(err)
+	}
+	if findings := p.Validate(); len(findings) != 4 {
+		t.Errorf("got %v, want %d open ends", findings, 4)
+	}
+	if err = p.Link(3, 2, activity.Relationship{}); err == nil {
+		t.Error("expected Link to fail on a logic loop after renumbering")
+	}
+}
+
+func TestProjectCalendars(t *testing.T) {
+	p, err := New("pipeline", startDate, newActivities())
+	if err != nil {
+		t.Fatal(err)
+	}
+	standard := calendar.New(1, "standard")
+	standard.Default = true
+	p.Calendars = []*calendar.Calendar{standard}
+	if err = p.Link(1, 2, activity.Relationship{}); err != nil {
+		t.Fatal(err)
+	}
+	p.StartDate = startDate.Add(8 * time.Hour)
+	p.Schedule()
+	a, _ := p.Activity(2)
+	if want := startDate.Add(24 * time.Hour); !a.Start.Equal(want) {
+		t.Errorf("got %v, want %v", a.Start, want)
+	}
+}
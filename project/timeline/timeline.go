@@ -1,31 +1,473 @@
 package timeline
 
 import (
+	"fmt"
+	"slices"
 	"time"
 
 	"github.com/vanillaiice/verano/activity"
+	"github.com/vanillaiice/verano/project/calendar"
 )
 
+// A Scheduler computes the timeline of activities in working time,
+// using the calendars referenced by the activities.
+// When a data date is set, the progress of the activities is taken into account:
+// completed activities keep their actual start and finish, the remaining work of
+// started activities is scheduled from the data date, and activities that are not
+// started cannot start before the data date.
+type Scheduler struct {
+	Calendars       map[int]*calendar.Calendar // Calendars referenced by the activities, keyed by calendar id
+	DefaultCalendar *calendar.Calendar         // Calendar of the activities without calendar, or continuous time if nil
+	DataDate        time.Time                  // Date up to which progress is recorded, progress is ignored if zero
+}
+
+// MaxCriticalPaths is the maximum number of critical paths returned by CriticalPath.
+const MaxCriticalPaths = 100
+
+// ConstraintViolation describes a constraint of an activity that is not met or that causes negative float.
+type ConstraintViolation struct {
+	ActivityId     int                     // ID of the activity with the constraint
+	ConstraintType activity.ConstraintType // Type of the constraint
+	ConstraintDate time.Time               // Date of the constraint
+	Float          time.Duration           // Negative float caused by the constraint
+}
+
+// Error returns a description of the constraint violation.
+func (v *ConstraintViolation) Error() string {
+	return fmt.Sprintf("activity %d: %s constraint on %s causes %s of negative float", v.ActivityId, v.ConstraintType, v.ConstraintDate.Format(time.DateTime), -v.Float)
+}
+
+// NewScheduler creates a scheduler using the provided 'calendars',
+// the calendar marked as default being used by the activities without calendar.
+func NewScheduler(calendars []*calendar.Calendar) *Scheduler {
+	return &Scheduler{
+		Calendars:       calendar.ToMap(calendars),
+		DefaultCalendar: calendar.Default(calendars),
+	}
+}
+
+// UpdateStartFinishTime updates the start and finish times of activities in the provided 'activitiesMap'
+// based on the order of activities sorted by their dependencies and the 'projectStartDate'.
+// Time is continuous, use a Scheduler to compute the times in working time.
+// This function modifies the 'activitiesMap' in-place.
+func UpdateStartFinishTime(activitiesMap map[int]*activity.Activity, orderActivitiesSortedByDep []int, projectStartDate time.Time) {
+	(&Scheduler{}).UpdateStartFinishTime(activitiesMap, orderActivitiesSortedByDep, projectStartDate)
+}
+
+// UpdateLateStartFinishTime updates the late start and late finish times of activities in the provided 'activitiesMap'
+// based on the order of activities sorted by their dependencies and the 'projectFinishDate'.
+// Time is continuous, use a Scheduler to compute the times in working time.
+// This function modifies the 'activitiesMap' in-place.
+func UpdateLateStartFinishTime(activitiesMap map[int]*activity.Activity, orderActivitiesSortedByDep []int, projectFinishDate time.Time) {
+	(&Scheduler{}).UpdateLateStartFinishTime(activitiesMap, orderActivitiesSortedByDep, projectFinishDate)
+}
+
+// UpdateFloat updates the total float and free float of activities in the provided 'activitiesMap'.
+// Time is continuous, use a Scheduler to compute the float in working time.
+// This function modifies the 'activitiesMap' in-place.
+func UpdateFloat(activitiesMap map[int]*activity.Activity, projectFinishDate time.Time) {
+	(&Scheduler{}).UpdateFloat(activitiesMap, projectFinishDate)
+}
+
+// CriticalPath returns the critical paths of the activities in the provided 'activitiesMap',
+// each path being a slice of activity ids ordered from the start to the finish of the path.
+// Time is continuous, use a Scheduler when the activities were scheduled in working time.
+func CriticalPath(activitiesMap map[int]*activity.Activity, orderActivitiesSortedByDep []int) (paths [][]int) {
+	return (&Scheduler{}).CriticalPath(activitiesMap, orderActivitiesSortedByDep)
+}
+
+// Schedule computes the timeline of the activities in the provided 'activitiesMap' in continuous time,
+// and returns the constraint violations. Use a Scheduler to compute the timeline in working time.
+// This function modifies the 'activitiesMap' in-place.
+func Schedule(activitiesMap map[int]*activity.Activity, orderActivitiesSortedByDep []int, projectStartDate time.Time) []*ConstraintViolation {
+	return (&Scheduler{}).Schedule(activitiesMap, orderActivitiesSortedByDep, projectStartDate)
+}
+
+// ProjectFinishDate returns the latest finish time of the activities in the provided 'activitiesMap'.
+func ProjectFinishDate(activitiesMap map[int]*activity.Activity) (projectFinishDate time.Time) {
+	for _, a := range activitiesMap {
+		if a.Finish.After(projectFinishDate) {
+			projectFinishDate = a.Finish
+		}
+	}
+	return
+}
+
+// Schedule computes the timeline of the activities in the provided 'activitiesMap':
+// the start and finish times from the 'projectStartDate', the late start and late finish times
+// from the project finish date, the float, and the times of the activities scheduled as late as possible.
+// It returns the constraints that cannot be met or that cause negative float.
+// This function modifies the 'activitiesMap' in-place.
+func (s *Scheduler) Schedule(activitiesMap map[int]*activity.Activity, orderActivitiesSortedByDep []int, projectStartDate time.Time) []*ConstraintViolation {
+	s.UpdateStartFinishTime(activitiesMap, orderActivitiesSortedByDep, projectStartDate)
+	projectFinishDate := ProjectFinishDate(activitiesMap)
+	s.UpdateLateStartFinishTime(activitiesMap, orderActivitiesSortedByDep, projectFinishDate)
+	s.UpdateFloat(activitiesMap, projectFinishDate)
+	s.UpdateAsLateAsPossible(activitiesMap, orderActivitiesSortedByDep, projectFinishDate)
+	return s.CheckConstraints(activitiesMap)
+}
+
+// Calendar returns the calendar of activity 'a', which is the scheduler's default calendar
+// if the activity has no calendar or references an unknown one.
+func (s *Scheduler) Calendar(a *activity.Activity) *calendar.Calendar {
+	if c, ok := s.Calendars[a.CalendarId]; ok && a.CalendarId != 0 {
+		return c
+	}
+	return s.DefaultCalendar
+}
+
 // UpdateStartFinishTime updates the start and finish times of activities in the provided 'activitiesMap'
 // based on the order of activities sorted by their dependencies and the 'projectStartDate'.
 // It iterates through the 'orderActivitiesSortedByDep' slice, representing activities sorted by their dependencies,
-// and calculates the earliest finish time considering the finish times of their predecessors.
+// and calculates the earliest start time allowed by the relationships (type and lag) with their predecessors,
+// and by the start no earlier than and finish no earlier than constraints.
+// Must start on and must finish on constraints override the relationships.
+// The start is then moved to the next working time of the activity's calendar,
+// and the finish is the start plus the duration in working time.
+// When a data date is set, the start and finish of started activities are computed from their progress.
 // The 'Start' and 'Finish' fields of each activity in 'activitiesMap' are then updated accordingly.
 // This function modifies the 'activitiesMap' in-place.
-func UpdateStartFinishTime(activitiesMap map[int]*activity.Activity, orderActivitiesSortedByDep []int, projectStartDate time.Time) {
+func (s *Scheduler) UpdateStartFinishTime(activitiesMap map[int]*activity.Activity, orderActivitiesSortedByDep []int, projectStartDate time.Time) {
 	for _, id := range orderActivitiesSortedByDep {
 		a := activitiesMap[id]
-		minFinishTime := projectStartDate
+		cal := s.Calendar(a)
+
+		if s.isStarted(a) {
+			a.Start = a.ActualStart
+			if s.isCompleted(a) {
+				a.Finish = a.ActualFinish
+			} else {
+				a.Finish = cal.Add(cal.NextWorkingTime(s.DataDate), s.remainingDuration(a))
+			}
+			if a.Start.IsZero() {
+				a.Start = cal.Add(a.Finish, -a.Duration)
+			}
+			continue
+		}
+
+		minStartTime := projectStartDate
+		if s.DataDate.After(minStartTime) {
+			minStartTime = s.DataDate
+		}
 
 		for _, predecessorId := range a.PredecessorsId {
-			predecessorFinishTime := activitiesMap[predecessorId].Finish
-			if predecessorFinishTime.After(minFinishTime) {
-				minFinishTime = predecessorFinishTime
+			startTime := earliestStart(cal, a, activitiesMap[predecessorId], a.Relationship(predecessorId))
+			if startTime.After(minStartTime) {
+				minStartTime = startTime
 			}
 		}
 
-		a.Start = minFinishTime
-		a.Finish = minFinishTime.Add(a.Duration)
+		switch a.ConstraintType {
+		case activity.StartNoEarlierThan:
+			if a.ConstraintDate.After(minStartTime) {
+				minStartTime = a.ConstraintDate
+			}
+		case activity.FinishNoEarlierThan:
+			if startTime := cal.Add(a.ConstraintDate, -a.Duration); startTime.After(minStartTime) {
+				minStartTime = startTime
+			}
+		}
+
+		switch a.ConstraintType {
+		case activity.MustStartOn:
+			a.Start = a.ConstraintDate
+		case activity.MustFinishOn:
+			a.Start = cal.Add(a.ConstraintDate, -a.Duration)
+		default:
+			a.Start = cal.NextWorkingTime(minStartTime)
+		}
+		a.Finish = cal.Add(a.Start, a.Duration)
 		activitiesMap[id] = a
 	}
 }
+
+// UpdateLateStartFinishTime updates the late start and late finish times of activities in the provided 'activitiesMap'
+// based on the order of activities sorted by their dependencies and the 'projectFinishDate'.
+// It iterates through the 'orderActivitiesSortedByDep' slice in reverse order,
+// and calculates the latest finish time allowed by the relationships (type and lag) with their successors,
+// and by the start no later than and finish no later than constraints.
+// Must start on and must finish on constraints override the relationships.
+// The late finish is then moved to the previous working time of the activity's calendar,
+// and the late start is the late finish minus the remaining duration in working time.
+// When a data date is set, the late times of completed activities are their actual times.
+// The 'LateStart' and 'LateFinish' fields of each activity in 'activitiesMap' are then updated accordingly.
+// This function modifies the 'activitiesMap' in-place.
+func (s *Scheduler) UpdateLateStartFinishTime(activitiesMap map[int]*activity.Activity, orderActivitiesSortedByDep []int, projectFinishDate time.Time) {
+	for i := len(orderActivitiesSortedByDep) - 1; i >= 0; i-- {
+		a := activitiesMap[orderActivitiesSortedByDep[i]]
+		cal := s.Calendar(a)
+		if s.isCompleted(a) {
+			a.LateStart = a.Start
+			a.LateFinish = a.Finish
+			continue
+		}
+		maxFinishTime := projectFinishDate
+
+		for _, successorId := range a.SuccessorsId {
+			succ := activitiesMap[successorId]
+			finishTime := latestFinish(cal, s.Calendar(succ), a, succ, succ.Relationship(a.Id))
+			if finishTime.Before(maxFinishTime) {
+				maxFinishTime = finishTime
+			}
+		}
+
+		switch a.ConstraintType {
+		case activity.StartNoLaterThan:
+			if finishTime := cal.Add(a.ConstraintDate, a.Duration); finishTime.Before(maxFinishTime) {
+				maxFinishTime = finishTime
+			}
+		case activity.FinishNoLaterThan:
+			if a.ConstraintDate.Before(maxFinishTime) {
+				maxFinishTime = a.ConstraintDate
+			}
+		}
+
+		switch {
+		case a.ConstraintType == activity.MustStartOn && !s.isStarted(a):
+			a.LateFinish = cal.Add(a.ConstraintDate, a.Duration)
+		case a.ConstraintType == activity.MustFinishOn && !s.isStarted(a):
+			a.LateFinish = a.ConstraintDate
+		default:
+			a.LateFinish = cal.PreviousWorkingTime(maxFinishTime)
+		}
+		a.LateStart = cal.Add(a.LateFinish, -s.remainingDuration(a))
+	}
+}
+
+// UpdateFloat updates the total float and free float of activities in the provided 'activitiesMap',
+// in working time of the activities' calendars.
+// The total float is the difference between the late finish and the finish of an activity,
+// and the free float is how much the activity can be delayed before one of its successors
+// (or the 'projectFinishDate' if it has none) is delayed.
+// It should be called after UpdateStartFinishTime and UpdateLateStartFinishTime.
+// This function modifies the 'activitiesMap' in-place.
+func (s *Scheduler) UpdateFloat(activitiesMap map[int]*activity.Activity, projectFinishDate time.Time) {
+	for _, a := range activitiesMap {
+		if s.isCompleted(a) {
+			a.TotalFloat = 0
+			a.FreeFloat = 0
+			continue
+		}
+		cal := s.Calendar(a)
+		a.TotalFloat = cal.Sub(a.LateFinish, a.Finish)
+		a.FreeFloat = cal.Sub(projectFinishDate, a.Finish)
+		for _, successorId := range a.SuccessorsId {
+			succ := activitiesMap[successorId]
+			if float := linkFloat(s.Calendar(succ), a, succ, succ.Relationship(a.Id)); float < a.FreeFloat {
+				a.FreeFloat = float
+			}
+		}
+	}
+}
+
+// UpdateAsLateAsPossible delays the activities with an as late as possible constraint in the provided 'activitiesMap'
+// by their free float, so that they finish as late as possible without delaying their successors.
+// The activities are delayed in the reverse order of the 'orderActivitiesSortedByDep' slice,
+// and the float of all activities is then updated.
+// It should be called after UpdateFloat.
+// This function modifies the 'activitiesMap' in-place.
+func (s *Scheduler) UpdateAsLateAsPossible(activitiesMap map[int]*activity.Activity, orderActivitiesSortedByDep []int, projectFinishDate time.Time) {
+	delayed := false
+	for i := len(orderActivitiesSortedByDep) - 1; i >= 0; i-- {
+		a := activitiesMap[orderActivitiesSortedByDep[i]]
+		if a.ConstraintType != activity.AsLateAsPossible || s.isStarted(a) {
+			continue
+		}
+		cal := s.Calendar(a)
+		freeFloat := cal.Sub(projectFinishDate, a.Finish)
+		for _, successorId := range a.SuccessorsId {
+			succ := activitiesMap[successorId]
+			if float := linkDelay(cal, s.Calendar(succ), a, succ, succ.Relationship(a.Id)); float < freeFloat {
+				freeFloat = float
+			}
+		}
+		if freeFloat > 0 {
+			a.Start = cal.Add(a.Start, freeFloat)
+			a.Finish = cal.Add(a.Start, a.Duration)
+			delayed = true
+		}
+	}
+	if delayed {
+		s.UpdateFloat(activitiesMap, projectFinishDate)
+	}
+}
+
+// CheckConstraints returns the constraints of the activities in the provided 'activitiesMap'
+// that are not met or that cause negative float, sorted by activity id:
+// start no later than and finish no later than constraints that are passed,
+// and must start on and must finish on constraints that start the activity before its predecessors allow it.
+// It should be called after UpdateFloat.
+func (s *Scheduler) CheckConstraints(activitiesMap map[int]*activity.Activity) (violations []*ConstraintViolation) {
+	for _, a := range activitiesMap {
+		var float time.Duration
+		cal := s.Calendar(a)
+		if s.isCompleted(a) || (s.isStarted(a) && a.ConstraintType != activity.FinishNoLaterThan) {
+			continue
+		}
+		switch a.ConstraintType {
+		case activity.StartNoLaterThan:
+			float = cal.Sub(a.ConstraintDate, a.Start)
+		case activity.FinishNoLaterThan:
+			float = cal.Sub(a.ConstraintDate, a.Finish)
+		case activity.MustStartOn, activity.MustFinishOn:
+			for _, predecessorId := range a.PredecessorsId {
+				if f := linkFloat(cal, activitiesMap[predecessorId], a, a.Relationship(predecessorId)); f < float {
+					float = f
+				}
+			}
+		}
+		if float < 0 {
+			violations = append(violations, &ConstraintViolation{
+				ActivityId:     a.Id,
+				ConstraintType: a.ConstraintType,
+				ConstraintDate: a.ConstraintDate,
+				Float:          float,
+			})
+		}
+	}
+	slices.SortFunc(violations, func(v1, v2 *ConstraintViolation) int {
+		return v1.ActivityId - v2.ActivityId
+	})
+	return
+}
+
+// CriticalPath returns the critical paths of the activities in the provided 'activitiesMap',
+// each path being a slice of activity ids ordered from the start to the finish of the path.
+// A critical path goes through activities without total float, linked by relationships
+// that drive the start of the successor. The paths are returned in the order of
+// the 'orderActivitiesSortedByDep' slice, and only the first MaxCriticalPaths paths are returned,
+// as the number of paths doubles with each parallel pair of critical activities.
+// It should be called after UpdateFloat.
+func (s *Scheduler) CriticalPath(activitiesMap map[int]*activity.Activity, orderActivitiesSortedByDep []int) (paths [][]int) {
+	for _, id := range orderActivitiesSortedByDep {
+		if len(paths) == MaxCriticalPaths {
+			break
+		}
+		a := activitiesMap[id]
+		if !a.IsCritical() || s.hasDrivingPredecessor(activitiesMap, a) {
+			continue
+		}
+		paths = s.walkCriticalPath(activitiesMap, a, nil, paths)
+	}
+	return
+}
+
+// walkCriticalPath appends to 'paths' the critical paths starting at activity 'a',
+// with 'path' holding the ids of the activities already walked through,
+// until 'paths' holds MaxCriticalPaths paths.
+func (s *Scheduler) walkCriticalPath(activitiesMap map[int]*activity.Activity, a *activity.Activity, path []int, paths [][]int) [][]int {
+	path = append(path[:len(path):len(path)], a.Id)
+	walked := false
+	for _, successorId := range a.SuccessorsId {
+		if len(paths) == MaxCriticalPaths {
+			return paths
+		}
+		succ := activitiesMap[successorId]
+		if s.isDriving(a, succ) {
+			walked = true
+			paths = s.walkCriticalPath(activitiesMap, succ, path, paths)
+		}
+	}
+	if !walked {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// hasDrivingPredecessor reports whether activity 'a' has a critical predecessor driving its start.
+func (s *Scheduler) hasDrivingPredecessor(activitiesMap map[int]*activity.Activity, a *activity.Activity) bool {
+	for _, predecessorId := range a.PredecessorsId {
+		if s.isDriving(activitiesMap[predecessorId], a) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDriving reports whether both activities 'p' and 'succ' are critical
+// and the relationship from 'p' to 'succ' has no float.
+func (s *Scheduler) isDriving(p, succ *activity.Activity) bool {
+	return p.IsCritical() && succ.IsCritical() && linkFloat(s.Calendar(succ), p, succ, succ.Relationship(p.Id)) <= 0
+}
+
+// isStarted reports whether activity 'a' is started and a data date is set.
+func (s *Scheduler) isStarted(a *activity.Activity) bool {
+	return !s.DataDate.IsZero() && (a.IsStarted() || a.IsCompleted())
+}
+
+// isCompleted reports whether activity 'a' is completed and a data date is set.
+func (s *Scheduler) isCompleted(a *activity.Activity) bool {
+	return !s.DataDate.IsZero() && a.IsCompleted()
+}
+
+// remainingDuration returns the remaining duration of activity 'a' if it is started and a data date is set,
+// and its duration otherwise, as in the forward pass.
+func (s *Scheduler) remainingDuration(a *activity.Activity) time.Duration {
+	if !s.isStarted(a) {
+		return a.Duration
+	}
+	return a.RemainingDuration()
+}
+
+// earliestStart returns the earliest start time of activity 'a' with calendar 'cal'
+// allowed by its relationship 'rel' with the predecessor 'p'.
+// The lag is in working time of the activity's calendar.
+func earliestStart(cal *calendar.Calendar, a, p *activity.Activity, rel activity.Relationship) time.Time {
+	switch rel.Type {
+	case activity.StartToStart:
+		return cal.Add(p.Start, rel.Lag)
+	case activity.FinishToFinish:
+		return cal.Add(cal.Add(p.Finish, rel.Lag), -a.Duration)
+	case activity.StartToFinish:
+		return cal.Add(cal.Add(p.Start, rel.Lag), -a.Duration)
+	default:
+		return cal.Add(p.Finish, rel.Lag)
+	}
+}
+
+// latestFinish returns the latest finish time of activity 'a' with calendar 'cal' allowed by
+// the relationship 'rel' with its successor 'succ' with calendar 'succCal'.
+// The lag is in working time of the successor's calendar.
+func latestFinish(cal, succCal *calendar.Calendar, a, succ *activity.Activity, rel activity.Relationship) time.Time {
+	switch rel.Type {
+	case activity.StartToStart:
+		return cal.Add(succCal.Add(succ.LateStart, -rel.Lag), a.Duration)
+	case activity.FinishToFinish:
+		return succCal.Add(succ.LateFinish, -rel.Lag)
+	case activity.StartToFinish:
+		return cal.Add(succCal.Add(succ.LateFinish, -rel.Lag), a.Duration)
+	default:
+		return succCal.Add(succ.LateStart, -rel.Lag)
+	}
+}
+
+// linkDelay returns the working time of the calendar 'cal' of the predecessor 'p' by which 'p' can be delayed
+// without delaying the successor 'succ' with calendar 'succCal', given their relationship 'rel'.
+// Unlike linkFloat, the delay is measured in the calendar of the predecessor.
+func linkDelay(cal, succCal *calendar.Calendar, p, succ *activity.Activity, rel activity.Relationship) time.Duration {
+	switch rel.Type {
+	case activity.StartToStart:
+		return cal.Sub(succCal.Add(succ.Start, -rel.Lag), p.Start)
+	case activity.FinishToFinish:
+		return cal.Sub(succCal.Add(succ.Finish, -rel.Lag), p.Finish)
+	case activity.StartToFinish:
+		return cal.Sub(succCal.Add(succ.Finish, -rel.Lag), p.Start)
+	default:
+		return cal.Sub(succCal.Add(succ.Start, -rel.Lag), p.Finish)
+	}
+}
+
+// linkFloat returns how much the predecessor 'p' can be delayed before the relationship 'rel'
+// delays the successor 'succ', in working time of the successor's calendar 'succCal'.
+func linkFloat(succCal *calendar.Calendar, p, succ *activity.Activity, rel activity.Relationship) time.Duration {
+	switch rel.Type {
+	case activity.StartToStart:
+		return succCal.Sub(succ.Start, succCal.Add(p.Start, rel.Lag))
+	case activity.FinishToFinish:
+		return succCal.Sub(succ.Finish, succCal.Add(p.Finish, rel.Lag))
+	case activity.StartToFinish:
+		return succCal.Sub(succ.Finish, succCal.Add(p.Start, rel.Lag))
+	default:
+		return succCal.Sub(succ.Start, succCal.Add(p.Finish, rel.Lag))
+	}
+}
@@ -2,10 +2,12 @@ package timeline
 
 import (
 	"fmt"
+	"slices"
 	"testing"
 	"time"
 
 	"github.com/vanillaiice/verano/activity"
+	"github.com/vanillaiice/verano/project/calendar"
 	"github.com/vanillaiice/verano/sorter"
 	"github.com/vanillaiice/verano/util"
 )
@@ -37,3 +39,349 @@ func TestUpdateStartFinishTime(t *testing.T) {
 	}
 	fmt.Printf("Total Project Duration is %.3f days\n", activitiesMap[sortedOrder[len(sortedOrder)-1]].Finish.Sub(projectStartDate).Hours()/24)
 }
+
+func TestUpdateStartFinishTimeRelationships(t *testing.T) {
+	activities := []*activity.Activity{
+		{Id: 1, Description: "Pour concrete", Duration: 4 * time.Hour, SuccessorsId: []int{2, 3, 4}},
+		{Id: 2, Description: "Level concrete", Duration: 2 * time.Hour, PredecessorsId: []int{1}, Relationships: map[int]activity.Relationship{1: {Type: activity.StartToStart, Lag: time.Hour}}},
+		{Id: 3, Description: "Clean pump", Duration: time.Hour, PredecessorsId: []int{1}, Relationships: map[int]activity.Relationship{1: {Type: activity.FinishToFinish, Lag: 30 * time.Minute}}},
+		{Id: 4, Description: "Cure concrete", Duration: 8 * time.Hour, PredecessorsId: []int{1}, Relationships: map[int]activity.Relationship{1: {Type: activity.FinishToStart, Lag: -time.Hour}}},
+		{Id: 5, Description: "Order pump", Duration: 2 * time.Hour, PredecessorsId: []int{1}, Relationships: map[int]activity.Relationship{1: {Type: activity.StartToFinish, Lag: 3 * time.Hour}}},
+	}
+	activities[0].SuccessorsId = append(activities[0].SuccessorsId, 5)
+	activitiesMap := util.ActivitiesToMap(activities)
+	activitiesGraph, err := util.ActivitiesToGraph(activities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	projectStartDate := time.Date(2024, time.January, 4, 8, 0, 0, 0, time.UTC)
+	UpdateStartFinishTime(activitiesMap, sorter.SortActivitiesByDeps(activitiesGraph), projectStartDate)
+
+	want := map[int]time.Time{
+		1: projectStartDate,
+		2: projectStartDate.Add(time.Hour),
+		3: projectStartDate.Add(3*time.Hour + 30*time.Minute),
+		4: projectStartDate.Add(3 * time.Hour),
+		5: projectStartDate.Add(time.Hour),
+	}
+	for id, start := range want {
+		a := activitiesMap[id]
+		if !a.Start.Equal(start) {
+			t.Errorf("activity %d: start got %v, want %v", id, a.Start, start)
+		}
+		if !a.Finish.Equal(start.Add(a.Duration)) {
+			t.Errorf("activity %d: finish got %v, want %v", id, a.Finish, start.Add(a.Duration))
+		}
+	}
+}
+
+func TestUpdateFloatAndCriticalPath(t *testing.T) {
+	activities := []*activity.Activity{
+		{Id: 1, Description: "Dig", Duration: 2 * time.Hour, SuccessorsId: []int{2, 3, 5}},
+		{Id: 2, Description: "Pour", Duration: 4 * time.Hour, PredecessorsId: []int{1}, SuccessorsId: []int{4}},
+		{Id: 3, Description: "Order", Duration: time.Hour, PredecessorsId: []int{1}, SuccessorsId: []int{4}},
+		{Id: 4, Description: "Build", Duration: 3 * time.Hour, PredecessorsId: []int{2, 3, 5}},
+		{Id: 5, Description: "Rebar", Duration: 4 * time.Hour, PredecessorsId: []int{1}, SuccessorsId: []int{4}},
+	}
+	activitiesMap := util.ActivitiesToMap(activities)
+	activitiesGraph, err := util.ActivitiesToGraph(activities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	projectStartDate := time.Date(2024, time.January, 4, 8, 0, 0, 0, time.UTC)
+	order := sorter.SortActivitiesByDeps(activitiesGraph)
+
+	UpdateStartFinishTime(activitiesMap, order, projectStartDate)
+	projectFinishDate := ProjectFinishDate(activitiesMap)
+	if want := projectStartDate.Add(9 * time.Hour); !projectFinishDate.Equal(want) {
+		t.Fatalf("project finish got %v, want %v", projectFinishDate, want)
+	}
+	UpdateLateStartFinishTime(activitiesMap, order, projectFinishDate)
+	UpdateFloat(activitiesMap, projectFinishDate)
+
+	wantLateStart := map[int]time.Duration{1: 0, 2: 2 * time.Hour, 3: 5 * time.Hour, 4: 6 * time.Hour, 5: 2 * time.Hour}
+	wantTotalFloat := map[int]time.Duration{1: 0, 2: 0, 3: 3 * time.Hour, 4: 0, 5: 0}
+	for id, a := range activitiesMap {
+		if want := projectStartDate.Add(wantLateStart[id]); !a.LateStart.Equal(want) {
+			t.Errorf("activity %d: late start got %v, want %v", id, a.LateStart, want)
+		}
+		if a.TotalFloat != wantTotalFloat[id] {
+			t.Errorf("activity %d: total float got %v, want %v", id, a.TotalFloat, wantTotalFloat[id])
+		}
+		if a.FreeFloat != wantTotalFloat[id] {
+			t.Errorf("activity %d: free float got %v, want %v", id, a.FreeFloat, wantTotalFloat[id])
+		}
+	}
+
+	paths := CriticalPath(activitiesMap, order)
+	want := [][]int{{1, 2, 4}, {1, 5, 4}}
+	if len(paths) != len(want) {
+		t.Fatalf("critical paths got %v, want %v", paths, want)
+	}
+	for i := range want {
+		if slices.Compare(paths[i], want[i]) != 0 {
+			t.Errorf("critical path got %v, want %v", paths[i], want[i])
+		}
+	}
+}
+
+func TestCriticalPathStackedDiamonds(t *testing.T) {
+	// 40 diamonds of critical activities stacked one after the other, 2^40 critical paths
+	const diamonds = 40
+	activities := []*activity.Activity{{Id: 1, Duration: time.Hour}}
+	for i := 0; i < diamonds; i++ {
+		join := 3*i + 1
+		left := &activity.Activity{Id: join + 1, Duration: time.Hour, PredecessorsId: []int{join}, SuccessorsId: []int{join + 3}}
+		right := &activity.Activity{Id: join + 2, Duration: time.Hour, PredecessorsId: []int{join}, SuccessorsId: []int{join + 3}}
+		activities[len(activities)-1].SuccessorsId = []int{join + 1, join + 2}
+		activities = append(activities, left, right, &activity.Activity{Id: join + 3, Duration: time.Hour, PredecessorsId: []int{join + 1, join + 2}})
+	}
+	activitiesMap := util.ActivitiesToMap(activities)
+	activitiesGraph, err := util.ActivitiesToGraph(activities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	projectStartDate := time.Date(2024, time.January, 4, 8, 0, 0, 0, time.UTC)
+	order := sorter.SortActivitiesByDeps(activitiesGraph)
+	UpdateStartFinishTime(activitiesMap, order, projectStartDate)
+	projectFinishDate := ProjectFinishDate(activitiesMap)
+	UpdateLateStartFinishTime(activitiesMap, order, projectFinishDate)
+	UpdateFloat(activitiesMap, projectFinishDate)
+
+	paths := CriticalPath(activitiesMap, order)
+	if len(paths) != MaxCriticalPaths {
+		t.Fatalf("got %d critical paths, want %d", len(paths), MaxCriticalPaths)
+	}
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		if len(path) != 2*diamonds+1 || path[0] != 1 || path[len(path)-1] != 3*diamonds+1 {
+			t.Errorf("critical path got %v, want a path from 1 to %d", path, 3*diamonds+1)
+		}
+		key := fmt.Sprint(path)
+		if seen[key] {
+			t.Errorf("critical path %v returned twice", path)
+		}
+		seen[key] = true
+	}
+}
+
+func TestUpdateFloatFreeFloat(t *testing.T) {
+	activities := []*activity.Activity{
+		{Id: 1, Description: "Survey", Duration: time.Hour, SuccessorsId: []int{2}},
+		{Id: 2, Description: "Report", Duration: time.Hour, PredecessorsId: []int{1}, SuccessorsId: []int{4}},
+		{Id: 3, Description: "Design", Duration: 5 * time.Hour, SuccessorsId: []int{4}},
+		{Id: 4, Description: "Approve", Duration: time.Hour, PredecessorsId: []int{2, 3}},
+	}
+	activitiesMap := util.ActivitiesToMap(activities)
+	activitiesGraph, err := util.ActivitiesToGraph(activities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	projectStartDate := time.Date(2024, time.January, 4, 8, 0, 0, 0, time.UTC)
+	order := sorter.SortActivitiesByDeps(activitiesGraph)
+	UpdateStartFinishTime(activitiesMap, order, projectStartDate)
+	projectFinishDate := ProjectFinishDate(activitiesMap)
+	UpdateLateStartFinishTime(activitiesMap, order, projectFinishDate)
+	UpdateFloat(activitiesMap, projectFinishDate)
+
+	// the survey can slip 3 hours without delaying the project, but it delays the report right away
+	if a := activitiesMap[1]; a.TotalFloat != 3*time.Hour || a.FreeFloat != 0 {
+		t.Errorf("activity 1: got total float %v and free float %v, want %v and %v", a.TotalFloat, a.FreeFloat, 3*time.Hour, time.Duration(0))
+	}
+	if a := activitiesMap[2]; a.TotalFloat != 3*time.Hour || a.FreeFloat != 3*time.Hour {
+		t.Errorf("activity 2: got total float %v and free float %v, want %v and %v", a.TotalFloat, a.FreeFloat, 3*time.Hour, 3*time.Hour)
+	}
+}
+
+func TestSchedulerCalendars(t *testing.T) {
+	activities := []*activity.Activity{
+		{Id: 1, Description: "Weld frame", Duration: 16 * time.Hour, CalendarId: 1, SuccessorsId: []int{2}},
+		{Id: 2, Description: "Cool down", Duration: 2 * time.Hour, CalendarId: 2, PredecessorsId: []int{1}, SuccessorsId: []int{3}},
+		{Id: 3, Description: "Paint frame", Duration: time.Hour, PredecessorsId: []int{2}},
+	}
+	standard := calendar.New(1, "standard")
+	standard.Default = true
+	continuous := &calendar.Calendar{Id: 2, Name: "continuous"}
+	s := NewScheduler([]*calendar.Calendar{standard, continuous})
+	if s.Calendar(activities[2]) != standard {
+		t.Errorf("got %v, want the default calendar", s.Calendar(activities[2]))
+	}
+
+	activitiesMap := util.ActivitiesToMap(activities)
+	activitiesGraph, err := util.ActivitiesToGraph(activities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := sorter.SortActivitiesByDeps(activitiesGraph)
+	// Friday 5 January 2024, 16:00
+	friday := time.Date(2024, time.January, 5, 0, 0, 0, 0, time.UTC)
+	s.UpdateStartFinishTime(activitiesMap, order, friday.Add(16*time.Hour))
+
+	tuesday := friday.Add(4 * 24 * time.Hour)
+	want := map[int][2]time.Time{
+		1: {friday.Add(16 * time.Hour), tuesday.Add(16 * time.Hour)},
+		2: {tuesday.Add(16 * time.Hour), tuesday.Add(18 * time.Hour)},
+		3: {tuesday.Add(32 * time.Hour), tuesday.Add(33 * time.Hour)},
+	}
+	for id, w := range want {
+		a := activitiesMap[id]
+		if !a.Start.Equal(w[0]) || !a.Finish.Equal(w[1]) {
+			t.Errorf("activity %d: got %v - %v, want %v - %v", id, a.Start, a.Finish, w[0], w[1])
+		}
+	}
+
+	projectFinishDate := ProjectFinishDate(activitiesMap)
+	s.UpdateLateStartFinishTime(activitiesMap, order, projectFinishDate)
+	s.UpdateFloat(activitiesMap, projectFinishDate)
+
+	// the frame can be finished at the end of Tuesday and cool down during the night
+	if a := activitiesMap[1]; !a.LateFinish.Equal(tuesday.Add(17*time.Hour)) || a.TotalFloat != time.Hour {
+		t.Errorf("activity 1: got late finish %v and total float %v, want %v and %v", a.LateFinish, a.TotalFloat, tuesday.Add(17*time.Hour), time.Hour)
+	}
+	if a := activitiesMap[2]; a.TotalFloat != 14*time.Hour {
+		t.Errorf("activity 2: got total float %v, want %v", a.TotalFloat, 14*time.Hour)
+	}
+	if a := activitiesMap[3]; a.TotalFloat != 0 {
+		t.Errorf("activity 3: got total float %v, want %v", a.TotalFloat, time.Duration(0))
+	}
+}
+
+func TestScheduleConstraints(t *testing.T) {
+	projectStartDate := time.Date(2024, time.January, 4, 8, 0, 0, 0, time.UTC)
+	activities := []*activity.Activity{
+		{Id: 1, Description: "Get permit", Duration: 4 * time.Hour, SuccessorsId: []int{2, 3}},
+		{Id: 2, Description: "Dig", Duration: 2 * time.Hour, PredecessorsId: []int{1}, SuccessorsId: []int{4}, ConstraintType: activity.FinishNoLaterThan, ConstraintDate: projectStartDate.Add(5 * time.Hour)},
+		{Id: 3, Description: "Survey", Duration: time.Hour, PredecessorsId: []int{1}, ConstraintType: activity.MustStartOn, ConstraintDate: projectStartDate.Add(2 * time.Hour)},
+		{Id: 4, Description: "Build", Duration: time.Hour, PredecessorsId: []int{2}, ConstraintType: activity.StartNoEarlierThan, ConstraintDate: projectStartDate.Add(8 * time.Hour)},
+		{Id: 5, Description: "Inspect", Duration: time.Hour, ConstraintType: activity.AsLateAsPossible},
+	}
+	activitiesMap := util.ActivitiesToMap(activities)
+	activitiesGraph, err := util.ActivitiesToGraph(activities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	violations := Schedule(activitiesMap, sorter.SortActivitiesByDeps(activitiesGraph), projectStartDate)
+
+	if a := activitiesMap[3]; !a.Start.Equal(projectStartDate.Add(2 * time.Hour)) {
+		t.Errorf("activity 3: got start %v, want %v", a.Start, projectStartDate.Add(2*time.Hour))
+	}
+	if a := activitiesMap[4]; !a.Start.Equal(projectStartDate.Add(8 * time.Hour)) {
+		t.Errorf("activity 4: got start %v, want %v", a.Start, projectStartDate.Add(8*time.Hour))
+	}
+	if a := activitiesMap[5]; !a.Finish.Equal(projectStartDate.Add(9 * time.Hour)) {
+		t.Errorf("activity 5: got finish %v, want %v", a.Finish, projectStartDate.Add(9*time.Hour))
+	}
+	if a := activitiesMap[2]; a.TotalFloat != -time.Hour {
+		t.Errorf("activity 2: got total float %v, want %v", a.TotalFloat, -time.Hour)
+	}
+
+	want := []ConstraintViolation{
+		{ActivityId: 2, ConstraintType: activity.FinishNoLaterThan, ConstraintDate: activities[1].ConstraintDate, Float: -time.Hour},
+		{ActivityId: 3, ConstraintType: activity.MustStartOn, ConstraintDate: activities[2].ConstraintDate, Float: -2 * time.Hour},
+	}
+	if len(violations) != len(want) {
+		t.Fatalf("got %v, want %v", violations, want)
+	}
+	for i := range want {
+		if *violations[i] != want[i] {
+			t.Errorf("got %v, want %v", violations[i], want[i])
+		}
+	}
+}
+
+func TestUpdateAsLateAsPossible(t *testing.T) {
+	activities := []*activity.Activity{
+		{Id: 1, Description: "Order paint", Duration: time.Hour, SuccessorsId: []int{3}, ConstraintType: activity.AsLateAsPossible},
+		{Id: 2, Description: "Sand walls", Duration: 5 * time.Hour, SuccessorsId: []int{3}},
+		{Id: 3, Description: "Paint walls", Duration: 2 * time.Hour, PredecessorsId: []int{1, 2}},
+	}
+	activitiesMap := util.ActivitiesToMap(activities)
+	activitiesGraph, err := util.ActivitiesToGraph(activities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	projectStartDate := time.Date(2024, time.January, 4, 8, 0, 0, 0, time.UTC)
+	if violations := Schedule(activitiesMap, sorter.SortActivitiesByDeps(activitiesGraph), projectStartDate); len(violations) != 0 {
+		t.Errorf("got %v, want no violations", violations)
+	}
+
+	if a := activitiesMap[1]; !a.Start.Equal(projectStartDate.Add(4*time.Hour)) || a.TotalFloat != 0 || a.FreeFloat != 0 {
+		t.Errorf("activity 1: got start %v, total float %v and free float %v, want %v, %v and %v", a.Start, a.TotalFloat, a.FreeFloat, projectStartDate.Add(4*time.Hour), time.Duration(0), time.Duration(0))
+	}
+}
+
+func TestUpdateAsLateAsPossibleCalendars(t *testing.T) {
+	activities := []*activity.Activity{
+		{Id: 1, Description: "Order paint", Duration: time.Hour, SuccessorsId: []int{3}, ConstraintType: activity.AsLateAsPossible},
+		{Id: 2, Description: "Cure primer", Duration: 24 * time.Hour, CalendarId: 2, SuccessorsId: []int{3}},
+		{Id: 3, Description: "Paint walls", Duration: time.Hour, CalendarId: 2, PredecessorsId: []int{1, 2}},
+	}
+	standard := calendar.New(1, "standard")
+	standard.Default = true
+	continuous := &calendar.Calendar{Id: 2, Name: "continuous"}
+	s := NewScheduler([]*calendar.Calendar{standard, continuous})
+
+	activitiesMap := util.ActivitiesToMap(activities)
+	activitiesGraph, err := util.ActivitiesToGraph(activities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Thursday 4 January 2024, 8:00
+	thursday := time.Date(2024, time.January, 4, 0, 0, 0, 0, time.UTC)
+	if violations := s.Schedule(activitiesMap, sorter.SortActivitiesByDeps(activitiesGraph), thursday.Add(8*time.Hour)); len(violations) != 0 {
+		t.Errorf("got %v, want no violations", violations)
+	}
+
+	// the painting starts on Friday at 8:00, the paint is ordered at the end of Thursday
+	if a := activitiesMap[1]; !a.Start.Equal(thursday.Add(16*time.Hour)) || !a.Finish.Equal(thursday.Add(17*time.Hour)) {
+		t.Errorf("activity 1: got %v - %v, want %v - %v", a.Start, a.Finish, thursday.Add(16*time.Hour), thursday.Add(17*time.Hour))
+	}
+}
+
+func TestSchedulerDataDate(t *testing.T) {
+	projectStartDate := time.Date(2024, time.January, 1, 8, 0, 0, 0, time.UTC)
+	activities := []*activity.Activity{
+		{Id: 1, Description: "Dig trench", Duration: 4 * time.Hour, SuccessorsId: []int{2}, Progress: 1, ActualStart: projectStartDate, ActualFinish: projectStartDate.Add(5 * time.Hour)},
+		{Id: 2, Description: "Lay pipe", Duration: 4 * time.Hour, PredecessorsId: []int{1}, SuccessorsId: []int{3}, Progress: 0.25, ActualStart: projectStartDate.Add(5 * time.Hour)},
+		{Id: 3, Description: "Backfill trench", Duration: 2 * time.Hour, PredecessorsId: []int{2}},
+		{Id: 4, Description: "Order signs", Duration: time.Hour},
+		// progress without an actual start does not make the activity started
+		{Id: 5, Description: "Paint markings", Duration: 4 * time.Hour, Progress: 0.5},
+	}
+	activitiesMap := util.ActivitiesToMap(activities)
+	activitiesGraph, err := util.ActivitiesToGraph(activities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Scheduler{DataDate: projectStartDate.Add(7 * time.Hour)}
+	if violations := s.Schedule(activitiesMap, sorter.SortActivitiesByDeps(activitiesGraph), projectStartDate); len(violations) != 0 {
+		t.Errorf("got %v, want no violations", violations)
+	}
+
+	tests := []struct {
+		id            int
+		start, finish time.Time
+	}{
+		{1, projectStartDate, projectStartDate.Add(5 * time.Hour)},
+		{2, projectStartDate.Add(5 * time.Hour), projectStartDate.Add(10 * time.Hour)},
+		{3, projectStartDate.Add(10 * time.Hour), projectStartDate.Add(12 * time.Hour)},
+		{4, projectStartDate.Add(7 * time.Hour), projectStartDate.Add(8 * time.Hour)},
+		{5, projectStartDate.Add(7 * time.Hour), projectStartDate.Add(11 * time.Hour)},
+	}
+	for _, test := range tests {
+		a := activitiesMap[test.id]
+		if !a.Start.Equal(test.start) || !a.Finish.Equal(test.finish) {
+			t.Errorf("activity %d: got %v - %v, want %v - %v", test.id, a.Start, a.Finish, test.start, test.finish)
+		}
+	}
+
+	if activitiesMap[1].IsCritical() || !activitiesMap[2].IsCritical() || !activitiesMap[3].IsCritical() {
+		t.Error("expected activities 2 and 3 to be the only critical activities")
+	}
+	if got, want := activitiesMap[2].LateStart, projectStartDate.Add(7*time.Hour); !got.Equal(want) {
+		t.Errorf("activity 2 late start: got %v, want %v", got, want)
+	}
+	if a := activitiesMap[5]; !a.LateStart.Equal(projectStartDate.Add(8*time.Hour)) || a.TotalFloat != time.Hour {
+		t.Errorf("activity 5: got late start %v and total float %v, want %v and %v", a.LateStart, a.TotalFloat, projectStartDate.Add(8*time.Hour), time.Hour)
+	}
+}
@@ -22,8 +22,8 @@ func (pv *visitor) Visit(v dag.Vertexer) {
 // based on their dependencies and returns a slice representing the sorted order.
 // It uses a topological sorting algorithm to treaverse the activities and create the topological order.
 // The resulting order ensures that activities with dependencies come before their dependent activities.
-// It should be noted that the relationship between the activities
-// is assumed to be start to finish.
+// It should be noted that only the precedence between the activities is considered,
+// the type and lag of the relationships are handled when computing the timeline.
 func SortActivitiesByDeps(graph *dag.DAG) []int {
 	v := &visitor{}
 	graph.OrderedWalk(v)
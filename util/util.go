@@ -2,8 +2,10 @@ package util
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/heimdalr/dag"
 	"github.com/vanillaiice/verano/activity"
@@ -21,6 +23,8 @@ func ActivitiesToMap(activities []*activity.Activity) (activitiesMap map[int]*ac
 
 // ActivitiesToGraph converts a slice of 'activities' into a directed acyclic graph (DAG).
 // It creates a new DAG, adds vertices for each activity, and establishes edges based on the successors' IDs.
+// The edges only carry the precedence, the type and lag of the relationships are kept in the activities.
+// An error is returned if the activities contain a logic loop, validate.Cycles can be used to list the loops.
 func ActivitiesToGraph(activities []*activity.Activity) (g *dag.DAG, err error) {
 	g = dag.NewDAG()
 	for _, act := range activities {
@@ -58,3 +62,82 @@ func Unflat(s string) (intVals []int, err error) {
 	}
 	return
 }
+
+// wbsEscaper escapes the dots and backslashes of the codes of work breakdown structure elements.
+var wbsEscaper = strings.NewReplacer(`\`, `\\`, ".", `\.`)
+
+// FlatWbs converts the codes of the elements of a work breakdown structure, from the root, into a dot-separated path
+// (e.g. "1.2.3"). The dots and backslashes of the codes are escaped with a backslash (e.g. "1.2\.1" for the codes "1" and "2.1").
+func FlatWbs(codes []string) string {
+	escaped := make([]string, len(codes))
+	for i, code := range codes {
+		escaped[i] = wbsEscaper.Replace(code)
+	}
+	return strings.Join(escaped, ".")
+}
+
+// UnflatWbs converts a dot-separated path of a work breakdown structure element into the codes of the elements
+// from the root, the dots and backslashes escaped with a backslash belonging to the codes.
+func UnflatWbs(path string) (codes []string) {
+	if path == "" {
+		return
+	}
+	var code strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path):
+			i++
+			code.WriteByte(path[i])
+		case path[i] == '.':
+			codes = append(codes, code.String())
+			code.Reset()
+		default:
+			code.WriteByte(path[i])
+		}
+	}
+	return append(codes, code.String())
+}
+
+// FlatRelationships converts a map of relationships keyed by predecessor id into a comma-separated string,
+// where each relationship is written as 'predecessorId:type:lag' (e.g. "2:SS:1h0m0s,3:FF:-30m0s").
+func FlatRelationships(rels map[int]activity.Relationship) string {
+	ids := make([]int, 0, len(rels))
+	for id := range rels {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	sRels := make([]string, 0, len(ids))
+	for _, id := range ids {
+		sRels = append(sRels, fmt.Sprintf("%d:%s:%s", id, rels[id].Type, rels[id].Lag))
+	}
+	return strings.Join(sRels, ",")
+}
+
+// UnflatRelationships converts a comma-separated string of relationships written as 'predecessorId:type:lag'
+// into a map of relationships keyed by predecessor id.
+func UnflatRelationships(s string) (rels map[int]activity.Relationship, err error) {
+	if s == "" {
+		return rels, nil
+	}
+	rels = make(map[int]activity.Relationship)
+	for _, sRel := range strings.Split(s, ",") {
+		fields := strings.Split(strings.TrimSpace(sRel), ":")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("malformed relationship %q", sRel)
+		}
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		relType, err := activity.ParseRelationshipType(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		lag, err := time.ParseDuration(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		rels[id] = activity.Relationship{Type: relType, Lag: lag}
+	}
+	return
+}
@@ -71,3 +71,53 @@ func TestUnflat(t *testing.T) {
 		t.Errorf("Error, want %v, got %v", want, sunflat)
 	}
 }
+
+func TestFlatWbs(t *testing.T) {
+	got := FlatWbs([]string{"1", "2.1", `a\b`})
+	if want := `1.2\.1.a\\b`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestUnflatWbs(t *testing.T) {
+	got := UnflatWbs(`1.2\.1.a\\b`)
+	if want := []string{"1", "2.1", `a\b`}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got = UnflatWbs(""); got != nil {
+		t.Errorf("got %q, want no codes", got)
+	}
+}
+
+func TestFlatRelationships(t *testing.T) {
+	rels := map[int]activity.Relationship{
+		3: {Type: activity.FinishToFinish, Lag: -30 * time.Minute},
+		2: {Type: activity.StartToStart, Lag: time.Hour},
+	}
+	want := "2:SS:1h0m0s,3:FF:-30m0s"
+	if s := FlatRelationships(rels); s != want {
+		t.Errorf("got %s, want %s", s, want)
+	}
+}
+
+func TestUnflatRelationships(t *testing.T) {
+	rels, err := UnflatRelationships("2:SS:1h0m0s, 3:FF:-30m0s")
+	if err != nil {
+		t.Error(err)
+	}
+	want := map[int]activity.Relationship{
+		2: {Type: activity.StartToStart, Lag: time.Hour},
+		3: {Type: activity.FinishToFinish, Lag: -30 * time.Minute},
+	}
+	if len(rels) != len(want) {
+		t.Errorf("got %v, want %v", rels, want)
+	}
+	for k, v := range want {
+		if rels[k] != v {
+			t.Errorf("got %v, want %v", rels[k], v)
+		}
+	}
+	if _, err = UnflatRelationships("2:SS"); err == nil {
+		t.Error("expected UnflatRelationships to fail")
+	}
+}
@@ -0,0 +1,219 @@
+package validate
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/vanillaiice/verano/activity"
+)
+
+// Cycle is a logic loop between activities. The activities are listed in order of precedence,
+// starting with the activity with the smallest id, the last activity being a predecessor of the first one.
+type Cycle struct {
+	Ids          []int    // IDs of the activities in the loop
+	Descriptions []string // Descriptions of the activities in the loop
+}
+
+// String returns the cycle as a chain of activities, ending with the first activity of the loop
+// (e.g. "1 (Dig trench) -> 2 (Lay pipe) -> 1 (Dig trench)").
+func (c Cycle) String() string {
+	steps := make([]string, 0, len(c.Ids)+1)
+	for i := range c.Ids {
+		steps = append(steps, fmt.Sprintf("%d (%s)", c.Ids[i], c.Descriptions[i]))
+	}
+	if len(steps) > 0 {
+		steps = append(steps, steps[0])
+	}
+	return strings.Join(steps, " -> ")
+}
+
+// CycleError is returned when activities contain one or more logic loops.
+type CycleError struct {
+	Cycles []Cycle // Cycles found in the activities
+}
+
+// Error lists the cycles, one per line.
+func (e *CycleError) Error() string {
+	lines := make([]string, 0, len(e.Cycles))
+	for _, c := range e.Cycles {
+		lines = append(lines, c.String())
+	}
+	return fmt.Sprintf("found %d cycle(s) between activities:\n%s", len(e.Cycles), strings.Join(lines, "\n"))
+}
+
+// Acyclic returns a *CycleError listing every cycle of the provided 'activities',
+// or nil if the activities contain no logic loop.
+func Acyclic(activities []*activity.Activity) error {
+	cycles := Cycles(activities)
+	if len(cycles) == 0 {
+		return nil
+	}
+	return &CycleError{Cycles: cycles}
+}
+
+// Cycles returns every elementary cycle of the provided 'activities', so that all the loops can be fixed at once.
+// A link between two activities is considered if it is recorded in the predecessors of the successor,
+// or in the successors of the predecessor. Links to activities that are not in 'activities' are ignored.
+// The cycles are sorted by their first activity id, and found using Johnson's algorithm.
+func Cycles(activities []*activity.Activity) (cycles []Cycle) {
+	activitiesMap := make(map[int]*activity.Activity)
+	for _, a := range activities {
+		activitiesMap[a.Id] = a
+	}
+	successors := successorsGraph(activitiesMap)
+
+	ids := make([]int, 0, len(activitiesMap))
+	for id := range activitiesMap {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	for _, s := range ids {
+		component := componentOf(s, successors)
+		if component == nil {
+			continue
+		}
+		j := &johnson{
+			start:      s,
+			successors: successors,
+			component:  component,
+			blocked:    make(map[int]bool),
+			blockedBy:  make(map[int][]int),
+		}
+		j.circuit(s)
+		for _, loop := range j.loops {
+			cycle := Cycle{Ids: loop}
+			for _, id := range loop {
+				cycle.Descriptions = append(cycle.Descriptions, activitiesMap[id].Description)
+			}
+			cycles = append(cycles, cycle)
+		}
+	}
+
+	return
+}
+
+// successorsGraph returns the sorted successors of each activity of 'activitiesMap',
+// built from both the predecessors and the successors lists.
+func successorsGraph(activitiesMap map[int]*activity.Activity) (successors map[int][]int) {
+	successors = make(map[int][]int)
+	link := func(from, to int) {
+		if _, ok := activitiesMap[from]; !ok {
+			return
+		}
+		if _, ok := activitiesMap[to]; !ok {
+			return
+		}
+		if !slices.Contains(successors[from], to) {
+			successors[from] = append(successors[from], to)
+		}
+	}
+	for _, a := range activitiesMap {
+		for _, id := range a.SuccessorsId {
+			link(a.Id, id)
+		}
+		for _, id := range a.PredecessorsId {
+			link(id, a.Id)
+		}
+	}
+	for id := range successors {
+		slices.Sort(successors[id])
+	}
+	return
+}
+
+// componentOf returns the strongly connected component containing 's' in the subgraph of the
+// activities with an id greater than or equal to 's', or nil if 's' is not part of any cycle in it.
+func componentOf(s int, successors map[int][]int) (component map[int]bool) {
+	// the component is made of the activities reachable from 's' that can also reach 's'
+	forward := reach(s, func(id int) []int { return successors[id] }, s)
+	predecessors := make(map[int][]int)
+	for from, tos := range successors {
+		for _, to := range tos {
+			predecessors[to] = append(predecessors[to], from)
+		}
+	}
+	backward := reach(s, func(id int) []int { return predecessors[id] }, s)
+
+	component = make(map[int]bool)
+	for id := range forward {
+		if backward[id] {
+			component[id] = true
+		}
+	}
+	if len(component) == 1 && !slices.Contains(successors[s], s) {
+		return nil
+	}
+	return
+}
+
+// reach returns the activities reachable from 'from' by following 'next',
+// ignoring activities with an id smaller than 'min'.
+func reach(from int, next func(int) []int, min int) (reached map[int]bool) {
+	reached = map[int]bool{from: true}
+	stack := []int{from}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, n := range next(id) {
+			if n < min || reached[n] {
+				continue
+			}
+			reached[n] = true
+			stack = append(stack, n)
+		}
+	}
+	return
+}
+
+// johnson holds the state of the search for the cycles starting at 'start' within 'component'.
+type johnson struct {
+	start      int
+	successors map[int][]int
+	component  map[int]bool
+	blocked    map[int]bool
+	blockedBy  map[int][]int
+	path       []int
+	loops      [][]int
+}
+
+// circuit searches for the cycles going through 'v', and reports whether one was found.
+func (j *johnson) circuit(v int) (found bool) {
+	j.path = append(j.path, v)
+	j.blocked[v] = true
+	for _, w := range j.successors[v] {
+		if !j.component[w] {
+			continue
+		}
+		if w == j.start {
+			j.loops = append(j.loops, slices.Clone(j.path))
+			found = true
+		} else if !j.blocked[w] && j.circuit(w) {
+			found = true
+		}
+	}
+	if found {
+		j.unblock(v)
+	} else {
+		for _, w := range j.successors[v] {
+			if j.component[w] && !slices.Contains(j.blockedBy[w], v) {
+				j.blockedBy[w] = append(j.blockedBy[w], v)
+			}
+		}
+	}
+	j.path = j.path[:len(j.path)-1]
+	return
+}
+
+// unblock unblocks 'v' and the activities that were blocked because of it.
+func (j *johnson) unblock(v int) {
+	j.blocked[v] = false
+	blockedBy := j.blockedBy[v]
+	delete(j.blockedBy, v)
+	for _, w := range blockedBy {
+		if j.blocked[w] {
+			j.unblock(w)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package validate
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/vanillaiice/verano/activity"
+)
+
+func TestCycles(t *testing.T) {
+	activities := []*activity.Activity{
+		{Id: 1, Description: "Dig trench", Duration: time.Hour, PredecessorsId: []int{3}, SuccessorsId: []int{2}},
+		{Id: 2, Description: "Lay pipe", Duration: time.Hour, PredecessorsId: []int{1, 4}, SuccessorsId: []int{3, 4}},
+		{Id: 3, Description: "Backfill trench", Duration: time.Hour, PredecessorsId: []int{2}},
+		{Id: 4, Description: "Test pipe", Duration: time.Hour, PredecessorsId: []int{2}, SuccessorsId: []int{2}},
+		{Id: 5, Description: "Order signs", Duration: time.Hour, PredecessorsId: []int{5}, SuccessorsId: []int{5}},
+		{Id: 6, Description: "Install signs", Duration: time.Hour, SuccessorsId: []int{7}},
+		{Id: 7, Description: "Open road", Duration: time.Hour, PredecessorsId: []int{6}, SuccessorsId: []int{99}},
+	}
+
+	cycles := Cycles(activities)
+	want := [][]int{{1, 2, 3}, {2, 4}, {5}}
+	if len(cycles) != len(want) {
+		t.Fatalf("got %v, want %v", cycles, want)
+	}
+	for i := range want {
+		if !slices.Equal(cycles[i].Ids, want[i]) {
+			t.Errorf("got %v, want %v", cycles[i].Ids, want[i])
+		}
+	}
+
+	if got, want := cycles[0].String(), "1 (Dig trench) -> 2 (Lay pipe) -> 3 (Backfill trench) -> 1 (Dig trench)"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	err := Acyclic(activities)
+	var cycleErr *CycleError
+	if !errors.As(err, &cycleErr) || len(cycleErr.Cycles) != 3 {
+		t.Errorf("got %v, want a cycle error with %d cycles", err, 3)
+	}
+
+	if err = Acyclic(activities[5:]); err != nil {
+		t.Errorf("got %v, want no error", err)
+	}
+}
+
+func TestCyclesAll(t *testing.T) {
+	// every pair of activities is linked in both directions, so every sequence of distinct activities is a loop
+	var activities []*activity.Activity
+	for i := 1; i <= 4; i++ {
+		a := &activity.Activity{Id: i}
+		for j := 1; j <= 4; j++ {
+			if j != i {
+				a.SuccessorsId = append(a.SuccessorsId, j)
+			}
+		}
+		activities = append(activities, a)
+	}
+	// 6 loops of two activities, 8 of three and 6 of four
+	if got, want := len(Cycles(activities)), 20; got != want {
+		t.Errorf("got %d cycles, want %d", got, want)
+	}
+}
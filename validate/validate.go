@@ -0,0 +1,184 @@
+package validate
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/vanillaiice/verano/activity"
+)
+
+// Severity defines how serious a finding is.
+type Severity int
+
+// Enumeration of available severities.
+const (
+	Warning Severity = 0 // The activities can be scheduled, but the schedule is probably incomplete
+	Error   Severity = 1 // The activities cannot be scheduled, or the schedule would be wrong
+)
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Kind defines the problem described by a finding.
+type Kind int
+
+// Enumeration of available kinds of findings.
+const (
+	DuplicateId        Kind = 0 // Several activities have the same id
+	MissingPredecessor Kind = 1 // A predecessor of the activity does not exist
+	MissingSuccessor   Kind = 2 // A successor of the activity does not exist
+	InconsistentLink   Kind = 3 // A link is recorded in the predecessors of the successor or in the successors of the predecessor, but not in both
+	SelfLink           Kind = 4 // The activity is its own predecessor or successor
+	NegativeDuration   Kind = 5 // The duration of the activity is negative
+	InvalidProgress    Kind = 6 // The progress of the activity is not between 0 and 1
+	OpenStart          Kind = 7 // The activity has no predecessor
+	OpenFinish         Kind = 8 // The activity has no successor
+	LogicLoop          Kind = 9 // The activity is part of a cycle
+)
+
+var kindNames = []string{
+	"duplicate id",
+	"missing predecessor",
+	"missing successor",
+	"inconsistent link",
+	"self link",
+	"negative duration",
+	"invalid progress",
+	"open start",
+	"open finish",
+	"logic loop",
+}
+
+// String returns the name of the kind of finding.
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+	return kindNames[k]
+}
+
+// Finding is a problem found in a set of activities.
+type Finding struct {
+	Kind       Kind     // Kind of the problem
+	Severity   Severity // Severity of the problem
+	ActivityId int      // ID of the activity with the problem
+	RelatedId  int      // ID of the other activity involved in the problem (0 if none)
+	Message    string   // Human readable description of the problem
+}
+
+// String returns the finding in a human readable form (e.g. "error: activity 2: missing predecessor 9").
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: activity %d: %s", f.Severity, f.ActivityId, f.Message)
+}
+
+// HasErrors reports whether any of the 'findings' has the Error severity.
+func HasErrors(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Check checks the integrity of the provided 'activities' before they are scheduled, and returns the problems found.
+// It reports duplicate ids, links to activities that do not exist, links recorded on one side only, self links,
+// negative durations, progress outside of 0 and 1, logic loops, and open ends.
+// An activity without predecessor (or without successor) is only reported as an open end
+// if it is not the only one, as a project usually has a single start and a single finish.
+// The findings are sorted by activity id.
+func Check(activities []*activity.Activity) (findings []Finding) {
+	activitiesMap := make(map[int]*activity.Activity)
+	for _, a := range activities {
+		if _, ok := activitiesMap[a.Id]; ok {
+			findings = append(findings, Finding{Kind: DuplicateId, Severity: Error, ActivityId: a.Id, Message: fmt.Sprintf("duplicate id %d (%q)", a.Id, a.Description)})
+			continue
+		}
+		activitiesMap[a.Id] = a
+	}
+
+	var openStarts, openFinishes []int
+	for _, a := range activities {
+		if activitiesMap[a.Id] != a {
+			continue
+		}
+		findings = append(findings, checkLinks(a, activitiesMap)...)
+
+		if a.Duration < 0 {
+			findings = append(findings, Finding{Kind: NegativeDuration, Severity: Error, ActivityId: a.Id, Message: fmt.Sprintf("negative duration %s", a.Duration)})
+		}
+		if a.Progress < 0 || a.Progress > 1 {
+			findings = append(findings, Finding{Kind: InvalidProgress, Severity: Error, ActivityId: a.Id, Message: fmt.Sprintf("progress %v is not between 0 and 1", a.Progress)})
+		}
+
+		if len(a.PredecessorsId) == 0 {
+			openStarts = append(openStarts, a.Id)
+		}
+		if len(a.SuccessorsId) == 0 {
+			openFinishes = append(openFinishes, a.Id)
+		}
+	}
+
+	if len(openStarts) > 1 {
+		for _, id := range openStarts {
+			findings = append(findings, Finding{Kind: OpenStart, Severity: Warning, ActivityId: id, Message: "no predecessor"})
+		}
+	}
+	if len(openFinishes) > 1 {
+		for _, id := range openFinishes {
+			findings = append(findings, Finding{Kind: OpenFinish, Severity: Warning, ActivityId: id, Message: "no successor"})
+		}
+	}
+
+	for _, c := range Cycles(activities) {
+		// loops of a single activity are already reported as self links
+		if len(c.Ids) == 1 {
+			continue
+		}
+		findings = append(findings, Finding{Kind: LogicLoop, Severity: Error, ActivityId: c.Ids[0], RelatedId: c.Ids[len(c.Ids)-1], Message: fmt.Sprintf("logic loop %s", c)})
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].ActivityId < findings[j].ActivityId
+	})
+
+	return
+}
+
+// checkLinks checks the predecessors and successors of activity 'a' against the activities of 'activitiesMap'.
+func checkLinks(a *activity.Activity, activitiesMap map[int]*activity.Activity) (findings []Finding) {
+	for _, id := range a.PredecessorsId {
+		p, ok := activitiesMap[id]
+		switch {
+		case id == a.Id:
+			findings = append(findings, Finding{Kind: SelfLink, Severity: Error, ActivityId: a.Id, RelatedId: id, Message: "activity is its own predecessor"})
+		case !ok:
+			findings = append(findings, Finding{Kind: MissingPredecessor, Severity: Error, ActivityId: a.Id, RelatedId: id, Message: fmt.Sprintf("missing predecessor %d", id)})
+		case !slices.Contains(p.SuccessorsId, a.Id):
+			findings = append(findings, Finding{Kind: InconsistentLink, Severity: Error, ActivityId: a.Id, RelatedId: id, Message: fmt.Sprintf("predecessor %d does not list the activity as a successor", id)})
+		}
+	}
+
+	for _, id := range a.SuccessorsId {
+		s, ok := activitiesMap[id]
+		switch {
+		case id == a.Id:
+			findings = append(findings, Finding{Kind: SelfLink, Severity: Error, ActivityId: a.Id, RelatedId: id, Message: "activity is its own successor"})
+		case !ok:
+			findings = append(findings, Finding{Kind: MissingSuccessor, Severity: Error, ActivityId: a.Id, RelatedId: id, Message: fmt.Sprintf("missing successor %d", id)})
+		case !slices.Contains(s.PredecessorsId, a.Id):
+			findings = append(findings, Finding{Kind: InconsistentLink, Severity: Error, ActivityId: a.Id, RelatedId: id, Message: fmt.Sprintf("successor %d does not list the activity as a predecessor", id)})
+		}
+	}
+
+	return
+}
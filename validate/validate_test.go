@@ -0,0 +1,76 @@
+package validate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vanillaiice/verano/activity"
+)
+
+func TestCheck(t *testing.T) {
+	activities := []*activity.Activity{
+		{Id: 1, Description: "Dig trench", Duration: time.Hour, SuccessorsId: []int{2, 3}},
+		{Id: 2, Description: "Lay pipe", Duration: time.Hour, PredecessorsId: []int{1, 9}, SuccessorsId: []int{4}},
+		{Id: 3, Description: "Backfill trench", Duration: -time.Hour, PredecessorsId: []int{3}, SuccessorsId: []int{4}, Progress: 1.5},
+		{Id: 4, Description: "Open road", Duration: time.Hour, PredecessorsId: []int{2, 3}, SuccessorsId: []int{8}},
+		{Id: 4, Description: "Close road", Duration: time.Hour},
+		{Id: 5, Description: "Order signs", Duration: time.Hour, PredecessorsId: []int{6}, SuccessorsId: []int{6}},
+		{Id: 6, Description: "Install signs", Duration: time.Hour, PredecessorsId: []int{5}, SuccessorsId: []int{5}},
+	}
+
+	want := []Finding{
+		{Kind: InconsistentLink, Severity: Error, ActivityId: 1, RelatedId: 3},
+		{Kind: MissingPredecessor, Severity: Error, ActivityId: 2, RelatedId: 9},
+		{Kind: SelfLink, Severity: Error, ActivityId: 3, RelatedId: 3},
+		{Kind: NegativeDuration, Severity: Error, ActivityId: 3},
+		{Kind: InvalidProgress, Severity: Error, ActivityId: 3},
+		{Kind: DuplicateId, Severity: Error, ActivityId: 4},
+		{Kind: MissingSuccessor, Severity: Error, ActivityId: 4, RelatedId: 8},
+		{Kind: LogicLoop, Severity: Error, ActivityId: 5, RelatedId: 6},
+	}
+
+	findings := Check(activities)
+	if len(findings) != len(want) {
+		t.Fatalf("got %v, want %v", findings, want)
+	}
+	for i := range want {
+		f := findings[i]
+		if f.Kind != want[i].Kind || f.Severity != want[i].Severity || f.ActivityId != want[i].ActivityId || f.RelatedId != want[i].RelatedId {
+			t.Errorf("finding %d: got %v (%v, %d, %d), want %v (%v, %d, %d)", i, f.Kind, f.Severity, f.ActivityId, f.RelatedId, want[i].Kind, want[i].Severity, want[i].ActivityId, want[i].RelatedId)
+		}
+	}
+	if !HasErrors(findings) {
+		t.Error("expected findings to have errors")
+	}
+}
+
+func TestCheckValid(t *testing.T) {
+	activities := []*activity.Activity{
+		{Id: 1, Description: "Dig trench", Duration: time.Hour, SuccessorsId: []int{2, 3}},
+		{Id: 2, Description: "Lay pipe", Duration: time.Hour, PredecessorsId: []int{1}, SuccessorsId: []int{4}, Progress: 1},
+		{Id: 3, Description: "Order signs", Duration: time.Hour, PredecessorsId: []int{1}, SuccessorsId: []int{4}},
+		{Id: 4, Description: "Open road", Duration: time.Hour, PredecessorsId: []int{2, 3}},
+	}
+	if findings := Check(activities); len(findings) != 0 {
+		t.Errorf("got %v, want no findings", findings)
+	}
+}
+
+func TestCheckOpenEnds(t *testing.T) {
+	activities := []*activity.Activity{
+		{Id: 1, Description: "Dig trench", Duration: time.Hour},
+		{Id: 2, Description: "Order signs", Duration: time.Hour},
+	}
+	findings := Check(activities)
+	if len(findings) != 4 {
+		t.Fatalf("got %v, want %d findings", findings, 4)
+	}
+	for _, f := range findings {
+		if f.Kind != OpenStart && f.Kind != OpenFinish {
+			t.Errorf("got %v, want open ends only", f)
+		}
+	}
+	if HasErrors(findings) {
+		t.Error("expected open ends not to be errors")
+	}
+}